@@ -3,8 +3,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/taevas/build"
+	"github.com/BTBurke/taevas/build/dev"
+	"github.com/BTBurke/taevas/build/email"
+	"github.com/BTBurke/taevas/build/form"
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/route"
+	"github.com/BTBurke/taevas/utils"
 )
 
 // Use layout to register a template that serves as a base for other templates.
@@ -16,3 +31,304 @@ func Layout(template string) {
 	}
 	fmt.Printf("works for %s\n", template)
 }
+
+// Extract collects translatable messages from all templates into a catalog for each locale.  Locales are
+// comma separated, e.g. taevas extract en,es,pt-BR
+func Extract(locales string) error {
+	ctx, err := build.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	catalogs, err := ctx.ExtractMessages(strings.Split(locales, ",")...)
+	if err != nil {
+		return err
+	}
+	for _, c := range catalogs {
+		fmt.Printf("%s: %d messages, %d missing translations\n", c.Locale, len(c.Messages), len(c.Missing()))
+	}
+	return nil
+}
+
+// Explain prints how a template is classified, which layout it resolves to and the template tree of every
+// target it participates in, e.g. taevas explain blog/index.base.tmpl
+func Explain(path string) error {
+	ctx, err := build.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	// layout and define errors are reported but do not prevent explaining how the templates resolve
+	var lerr *build.LayoutError
+	var derr *build.DefineError
+	if err := ctx.Scan(); err != nil {
+		if !errors.As(err, &lerr) && !errors.As(err, &derr) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	e, err := ctx.Explain(abs)
+	if err != nil {
+		return err
+	}
+	fmt.Print(e)
+	return nil
+}
+
+// Lint reports partial templates that are never referenced, layouts that no target uses and blocks that are
+// never overridden
+func Lint() error {
+	ctx, err := build.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	report, err := ctx.Lint()
+	if err != nil {
+		return err
+	}
+	if report.Empty() {
+		return nil
+	}
+	fmt.Print(report)
+	return fmt.Errorf("found %d unused templates, %d unused layouts and %d dead blocks", len(report.UnusedTemplates), len(report.UnusedLayouts), len(report.DeadBlocks))
+}
+
+// Emails generates a Render<Name>Email function for every <name>.email.html.tmpl and <name>.email.txt.tmpl pair,
+// and a Render<Name>EmailIn function that takes a locale, and reports constructs in the html part that email clients
+// do not support
+func Emails() error {
+	ctx, err := build.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	out, err := fs.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	issues, err := email.GenerateAll(ctx.InputFS, out)
+	if err != nil {
+		return err
+	}
+	for _, i := range issues {
+		fmt.Fprintln(os.Stderr, i)
+	}
+	return out.Flush()
+}
+
+// Forms generates a struct, decoder and validator for every named form in the targets of each package, derived
+// from the inputs declared in the markup
+func Forms() error {
+	ctx, err := build.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	out, err := fs.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := form.GenerateAll(ctx.InputFS, out); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// Routes generates RegisterRoutes in the module root package, which serves every target at a route derived from its
+// location, e.g. blog/post.layout.tmpl at /blog/post.  Targets with render: static in their front matter are rendered
+// now and served as they are.  It fails when targets share a route.
+func Routes() error {
+	ctx, err := build.New(utils.GoRoot(), schemaOptions()...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	out, err := fs.New(utils.GoRoot())
+	if err != nil {
+		return err
+	}
+	if err := route.GenerateAll(ctx.InputFS, out, ".", ctx.Prerender); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// Site renders every target to a static file in dir, replacing files from a previous build, e.g. taevas site public.
+// Targets with route parameters are rendered once per entry in their data file and skipped when they have none.
+// sitemap.xml and collection feeds are written when TAEVAS_BASE_URL is set to the URL the site is served from, and a
+// search index when TAEVAS_SEARCH is set.  The aliases of targets are written as redirect stubs and listed in _redirects.
+func Site(dir string) error {
+	ctx, err := build.New(utils.GoRoot(), siteOptions(dir)...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Scan(); err != nil {
+		return err
+	}
+	site, err := ctx.Build()
+	if err != nil {
+		return err
+	}
+	for _, target := range site.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: no data file lists the values of its route parameters\n", target)
+	}
+	fmt.Printf("wrote %d pages, %d redirects and %d site files to %s\n", len(site.Pages), len(site.Redirects), len(site.Generated), dir)
+	return nil
+}
+
+// Watch builds the site into dir like Site, then rebuilds only the targets affected by each change to templates,
+// stylesheets, markdown and data files until interrupted, e.g. taevas watch public.  Files are polled every second, or
+// as often as TAEVAS_WATCH_INTERVAL sets, e.g. 250ms.
+func Watch(ctx context.Context, dir string) error {
+	interval, err := watchInterval()
+	if err != nil {
+		return err
+	}
+	c, err := build.New(utils.GoRoot(), siteOptions(dir)...)
+	if err != nil {
+		return err
+	}
+	if err := c.Scan(); err != nil {
+		return err
+	}
+	site, err := c.Build()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d pages to %s, watching for changes\n", len(site.Pages), dir)
+
+	c.Watch(ctx, interval, func(ch *build.Changes) error {
+		return rebuild(c, ch)
+	}, func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+	})
+	return nil
+}
+
+// Serve builds the site, serves it on localhost:8080 or TAEVAS_ADDR and rebuilds it like Watch as files change until
+// interrupted.  Pages reload in the browser after every rebuild, and only swap their stylesheets when stylesheets
+// alone changed.  Stylesheets and other files that are not templates or data are served from the module root.
+func Serve(ctx context.Context) error {
+	addr := os.Getenv("TAEVAS_ADDR")
+	if addr == "" {
+		addr = "localhost:8080"
+	}
+	interval, err := watchInterval()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "taevas-serve")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := build.New(utils.GoRoot(), append(siteOptions(dir), build.WithLiveReload(dev.ScriptPath))...)
+	if err != nil {
+		return err
+	}
+	if err := c.Scan(); err != nil {
+		return err
+	}
+	site, err := c.Build()
+	if err != nil {
+		return err
+	}
+	for _, target := range site.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: no data file lists the values of its route parameters\n", target)
+	}
+
+	srv := dev.New(dir, utils.GoRoot(), c.IsSource)
+	go c.Watch(ctx, interval, func(ch *build.Changes) error {
+		if err := rebuild(c, ch); err != nil {
+			return err
+		}
+		srv.Changed(ch.Files)
+		return nil
+	}, func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+	})
+
+	hs := &http.Server{Addr: addr, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		hs.Close()
+	}()
+	fmt.Printf("serving on http://%s\n", addr)
+	if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// rebuild renders the targets affected by changes again.  Changes that affect no target, such as to a stylesheet,
+// need no rebuild.
+func rebuild(c *build.Context, ch *build.Changes) error {
+	if len(ch.Targets) == 0 {
+		return nil
+	}
+	start := time.Now()
+	if _, err := c.Rebuild(ch.Targets); err != nil {
+		return err
+	}
+	fmt.Printf("rebuilt %d targets for %s in %s\n", len(ch.Targets), strings.Join(ch.Files, ", "), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// watchInterval returns how often to poll for changes, every second unless TAEVAS_WATCH_INTERVAL sets it
+func watchInterval() (time.Duration, error) {
+	v := os.Getenv("TAEVAS_WATCH_INTERVAL")
+	if v == "" {
+		return time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TAEVAS_WATCH_INTERVAL: %w", err)
+	}
+	return d, nil
+}
+
+// schemas are the Go types that targets validate their data files against by name, e.g. schema: Post in front matter.
+// Register them from another file in this directory, which may import the packages of the module:
+//
+//	func init() {
+//		schemas["Post"] = blog.Post{}
+//	}
+//
+// Schemas written as JSON schema files, e.g. schema: schemas/post.json, need no registration.
+var schemas = map[string]interface{}{}
+
+// schemaOptions registers the schemas with a build
+func schemaOptions() []build.BuildOption {
+	var opts []build.BuildOption
+	for name, v := range schemas {
+		opts = append(opts, build.WithSchema(name, v))
+	}
+	return opts
+}
+
+// siteOptions configures a static build into dir from the environment
+func siteOptions(dir string) []build.BuildOption {
+	opts := append([]build.BuildOption{build.WithOutputDirectory(dir, true)}, schemaOptions()...)
+	if base := os.Getenv("TAEVAS_BASE_URL"); base != "" {
+		opts = append(opts, build.WithBaseURL(base))
+	}
+	if os.Getenv("TAEVAS_SEARCH") != "" {
+		opts = append(opts, build.WithSearchIndex())
+	}
+	return opts
+}
@@ -0,0 +1,205 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/route"
+	"github.com/BTBurke/taevas/utils"
+)
+
+// Item is a markdown or data file of a collection as seen by templates
+type Item struct {
+	Slug string
+	// URL is the page of the item, or empty when no target renders the items of its collection
+	URL string
+	// Data is the front matter of a markdown file or the object in a data file
+	Data map[string]interface{}
+	// Body is the text of a markdown file after its front matter
+	Body string
+
+	path    string
+	modtime time.Time
+}
+
+// Listing is the data that a target declaring a collection is rendered with.  Targets with route parameters render
+// one item per page and other targets render a page of items.
+type Listing struct {
+	// Data is the data file or front matter of the target
+	Data  interface{}
+	Item  *Item
+	Items []Item
+	// Page is the number of the page starting at 1, or of the item for item pages
+	Page  int
+	Pages int
+	// Prev and Next are the URLs of the neighbouring pages, or empty on the first and last page
+	Prev string
+	Next string
+}
+
+// collection is declared in the front matter of a target:
+//
+//	collection: blog/posts
+//	sort: date
+//	order: desc
+//	filter: {draft: false}
+//	per_page: 10
+//
+// The directory is relative to the build root.  Items are filtered by the values of their metadata and sorted by
+// the value of a metadata key, or by file name.  Targets without route parameters list per_page items on each page,
+// or every item on a single page when it is not set.
+type collection struct {
+	dir     string
+	query   fs.CollectionQuery
+	perPage int
+}
+
+// parseCollection returns the collection declared by a target or nil when it declares none
+func parseCollection(target string, m fs.Metadata) (*collection, error) {
+	dir := m.Get("collection", "")
+	if dir == "" {
+		return nil, nil
+	}
+	c := &collection{dir: utils.ParsePath(dir).Dir(), query: fs.CollectionQuery{Sort: m.Get("sort", "")}}
+	switch m.Get("order", "asc") {
+	case "asc":
+	case "desc":
+		c.query.Desc = true
+	default:
+		return nil, fmt.Errorf("invalid collection in %s: order must be asc or desc", target)
+	}
+	if filter := m.Get("filter", ""); filter != "" {
+		var where map[string]interface{}
+		if err := json.Unmarshal([]byte(filter), &where); err != nil {
+			return nil, fmt.Errorf("invalid collection in %s: filter must be a table of metadata values", target)
+		}
+		c.query.Where = make(map[string]string, len(where))
+		for key, value := range where {
+			c.query.Where[key] = fmt.Sprint(value)
+		}
+	}
+	if perPage := m.Get("per_page", ""); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid collection in %s: per_page must be a positive number", target)
+		}
+		c.perPage = n
+	}
+	return c, nil
+}
+
+// items returns the items of a collection in order
+func (c *Context) items(col *collection) ([]Item, error) {
+	found, err := c.InputFS.Collection(col.dir, col.query)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, len(found))
+	for i, f := range found {
+		modtime, err := c.InputFS.ModTime(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.readData(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = Item{Slug: f.Slug, path: f.Path, modtime: modtime}
+		switch d := data.(type) {
+		case map[string]interface{}:
+			items[i].Data = d
+		case nil:
+			items[i].Data = decodeMetadata(f.Metadata)
+		}
+		if path.Ext(f.Path) != ".md" {
+			continue
+		}
+		b, err := c.InputFS.ReadFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Body = string(b)
+	}
+	return items, nil
+}
+
+// itemPages returns a page for every item rendered by a target with route parameters.  The slug fills a parameter
+// named slug and the data of the item fills the others.
+func itemPages(r route.Route, items []Item, data interface{}) ([]Page, []interface{}, error) {
+	pages := make([]Page, len(items))
+	for i := range items {
+		values := map[string]interface{}{"slug": items[i].Slug}
+		for key, value := range items[i].Data {
+			if key != "slug" {
+				values[key] = value
+			}
+		}
+		url, err := fill(r, values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build %s: item %s %w", r.Target, items[i].Slug, err)
+		}
+		items[i].URL = url
+		pages[i] = Page{Target: r.Target, URL: url}
+	}
+	listings := make([]interface{}, len(items))
+	for i := range items {
+		l := &Listing{Data: data, Item: &items[i], Page: i + 1, Pages: len(items)}
+		if i > 0 {
+			l.Prev = items[i-1].URL
+		}
+		if i < len(items)-1 {
+			l.Next = items[i+1].URL
+		}
+		listings[i] = l
+	}
+	return pages, listings, nil
+}
+
+// listPages returns the pages listing the items of a collection.  The first page is served at the route of the
+// target and the others below it, e.g. /blog/page/2.
+func listPages(r route.Route, items []Item, perPage int, data interface{}) ([]Page, []interface{}) {
+	if perPage == 0 || perPage > len(items) {
+		perPage = len(items)
+	}
+	n := 1
+	if perPage > 0 {
+		n = (len(items) + perPage - 1) / perPage
+	}
+	base := r.Path
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	url := func(page int) string {
+		if page == 1 {
+			return r.Path
+		}
+		return base + "page/" + strconv.Itoa(page)
+	}
+
+	pages := make([]Page, n)
+	listings := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		l := &Listing{Data: data, Page: i + 1, Pages: n}
+		if perPage > 0 {
+			end := (i + 1) * perPage
+			if end > len(items) {
+				end = len(items)
+			}
+			l.Items = items[i*perPage : end]
+		}
+		if i > 0 {
+			l.Prev = url(i)
+		}
+		if i < n-1 {
+			l.Next = url(i + 2)
+		}
+		pages[i] = Page{Target: r.Target, URL: url(i + 1)}
+		listings[i] = l
+	}
+	return pages, listings
+}
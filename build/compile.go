@@ -2,12 +2,16 @@ package build
 
 import (
 	"fmt"
+	"html/template"
+	iofs "io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/schema"
 	"github.com/BTBurke/taevas/utils"
 	"golang.org/x/net/html"
 )
@@ -106,23 +110,254 @@ type Context struct {
 	TC       TemplateCompiler
 	OutputFS map[string]*fs.Filesystem
 	InputFS  *fs.Filesystem
+
+	opts *options
+	// built and written are the output of every target and the files written by the previous static build, which
+	// Rebuild reuses
+	built   map[string]*builtTarget
+	written map[string]string
+	// invalid holds the parse errors of markdown and data files by path, which are reported when a build reads them
+	invalid map[string]error
 }
 
 // New returns a new build context, setting the template compiler and any global
 // options
 func New(root string, opts ...BuildOption) (*Context, error) {
-	// TODO: figure out how to create a top level build context
-	return nil, nil
+	o := &options{
+		templateExt:  ".tmpl",
+		layoutPrefix: "_",
+	}
+	for _, opt := range append([]BuildOption{withRoot(root)}, opts...) {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.outDir == "" {
+		o.outDir = o.root
+	}
+	if o.catalogDir == "" {
+		o.catalogDir = filepath.Join(o.root, "locales")
+	}
+
+	in, err := fs.New(o.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input filesystem: %w", err)
+	}
+	if err := o.configure(in); err != nil {
+		return nil, err
+	}
+	return &Context{
+		OutputFS: make(map[string]*fs.Filesystem),
+		InputFS:  in,
+		opts:     o,
+	}, nil
+}
+
+// indexedExtensions are the extensions of files other than templates that Scan indexes
+var indexedExtensions = map[string]bool{
+	".css":  true,
+	".json": true,
+	".md":   true,
+	".yaml": true,
+	".yml":  true,
+}
+
+// Scan indexes the templates, stylesheets, markdown and data files under the root, skipping hidden directories,
+// vendored code and the output directory.  Markdown and data files that fail to parse are reported by the builds
+// that read them.  It returns a LayoutError or a DefineError when layouts or definitions conflict.
+func (c *Context) Scan() error {
+	if err := c.walk(func(rel string, d iofs.DirEntry) error { return c.index(rel) }); err != nil {
+		return err
+	}
+	if err := c.checkLayouts(); err != nil {
+		return err
+	}
+	return c.checkDefines()
+}
+
+// walk calls fn with the path relative to the root of every file that Scan indexes
+func (c *Context) walk(fn func(rel string, d iofs.DirEntry) error) error {
+	return filepath.WalkDir(c.opts.root, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != c.opts.root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "node_modules" || (path == c.opts.outDir && c.opts.outDir != c.opts.root)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// stylesheets are indexed so that templates can refer to variants such as .rtl.css, and markdown and data files
+		// so that static builds can render targets and collections with them
+		if !c.opts.isTemplate(d.Name()) && !indexedExtensions[filepath.Ext(d.Name())] {
+			return nil
+		}
+		rel, err := filepath.Rel(c.opts.root, path)
+		if err != nil {
+			return err
+		}
+		return fn(rel, d)
+	})
+}
+
+// index adds a file found by walk to the input filesystem.  Any markdown or data file in the module is indexed, so
+// one that fails to parse is added without metadata and its error is only returned when a build reads it.
+func (c *Context) index(rel string) error {
+	add := c.InputFS.AddTemplate
+	switch {
+	case c.opts.isTemplate(filepath.Base(rel)):
+	case filepath.Ext(rel) == ".css":
+		add = c.InputFS.Add
+	default:
+		_, perr := c.InputFS.AddData(rel)
+		if perr == nil {
+			return nil
+		}
+		if _, err := c.InputFS.Add(rel); err != nil {
+			return fmt.Errorf("failed to scan %s: %w", rel, err)
+		}
+		if c.invalid == nil {
+			c.invalid = make(map[string]error)
+		}
+		c.invalid[utils.ParsePath(rel).String()] = perr
+		return nil
+	}
+	if _, err := add(rel); err != nil {
+		return fmt.Errorf("failed to scan %s: %w", rel, err)
+	}
+	return nil
+}
+
+// IsSource reports whether a file name is a template, markdown or data file that a build reads rather than a file
+// such as a stylesheet that a site serves as it is
+func (c *Context) IsSource(name string) bool {
+	return c.opts.isTemplate(name) || (indexedExtensions[filepath.Ext(name)] && filepath.Ext(name) != ".css")
+}
+
+// LayoutError reports layout inheritance cycles and targets whose layout does not exist, which would otherwise
+// silently drop out of the template tree
+type LayoutError struct {
+	Cycles    [][]string
+	Unmatched []fs.UnmatchedTarget
+}
+
+func (e *LayoutError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid layouts:")
+	for _, c := range e.Cycles {
+		fmt.Fprintf(&b, "\n  layout inheritance cycle: %s", strings.Join(c, " -> "))
+	}
+	for _, t := range e.Unmatched {
+		if t.Layout == "" {
+			fmt.Fprintf(&b, "\n  target %s does not name a layout and there is no default layout", t.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "\n  target %s uses layout %q which does not exist", t.Path, t.Layout)
+	}
+	return b.String()
+}
+
+// checkLayouts returns a LayoutError when the scanned templates contain layout cycles or unmatched targets
+func (c *Context) checkLayouts() error {
+	cycles, err := c.InputFS.LayoutCycles()
+	if err != nil {
+		return err
+	}
+	unmatched, err := c.InputFS.UnmatchedTargets()
+	if err != nil {
+		return err
+	}
+	if len(cycles) > 0 || len(unmatched) > 0 {
+		return &LayoutError{Cycles: cycles, Unmatched: unmatched}
+	}
+	return nil
+}
+
+// Explain reports how a scanned file is classified and the template tree of every target it participates in.  The
+// path may be absolute or relative to the build root.
+func (c *Context) Explain(path string) (*fs.Explanation, error) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(c.opts.root, path)
+		if err != nil {
+			return nil, err
+		}
+		path = rel
+	}
+	return c.InputFS.Explain(filepath.ToSlash(path))
 }
 
 type BuildOption func(*options) error
 
 type options struct {
 	templateExt     string
+	extensions      map[string]string
 	root            string
 	outDir          string
 	outDirOverwrite bool
+	catalogDir      string
 	timeout         time.Duration
+	funcs           template.FuncMap
+	schemas         map[string]*schema.Schema
+	baseURL         string
+	search          bool
+	liveReload      string
+
+	// naming conventions used to classify templates, see create.sql
+	layoutPrefix  string
+	layoutDir     string
+	targetSuffix  string
+	globalsDir    string
+	defaultLayout string
+	dirLayouts    map[string]string
+	partialNames  string
+	routes        map[string]string
+}
+
+// configure stores the naming conventions in the config table of a filesystem so that its views classify
+// templates the same way as the input filesystem
+func (o *options) configure(f *fs.Filesystem) error {
+	for key, value := range map[string]string{
+		"template_extension": o.templateExt,
+		"layout_prefix":      o.layoutPrefix,
+		"layout_directory":   o.layoutDir,
+		"target_suffix":      o.targetSuffix,
+		"globals_directory":  o.globalsDir,
+		"default_layout":     o.defaultLayout,
+		"partial_names":      o.partialNames,
+	} {
+		if err := f.SetConfig(key, value); err != nil {
+			return err
+		}
+	}
+	for dir, layout := range o.dirLayouts {
+		if err := f.SetDirectoryLayout(dir, layout); err != nil {
+			return err
+		}
+	}
+	for ext, typ := range o.extensions {
+		if err := f.SetExtension(ext, typ); err != nil {
+			return err
+		}
+	}
+	for target, route := range o.routes {
+		if err := f.SetRoute(target, route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTemplate reports whether the file name has one of the template extensions
+func (o *options) isTemplate(name string) bool {
+	if strings.HasSuffix(name, o.templateExt) {
+		return true
+	}
+	for ext := range o.extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
 }
 
 func WithTemplateExtension(ext string) BuildOption {
@@ -135,6 +370,33 @@ func WithTemplateExtension(ext string) BuildOption {
 	}
 }
 
+// WithHTMLExtensions adds template extensions parsed with html/template in addition to the template extension,
+// e.g. .html and .gohtml
+func WithHTMLExtensions(exts ...string) BuildOption {
+	return withExtensions(fs.TypeHTML, exts)
+}
+
+// WithTextExtensions adds template extensions parsed with text/template for plain text output, e.g. .txt.tmpl
+// and .md.tmpl.  Text targets only use layouts and partials that are also text templates.
+func WithTextExtensions(exts ...string) BuildOption {
+	return withExtensions(fs.TypeText, exts)
+}
+
+func withExtensions(typ string, exts []string) BuildOption {
+	return func(o *options) error {
+		if o.extensions == nil {
+			o.extensions = make(map[string]string)
+		}
+		for _, ext := range exts {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			o.extensions[ext] = typ
+		}
+		return nil
+	}
+}
+
 func withRoot(root string) BuildOption {
 	return func(o *options) error {
 		// root can be relative or absolute directory. If relative, it should refer to Go module root
@@ -169,3 +431,184 @@ func WithTimeout(d time.Duration) BuildOption {
 		return nil
 	}
 }
+
+// WithCatalogDirectory sets the directory holding the translation catalog for each locale.  Relative
+// directories refer to the Go module root.  Defaults to locales in the build root.
+func WithCatalogDirectory(dir string) BuildOption {
+	return func(o *options) error {
+		p := utils.ParsePath(dir)
+		if !p.IsAbs() {
+			dir = filepath.Join(utils.GoRoot(), dir)
+		}
+		o.catalogDir = dir
+		return nil
+	}
+}
+
+// WithLayoutPrefix sets the file name prefix that marks a template as a layout.  Defaults to _.
+func WithLayoutPrefix(prefix string) BuildOption {
+	return func(o *options) error {
+		o.layoutPrefix = prefix
+		return nil
+	}
+}
+
+// WithLayoutDirectory treats every template in dir, relative to the build root, as a layout available to
+// all targets, e.g. layouts/base.tmpl.  The layout prefix becomes optional.
+func WithLayoutDirectory(dir string) BuildOption {
+	return func(o *options) error {
+		d, err := relativeDir(dir)
+		if err != nil {
+			return fmt.Errorf("error setting layout directory: %w", err)
+		}
+		o.layoutDir = d
+		return nil
+	}
+}
+
+// WithTargetSuffix marks targets by a suffix before the template extension instead of by naming their
+// layout, e.g. .page for index.page.html.  Targets may still name a layout before the suffix, such as
+// index.admin.page.html, and otherwise use the default layout.
+func WithTargetSuffix(suffix string, defaultLayout string) BuildOption {
+	return func(o *options) error {
+		if suffix != "" && !strings.HasPrefix(suffix, ".") {
+			suffix = "." + suffix
+		}
+		o.targetSuffix = suffix
+		o.defaultLayout = defaultLayout
+		return nil
+	}
+}
+
+// WithGlobalsDirectory makes the templates in dir, relative to the build root, the only globals instead of
+// inferring globals from directories without targets, e.g. components
+func WithGlobalsDirectory(dir string) BuildOption {
+	return func(o *options) error {
+		d, err := relativeDir(dir)
+		if err != nil {
+			return fmt.Errorf("error setting globals directory: %w", err)
+		}
+		o.globalsDir = d
+		return nil
+	}
+}
+
+// WithDirectoryLayout sets the layout used by targets in dir, relative to the build root, and its subdirectories
+// when they do not name one, e.g. admin.  It takes precedence over the default layout of WithTargetSuffix and
+// requires a target suffix, since targets otherwise name their layout, so New returns an error without one.
+func WithDirectoryLayout(dir string, layout string) BuildOption {
+	return func(o *options) error {
+		d := "."
+		if filepath.Clean(dir) != "." {
+			var err error
+			if d, err = relativeDir(dir); err != nil {
+				return fmt.Errorf("error setting directory layout: %w", err)
+			}
+		}
+		if o.dirLayouts == nil {
+			o.dirLayouts = make(map[string]string)
+		}
+		o.dirLayouts[d] = layout
+		return nil
+	}
+}
+
+// WithNamespacedPartials names locals and globals by their root relative path without the extension, e.g.
+// {{ template "components/button" . }}, so that partials with the same file name in different directories do
+// not collide.  Short names can be added with the alias key in the front matter of a partial.
+func WithNamespacedPartials() BuildOption {
+	return func(o *options) error {
+		o.partialNames = "path"
+		return nil
+	}
+}
+
+// WithRoute serves the target at path, relative to the build root, at route instead of the route derived from its
+// location, e.g. WithRoute("blog/post.layout.tmpl", "/articles/post").  A route in the front matter of the target
+// takes precedence.
+func WithRoute(path string, route string) BuildOption {
+	return func(o *options) error {
+		p := filepath.ToSlash(filepath.Clean(path))
+		if filepath.IsAbs(p) || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("error setting route: %s must be relative to the build root", path)
+		}
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("error setting route: route %q for %s must be an absolute URL path", route, path)
+		}
+		if o.routes == nil {
+			o.routes = make(map[string]string)
+		}
+		o.routes[p] = route
+		return nil
+	}
+}
+
+// WithTemplateFuncs makes funcs available to every template rendered by Build
+func WithTemplateFuncs(funcs template.FuncMap) BuildOption {
+	return func(o *options) error {
+		if o.funcs == nil {
+			o.funcs = make(template.FuncMap, len(funcs))
+		}
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+		return nil
+	}
+}
+
+// WithSchema registers the schema derived from the Go type of v under name, so that targets can validate their data
+// files against it with schema: name in their front matter, e.g. WithSchema("Post", Post{})
+func WithSchema(name string, v interface{}) BuildOption {
+	return func(o *options) error {
+		if name == "" || strings.HasSuffix(name, ".json") {
+			return fmt.Errorf("error setting schema: invalid name %q", name)
+		}
+		if o.schemas == nil {
+			o.schemas = make(map[string]*schema.Schema)
+		}
+		o.schemas[name] = schema.For(v)
+		return nil
+	}
+}
+
+// WithBaseURL sets the scheme and host the site is served from, e.g. https://example.com, which static builds need to
+// write the absolute URLs of sitemap.xml and collection feeds
+func WithBaseURL(base string) BuildOption {
+	return func(o *options) error {
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("error setting base url: %q must be an absolute http or https URL", base)
+		}
+		o.baseURL = strings.TrimSuffix(base, "/")
+		return nil
+	}
+}
+
+// WithSearchIndex writes search.json, an index of the text of every html page rendered by Build, and search.js, a
+// script that searches it in the browser.  Elements marked with data-search-ignore, such as navigation, are not
+// indexed.
+func WithSearchIndex() BuildOption {
+	return func(o *options) error {
+		o.search = true
+		return nil
+	}
+}
+
+// WithLiveReload adds a script loaded from src to every html page rendered by Build, for development servers that
+// reload pages when the site is rebuilt.  The script is inserted before the closing body tag, or at the end of pages
+// without one.
+func WithLiveReload(src string) BuildOption {
+	return func(o *options) error {
+		o.liveReload = src
+		return nil
+	}
+}
+
+// relativeDir cleans a directory relative to the build root into the form stored in the fs database
+func relativeDir(dir string) (string, error) {
+	d := filepath.ToSlash(filepath.Clean(dir))
+	if filepath.IsAbs(d) || d == "." || strings.HasPrefix(d, "../") {
+		return "", fmt.Errorf("%s must be a subdirectory of the build root", dir)
+	}
+	return d, nil
+}
@@ -0,0 +1,182 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates files relative to a temporary root and returns the root
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(td) })
+
+	for path, content := range files {
+		p := filepath.Join(td, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	return td
+}
+
+func TestScan(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":         `{{ template "content" . }}`,
+		"a/index.layout.tmpl":  "---\ntitle: A\n---\n{{ define \"content\" }}a{{ end }}",
+		"a/notes.txt":          `not a template`,
+		".git/x.tmpl":          ``,
+		"vendor/v/_other.tmpl": ``,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	var paths []string
+	require.NoError(t, ctx.InputFS.Conn().Select(&paths, "SELECT path FROM filesystem ORDER BY path"))
+	assert.Equal(t, []string{"./_layout.tmpl", "a/index.layout.tmpl"}, paths)
+
+	tree, err := ctx.InputFS.TargetTree("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"./_layout.tmpl", "a/index.layout.tmpl"}, tree)
+
+	// front matter is stored as metadata and stripped from the template
+	b, err := ctx.InputFS.ReadFile("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, `{{ define "content" }}a{{ end }}`, string(b))
+	m, err := ctx.InputFS.Metadata("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "A", m["title"])
+}
+
+func TestLocalize(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":        `<title>{{ t "Home" }}</title>{{ template "content" . }}`,
+		"a/index.layout.tmpl": `{{ define "content" }}<p data-i18n>Hello</p>{{ end }}`,
+		"locales/es.json":     `{"Home": "Inicio", "Hello": ""}`,
+	})
+	out := filepath.Join(root, "out")
+	ctx, err := New(root, WithOutputDirectory(out, true))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	catalogs, err := ctx.ExtractMessages("es", "fr")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Home": "Inicio", "Hello": ""}, catalogs[0].Messages)
+	assert.Equal(t, []string{"Hello", "Home"}, catalogs[1].Missing())
+	_, err = os.Stat(filepath.Join(root, "locales", "fr.json"))
+	assert.NoError(t, err)
+
+	missing, err := ctx.Localize("es", "fr")
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(missing["es"]))
+	assert.Equal(t, "Hello", missing["es"][0].ID)
+	assert.Equal(t, 2, len(missing["fr"]))
+
+	b, err := ctx.OutputFS["es"].ReadFile("_layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, `<title>{{ t "Inicio" }}</title>{{ template "content" . }}`, string(b))
+
+	// translated template sets keep the same structure as the input
+	tree, err := ctx.OutputFS["fr"].TargetTree("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"./_layout.tmpl", "a/index.layout.tmpl"}, tree)
+}
+
+func TestNamingOptions(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"layouts/base.html":      `{{ template "content" . }}`,
+		"components/button.html": `{{ define "button" }}<button>{{ end }}`,
+		"blog/index.page.html":   `{{ define "content" }}{{ template "button" }}{{ end }}`,
+		"blog/post.html":         `{{ define "post" }}{{ end }}`,
+	})
+	ctx, err := New(root,
+		WithTemplateExtension(".html"),
+		WithLayoutDirectory("layouts/"),
+		WithTargetSuffix("page", "base"),
+		WithGlobalsDirectory("./components"),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	tree, err := ctx.InputFS.TargetTree("blog/index.page.html")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"layouts/base.html", "components/button.html", "blog/post.html", "blog/index.page.html"}, tree)
+
+	ctx, err = New(root, WithTemplateExtension(".html"), WithLayoutDirectory("layouts"), WithTargetSuffix(".page", "missing"), WithDirectoryLayout("blog", "base"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	tree, err = ctx.InputFS.TargetTree("blog/index.page.html")
+	require.NoError(t, err)
+	assert.Equal(t, "layouts/base.html", tree[0])
+
+	_, err = New(root, WithLayoutDirectory("layouts"), WithDirectoryLayout("blog", "base"))
+	assert.Error(t, err)
+	_, err = New(root, WithLayoutDirectory("../layouts"))
+	assert.Error(t, err)
+	_, err = New(root, WithGlobalsDirectory("."))
+	assert.Error(t, err)
+}
+
+func TestScanLayoutErrors(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_a.b.tmpl":       ``,
+		"_b.a.tmpl":       ``,
+		"x/index.a.tmpl":  ``,
+		"x/other.no.tmpl": ``,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+
+	err = ctx.Scan()
+	var lerr *LayoutError
+	require.True(t, errors.As(err, &lerr))
+	assert.Equal(t, [][]string{{"./_a.b.tmpl", "./_b.a.tmpl", "./_a.b.tmpl"}}, lerr.Cycles)
+	assert.Equal(t, []fs.UnmatchedTarget{{Path: "x/other.no.tmpl", Layout: "no"}}, lerr.Unmatched)
+	assert.Contains(t, err.Error(), "layout inheritance cycle: ./_a.b.tmpl -> ./_b.a.tmpl -> ./_a.b.tmpl")
+	assert.Contains(t, err.Error(), `target x/other.no.tmpl uses layout "no" which does not exist`)
+}
+
+func TestExplain(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":        ``,
+		"a/index.layout.tmpl": ``,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	for _, path := range []string{"a/index.layout.tmpl", filepath.Join(root, "a", "index.layout.tmpl")} {
+		e, err := ctx.Explain(path)
+		require.NoError(t, err)
+		assert.Equal(t, fs.KindTarget, e.Kind)
+		assert.Equal(t, "./_layout.tmpl", e.Layout)
+	}
+}
+
+func TestRouteOption(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":          ``,
+		"index.layout.tmpl":     ``,
+		"blog/post.layout.tmpl": ``,
+	})
+	ctx, err := New(root, WithRoute("./blog/post.layout.tmpl", "/articles/post"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	routes, err := ctx.InputFS.Routes()
+	require.NoError(t, err)
+	assert.Equal(t, []fs.Route{
+		{Target: "./index.layout.tmpl", Route: "/", Type: fs.TypeHTML},
+		{Target: "blog/post.layout.tmpl", Route: "/articles/post", Type: fs.TypeHTML},
+	}, routes)
+
+	_, err = New(root, WithRoute("blog/post.layout.tmpl", "articles/post"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defineRE matches {{ define "name" }} and {{ block "name" . }} actions
+var defineRE = regexp.MustCompile("{{-?\\s*(define|block)\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// Definition is a named template defined by a define or block action
+type Definition struct {
+	Name     string
+	Template string
+	Line     int
+	// Block is true for {{ block }}, which defines a default meant to be overridden
+	Block bool
+}
+
+func (d Definition) String() string {
+	return fmt.Sprintf("%s:%d", d.Template, d.Line)
+}
+
+// DefineConflict is a template name defined more than once in the template tree of one or more targets.
+// Definitions are in parse order, so the last one is used when the target is rendered.
+type DefineConflict struct {
+	Name        string
+	Definitions []Definition
+	Targets     []string
+	// Override is true when the first definition is in a layout or is a block, which is how a target or a more
+	// specific layout overrides a layout block.  Any other redefinition is an accidental collision.
+	Override bool
+}
+
+// DefineError reports accidental collisions between template definitions
+type DefineError struct {
+	Conflicts []DefineConflict
+}
+
+func (e *DefineError) Error() string {
+	var b strings.Builder
+	b.WriteString("duplicate template definitions:")
+	for _, c := range e.Conflicts {
+		locations := make([]string, len(c.Definitions))
+		for i, d := range c.Definitions {
+			locations[i] = d.String()
+		}
+		fmt.Fprintf(&b, "\n  %q is defined in %s, the definition in %s is used by %s", c.Name, strings.Join(locations, " and "), locations[len(locations)-1], strings.Join(c.Targets, ", "))
+	}
+	return b.String()
+}
+
+// Definitions returns the define and block actions in a template, numbering lines from the start of src
+func Definitions(template string, src []byte) []Definition {
+	var defs []Definition
+	for _, m := range defineRE.FindAllSubmatchIndex(src, -1) {
+		name, err := strconv.Unquote(string(src[m[4]:m[5]]))
+		if err != nil {
+			continue
+		}
+		defs = append(defs, Definition{
+			Name:     name,
+			Template: template,
+			Line:     bytes.Count(src[:m[0]], []byte("\n")) + 1,
+			Block:    string(src[m[2]:m[3]]) == "block",
+		})
+	}
+	return defs
+}
+
+// definitions returns the definitions in a template of the input filesystem, numbering lines from the start of the
+// file including its front matter
+func (c *Context) definitions(template string) ([]Definition, error) {
+	b, err := c.InputFS.ReadFile(template)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := c.InputFS.FrontMatterLines(template)
+	if err != nil {
+		return nil, err
+	}
+	defs := Definitions(template, b)
+	for i := range defs {
+		defs[i].Line += offset
+	}
+	return defs, nil
+}
+
+// CheckDefines finds template names that are defined by more than one template in the tree of a target, both
+// intentional overrides of layout blocks and accidental collisions
+func (c *Context) CheckDefines() ([]DefineConflict, error) {
+	targets, err := c.InputFS.Targets()
+	if err != nil {
+		return nil, err
+	}
+	layouts, err := c.InputFS.Layouts()
+	if err != nil {
+		return nil, err
+	}
+	isLayout := make(map[string]bool, len(layouts))
+	for _, l := range layouts {
+		isLayout[l] = true
+	}
+
+	defs := make(map[string][]Definition)
+	definitions := func(template string) ([]Definition, error) {
+		if d, ok := defs[template]; ok {
+			return d, nil
+		}
+		d, err := c.definitions(template)
+		if err != nil {
+			return nil, err
+		}
+		defs[template] = d
+		return d, nil
+	}
+
+	// conflicts are keyed by name and locations so that a collision between globals is reported once for all targets
+	conflicts := make(map[string]*DefineConflict)
+	var keys []string
+	for _, target := range targets {
+		tree, err := c.InputFS.TargetTree(target)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string][]Definition)
+		var names []string
+		for _, template := range tree {
+			d, err := definitions(template)
+			if err != nil {
+				return nil, err
+			}
+			for _, def := range d {
+				// a template may redefine its own names, which is not a conflict between templates
+				if n := byName[def.Name]; len(n) > 0 && n[len(n)-1].Template == def.Template {
+					continue
+				}
+				if _, ok := byName[def.Name]; !ok {
+					names = append(names, def.Name)
+				}
+				byName[def.Name] = append(byName[def.Name], def)
+			}
+		}
+
+		for _, name := range names {
+			d := byName[name]
+			if len(d) < 2 {
+				continue
+			}
+			key := name
+			for _, def := range d {
+				key += "\x00" + def.String()
+			}
+			if conflict, ok := conflicts[key]; ok {
+				conflict.Targets = append(conflict.Targets, target)
+				continue
+			}
+			conflicts[key] = &DefineConflict{
+				Name:        name,
+				Definitions: d,
+				Targets:     []string{target},
+				Override:    isLayout[d[0].Template] || d[0].Block,
+			}
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make([]DefineConflict, len(keys))
+	for i, key := range keys {
+		out[i] = *conflicts[key]
+	}
+	return out, nil
+}
+
+// checkDefines returns a DefineError when templates in the tree of a target accidentally define the same name
+func (c *Context) checkDefines() error {
+	conflicts, err := c.CheckDefines()
+	if err != nil {
+		return err
+	}
+	var collisions []DefineConflict
+	for _, conflict := range conflicts {
+		if !conflict.Override {
+			collisions = append(collisions, conflict)
+		}
+	}
+	if len(collisions) > 0 {
+		return &DefineError{Conflicts: collisions}
+	}
+	return nil
+}
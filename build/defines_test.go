@@ -0,0 +1,56 @@
+package build
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinitions(t *testing.T) {
+	src := "{{ block \"title\" . }}x{{ end }}\n{{- define `card` -}}{{ end }}\n{{define \"a\\\"b\"}}{{end}}{{ template \"card\" }}"
+	assert.Equal(t, []Definition{
+		{Name: "title", Template: "x.tmpl", Line: 1, Block: true},
+		{Name: "card", Template: "x.tmpl", Line: 2},
+		{Name: `a"b`, Template: "x.tmpl", Line: 3},
+	}, Definitions("x.tmpl", []byte(src)))
+}
+
+func TestCheckDefines(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":        `{{ block "title" . }}{{ end }}{{ template "content" . }}`,
+		"g/card.tmpl":         `{{ define "card" }}a{{ end }}`,
+		"g/other.tmpl":        "---\ntitle: other\n---\n\n{{ define \"card\" }}b{{ end }}",
+		"g/button.tmpl":       `{{ block "button" . }}{{ end }}`,
+		"a/index.layout.tmpl": `{{ define "title" }}A{{ end }}{{ define "content" }}{{ end }}{{ define "content" }}{{ end }}`,
+		"a/list.layout.tmpl":  `{{ define "content" }}{{ template "row" }}{{ end }}`,
+		"a/row.tmpl":          `{{ define "row" }}{{ end }}{{ define "button" }}{{ end }}`,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+
+	err = ctx.Scan()
+	var derr *DefineError
+	require.True(t, errors.As(err, &derr))
+	assert.Equal(t, []DefineConflict{{
+		Name:        "card",
+		Definitions: []Definition{{Name: "card", Template: "g/card.tmpl", Line: 1}, {Name: "card", Template: "g/other.tmpl", Line: 5}},
+		Targets:     []string{"a/index.layout.tmpl", "a/list.layout.tmpl"},
+	}}, derr.Conflicts)
+	assert.Contains(t, err.Error(), `"card" is defined in g/card.tmpl:1 and g/other.tmpl:5, the definition in g/other.tmpl:5 is used by a/index.layout.tmpl, a/list.layout.tmpl`)
+
+	// overriding blocks is intentional
+	conflicts, err := ctx.CheckDefines()
+	require.NoError(t, err)
+	overrides := make(map[string][]string)
+	for _, c := range conflicts {
+		if c.Override {
+			overrides[c.Name] = c.Targets
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"title":  {"a/index.layout.tmpl"},
+		"button": {"a/index.layout.tmpl", "a/list.layout.tmpl"},
+	}, overrides)
+}
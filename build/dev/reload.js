@@ -0,0 +1,38 @@
+// Reloads pages served by taevas serve when the site is rebuilt.  Stylesheets are swapped in place when only they
+// changed, so the page keeps its scroll position and state.
+(function () {
+  "use strict";
+
+  if (!window.EventSource) return;
+  var events = new EventSource("/_taevas/events");
+
+  events.addEventListener("reload", function () {
+    window.location.reload();
+  });
+
+  events.addEventListener("css", function (e) {
+    var changed = JSON.parse(e.data);
+    var links = [];
+    var all = document.querySelectorAll('link[rel="stylesheet"]');
+    for (var i = 0; i < all.length; i++) {
+      if (new URL(all[i].href, window.location.href).origin === window.location.origin) links.push(all[i]);
+    }
+    // stylesheets imported by another are found by swapping every stylesheet
+    var matched = links.filter(function (link) {
+      return changed.indexOf(new URL(link.href, window.location.href).pathname) >= 0;
+    });
+    (matched.length ? matched : links).forEach(swap);
+  });
+
+  // swap loads a fresh copy of a stylesheet next to it and removes the old one once it has loaded, so the page is
+  // never unstyled
+  function swap(link) {
+    var url = new URL(link.href, window.location.href);
+    url.searchParams.set("taevas-reload", Date.now());
+    var next = link.cloneNode();
+    next.href = url.toString();
+    next.addEventListener("load", function () { link.remove(); });
+    next.addEventListener("error", function () { next.remove(); });
+    link.parentNode.insertBefore(next, link.nextSibling);
+  }
+})();
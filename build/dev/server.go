@@ -0,0 +1,160 @@
+// Package dev serves a static build during development and reloads pages in the browser when it is rebuilt
+package dev
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// ScriptPath is the URL of the live reload script, which a build adds to every page with build.WithLiveReload
+	ScriptPath = "/_taevas/reload.js"
+	// EventsPath is the URL of the stream of server-sent events that tells pages to reload
+	EventsPath = "/_taevas/events"
+)
+
+// script reloads the page on a reload event and swaps stylesheets on a css event
+//
+//go:embed reload.js
+var script []byte
+
+// event is a server-sent event
+type event struct {
+	name string
+	data string
+}
+
+// Server serves the pages of a static build from its output directory and other files, such as stylesheets and
+// images, from the root of the site.  Templates, data files and hidden files are never served.  Pages load a script
+// that listens for reload events sent when the site changes.
+type Server struct {
+	out  string
+	root string
+	// isSource reports whether a file is a template or data file of the site
+	isSource func(name string) bool
+
+	mu      sync.Mutex
+	clients map[chan event]bool
+}
+
+// New returns a server for the static build in out of the site in root.  isSource reports whether a file name is a
+// template or data file that must not be served.
+func New(out string, root string, isSource func(name string) bool) *Server {
+	return &Server{
+		out:      out,
+		root:     root,
+		isSource: isSource,
+		clients:  make(map[chan event]bool),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// pages change on every rebuild, so the browser must not cache them
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.URL.Path {
+	case ScriptPath:
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		_, _ = w.Write(script)
+	case EventsPath:
+		s.events(w, r)
+	default:
+		s.file(w, r)
+	}
+}
+
+// file serves a page from the build, e.g. /about from about/index.html and /notes from notes.txt, or else a file from
+// the root
+func (s *Server) file(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + r.URL.Path)
+	if s.exists(s.out, p) || s.exists(s.out, path.Join(p, "index.html")) {
+		http.FileServer(http.Dir(s.out)).ServeHTTP(w, r)
+		return
+	}
+	if s.exists(s.out, p+".txt") {
+		http.ServeFile(w, r, filepath.Join(s.out, filepath.FromSlash(p+".txt")))
+		return
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if strings.HasPrefix(segment, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if s.isSource(path.Base(p)) || !s.exists(s.root, p) {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(s.root, filepath.FromSlash(p)))
+}
+
+// exists reports whether p is a file below dir
+func (s *Server) exists(dir string, p string) bool {
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p)))
+	return err == nil && !info.IsDir()
+}
+
+// events streams reload events to a page until it is closed
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan event, 8)
+	s.mu.Lock()
+	s.clients[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+			flusher.Flush()
+		}
+	}
+}
+
+// Changed tells every open page that files changed.  Pages reload, or only swap their stylesheets when every changed
+// file is a stylesheet.  Files are relative to the root, e.g. css/main.css.
+func (s *Server) Changed(files []string) {
+	urls := make([]string, 0, len(files))
+	for _, f := range files {
+		if path.Ext(f) != ".css" {
+			s.send(event{name: "reload", data: "{}"})
+			return
+		}
+		urls = append(urls, "/"+strings.TrimPrefix(filepath.ToSlash(f), "/"))
+	}
+	b, _ := json.Marshal(urls)
+	s.send(event{name: "css", data: string(b)})
+}
+
+// send queues an event for every page, dropping it for pages that have not read the previous ones
+func (s *Server) send(e event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package dev
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func TestServeFiles(t *testing.T) {
+	out, root := t.TempDir(), t.TempDir()
+	writeFiles(t, out, map[string]string{
+		"index.html":       "home",
+		"about/index.html": "about",
+		"notes.txt":        "notes",
+	})
+	writeFiles(t, root, map[string]string{
+		"css/main.css":      "body {}",
+		"index.layout.tmpl": "template",
+		"posts/a.md":        "post",
+		".env":              "secret",
+	})
+	isSource := func(name string) bool { return strings.HasSuffix(name, ".tmpl") || strings.HasSuffix(name, ".md") }
+	ts := httptest.NewServer(New(out, root, isSource))
+	defer ts.Close()
+
+	for path, expect := range map[string]string{
+		"/":             "home",
+		"/about/":       "about",
+		"/notes":        "notes",
+		"/css/main.css": "body {}",
+		ScriptPath:      string(script),
+	} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "failed for %s", path)
+		assert.Equal(t, expect, string(b), "failed for %s", path)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	}
+	for _, path := range []string{"/index.layout.tmpl", "/posts/a.md", "/.env", "/missing"} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "failed for %s", path)
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	s := New(t.TempDir(), t.TempDir(), func(string) bool { return false })
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + EventsPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	events := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := events.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, ": connected\n", readEvent())
+
+	s.Changed([]string{"css/main.css", "print.css"})
+	assert.Equal(t, "event: css\ndata: [\"/css/main.css\",\"/print.css\"]\n", readEvent())
+	s.Changed([]string{"css/main.css", "index.layout.tmpl"})
+	assert.Equal(t, "event: reload\ndata: {}\n", readEvent())
+}
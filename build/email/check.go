@@ -0,0 +1,130 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Issue is a construct in an email that email clients do not support
+type Issue struct {
+	Template string
+	// Line is 0 for issues with the email as a whole
+	Line    int
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Template, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", i.Template, i.Line, i.Message)
+}
+
+// unsupportedElements are removed or not rendered by most email clients
+var unsupportedElements = map[string]string{
+	"script": "scripts are removed by email clients",
+	"form":   "forms are not supported by most email clients",
+	"iframe": "frames are not supported by email clients",
+	"frame":  "frames are not supported by email clients",
+	"object": "embedded objects are not supported by email clients",
+	"embed":  "embedded objects are not supported by email clients",
+	"video":  "video is not supported by most email clients",
+	"audio":  "audio is not supported by most email clients",
+	"canvas": "canvas requires scripts, which are removed by email clients",
+	"svg":    "inline SVG is not supported by most email clients",
+}
+
+// urlAttributes hold URLs that must be absolute to resolve when the email is opened
+var urlAttributes = map[string]bool{"href": true, "src": true, "background": true}
+
+// absoluteSchemes are URL prefixes that resolve outside of a web page
+var absoluteSchemes = []string{"http://", "https://", "//", "mailto:", "tel:", "sms:", "cid:", "data:"}
+
+// Check reports elements, URLs and styles in the html part of an email that email clients block, ignore or render
+// differently than browsers.  It is run on the output of Inline, so stylesheets that could not be inlined are reported
+// as links.  URLs that start with a template action are assumed to be absolute.
+func Check(template string, src []byte) []Issue {
+	var issues []Issue
+	line := 1
+	report := func(format string, args ...interface{}) {
+		issues = append(issues, Issue{Template: template, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	inStyle := false
+	// the tokenizer never fails on a byte slice, it only reaches the end of the document
+	_ = eachToken(src, func(_ *html.Tokenizer, tt html.TokenType, t html.Token, raw []byte) error {
+		defer func() { line += bytes.Count(raw, []byte("\n")) }()
+		switch tt {
+		case html.TextToken:
+			if inStyle && bytes.Contains(bytes.ToLower(raw), []byte("@import")) {
+				report("@import is not supported by most email clients")
+			}
+			return nil
+		case html.EndTagToken:
+			if t.Data == "style" {
+				inStyle = false
+			}
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			return nil
+		}
+
+		if reason, ok := unsupportedElements[t.Data]; ok {
+			report("<%s>: %s", t.Data, reason)
+		}
+		if t.Data == "style" && tt == html.StartTagToken {
+			inStyle = true
+		}
+		if isStylesheet(t) {
+			report("external stylesheet %s is not loaded by most email clients", attr(t, "href"))
+		}
+		for _, a := range t.Attr {
+			switch {
+			case urlAttributes[a.Key]:
+				checkURL(a.Val, report)
+			case a.Key == "style":
+				checkStyle(a.Val, report)
+			case strings.HasPrefix(a.Key, "on"):
+				report("event handler %s is removed by email clients", a.Key)
+			}
+		}
+		return nil
+	})
+	return issues
+}
+
+func checkURL(u string, report func(format string, args ...interface{})) {
+	v := strings.ToLower(strings.TrimSpace(u))
+	switch {
+	case v == "" || strings.HasPrefix(v, "#") || strings.HasPrefix(v, "{{"):
+		return
+	case strings.HasPrefix(v, "javascript:"):
+		report("javascript: URLs are blocked by email clients")
+		return
+	}
+	for _, scheme := range absoluteSchemes {
+		if strings.HasPrefix(v, scheme) {
+			return
+		}
+	}
+	report("relative URL %s does not resolve in an email, use an absolute URL", u)
+}
+
+func checkStyle(style string, report func(format string, args ...interface{})) {
+	for _, d := range parseDeclarations(style) {
+		value := strings.ToLower(d.value)
+		switch {
+		case d.prop == "position" && value != "static":
+			report("position: %s is not supported by most email clients", d.value)
+		case d.prop == "display" && (strings.Contains(value, "flex") || strings.Contains(value, "grid")):
+			report("display: %s is not supported by most email clients, use tables for layout", d.value)
+		case strings.HasPrefix(d.prop, "grid") || strings.HasPrefix(d.prop, "flex"):
+			report("%s is not supported by most email clients, use tables for layout", d.prop)
+		case d.prop == "animation" || d.prop == "transition" || d.prop == "transform":
+			report("%s is not supported by most email clients", d.prop)
+		}
+	}
+}
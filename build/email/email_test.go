@@ -0,0 +1,188 @@
+package email
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const welcome = `<html>
+<head>
+<style>
+  /* comments are dropped */
+  p { color: #333; margin: 0 }
+  .button { background: #06c; color: white }
+  td.cell > a { text-decoration: none }
+  a:hover { color: red }
+  @media (max-width: 600px) { p { font-size: 18px } }
+</style>
+<link rel="stylesheet" href="email.css">
+</head>
+<body>
+<p class="lead" style="margin: 4px">Welcome {{ .Name }}</p>
+<table><tr><td class="cell"><a class="button" href="{{ .URL }}">Start</a></td></tr></table>
+<a href="https://example.com"><IMG SRC="https://example.com/logo.png"></a>
+</body>
+</html>`
+
+func TestInline(t *testing.T) {
+	load := func(href string) ([]byte, bool) {
+		if href != "email.css" {
+			return nil, false
+		}
+		return []byte(`#footer, .lead { font-family: "Helvetica Neue", sans-serif } img { border: 0 }`), true
+	}
+	out, err := Inline([]byte(welcome), load)
+	require.NoError(t, err)
+	s := string(out)
+
+	// declarations in the style attribute take precedence over the stylesheet
+	assert.Contains(t, s, `<p class="lead" style="color: #333; margin: 0; font-family: 'Helvetica Neue', sans-serif; margin: 4px">Welcome {{ .Name }}</p>`)
+	assert.Contains(t, s, `<a style="background: #06c; color: white; text-decoration: none" class="button" href="{{ .URL }}">Start</a>`)
+	assert.Contains(t, s, `<IMG style="border: 0" SRC="https://example.com/logo.png">`)
+	assert.Contains(t, s, `<a href="https://example.com">`)
+
+	// rules that cannot be inlined are kept in a single style element in place of the first stylesheet
+	assert.Contains(t, s, "<style>\na:hover { color: red }\n@media (max-width: 600px) { p { font-size: 18px } }\n</style>\n\n</head>")
+	assert.NotContains(t, s, "email.css")
+	assert.NotContains(t, s, "comments")
+}
+
+func TestParseSelector(t *testing.T) {
+	tt := []struct {
+		selector    string
+		ok          bool
+		specificity [3]int
+	}{
+		{selector: "p", ok: true, specificity: [3]int{0, 0, 1}},
+		{selector: "td.cell.wide", ok: true, specificity: [3]int{0, 2, 1}},
+		{selector: "#header .logo > img", ok: true, specificity: [3]int{1, 1, 1}},
+		{selector: "*", ok: true},
+		{selector: "a:hover"},
+		{selector: "input[type=text]"},
+		{selector: "h1 + p"},
+		{selector: "> p"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.selector, func(t *testing.T) {
+			sel, ok := parseSelector(tc.selector)
+			assert.Equal(t, tc.ok, ok)
+			if ok {
+				assert.Equal(t, tc.specificity, sel.specificity())
+			}
+		})
+	}
+
+	sel, _ := parseSelector("table .cell > a")
+	td := element{tag: "td", classes: []string{"cell"}}
+	a := element{tag: "a"}
+	assert.True(t, sel.matches([]element{{tag: "table"}, {tag: "tr"}, td, a}))
+	assert.False(t, sel.matches([]element{{tag: "table"}, td, {tag: "span"}, a}))
+	assert.False(t, sel.matches([]element{td, a}))
+}
+
+func TestCheck(t *testing.T) {
+	src := `<html>
+<link rel="stylesheet" href="https://example.com/email.css">
+<div style="display: flex; position: absolute">
+<script>alert(1)</script>
+<form action="/subscribe"><input name="email"></form>
+<a href="/account" onclick="track()">Account</a>
+<a href="javascript:void(0)">x</a>
+<img src="{{ .Logo }}"><a href="mailto:help@example.com">help</a><a href="#top">top</a>
+</div>
+</html>`
+	var messages []string
+	for _, i := range Check("mail/welcome.email.html.tmpl", []byte(src)) {
+		messages = append(messages, i.String())
+	}
+	assert.Equal(t, []string{
+		"mail/welcome.email.html.tmpl:2: external stylesheet https://example.com/email.css is not loaded by most email clients",
+		"mail/welcome.email.html.tmpl:3: display: flex is not supported by most email clients, use tables for layout",
+		"mail/welcome.email.html.tmpl:3: position: absolute is not supported by most email clients",
+		"mail/welcome.email.html.tmpl:4: <script>: scripts are removed by email clients",
+		"mail/welcome.email.html.tmpl:5: <form>: forms are not supported by most email clients",
+		"mail/welcome.email.html.tmpl:6: relative URL /account does not resolve in an email, use an absolute URL",
+		"mail/welcome.email.html.tmpl:6: event handler onclick is removed by email clients",
+		"mail/welcome.email.html.tmpl:7: javascript: URLs are blocked by email clients",
+	}, messages)
+}
+
+func TestGenerateAll(t *testing.T) {
+	in, err := fs.New("/test")
+	require.NoError(t, err)
+	out, err := fs.New("/out")
+	require.NoError(t, err)
+
+	for path, content := range map[string]string{
+		"mail/welcome.email.html.tmpl":       `<style>p { color: #333 }</style><p>Hi {{ .Name }}, <a href="/start">start</a></p>`,
+		"mail/welcome.email.txt.tmpl":        "Hi {{ .Name }}",
+		"mail/receipt.email.html.tmpl":       `<p>{{ .Total }}</p>`,
+		"mail/password-reset.email.txt.tmpl": "Reset at {{ .URL }}",
+		"a/index.base.tmpl":                  `<p>not an email</p>`,
+	} {
+		id, err := in.AddVirtual(path, []byte(content))
+		require.NoError(t, err)
+		if path == "mail/welcome.email.html.tmpl" {
+			require.NoError(t, in.SetMetadata(id, 0, fs.Metadata{"subject": "Welcome\n {{ .Name }}"}))
+		}
+	}
+
+	issues, err := GenerateAll(in, out)
+	require.NoError(t, err)
+	var messages []string
+	for _, i := range issues {
+		messages = append(messages, i.String())
+	}
+	assert.Equal(t, []string{
+		"mail/password-reset.email.txt.tmpl: email has no subject in its front matter",
+		"mail/receipt.email.html.tmpl: email has no subject in its front matter",
+		"mail/receipt.email.html.tmpl: email has no plain text part, which spam filters penalize",
+		"mail/welcome.email.html.tmpl:1: relative URL /start does not resolve in an email, use an absolute URL",
+	}, messages)
+
+	src, err := out.ReadFile("mail/" + OutputFile)
+	require.NoError(t, err)
+	typeCheck(t, "mail", src)
+	assert.Contains(t, string(src), "package mail")
+	assert.Contains(t, string(src), "func RenderWelcomeEmail(data interface{}) (subject, text, html string, err error)")
+	assert.Contains(t, string(src), "return RenderWelcomeEmailIn(i18n.DefaultLocale, data)")
+	assert.Contains(t, string(src), "func RenderWelcomeEmailIn(locale string, data interface{}) (subject, text, html string, err error)")
+	assert.Contains(t, string(src), "funcs := i18n.Funcs(locale)")
+	assert.Contains(t, string(src), "func RenderPasswordResetEmail(")
+	assert.Contains(t, string(src), "func RenderReceiptEmail(")
+	assert.Contains(t, string(src), `"<p style=\"color: #333\">Hi {{ .Name }}`)
+
+	_, err = out.ReadFile("a/" + OutputFile)
+	assert.Error(t, err)
+}
+
+func TestGenerateTextOnly(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "mail", []Email{{Name: "reset", TextTemplate: "mail/reset.email.txt.tmpl", Text: "{{ .URL }}"}}))
+	assert.NotContains(t, buf.String(), "html/template")
+
+	// names that differ only in punctuation would generate the same function
+	assert.Error(t, Generate(&buf, "mail", []Email{{Name: "a-b", TextTemplate: "a"}, {Name: "a_b", TextTemplate: "b"}}))
+}
+
+// sources imports packages from source for typeCheck, caching them between tests
+var sources = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck fails the test unless the generated source of package pkg compiles
+func typeCheck(t *testing.T, pkg string, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, OutputFile, src, 0)
+	require.NoError(t, err)
+	conf := types.Config{Importer: sources}
+	_, err = conf.Check(pkg, fset, []*ast.File{f}, nil)
+	require.NoError(t, err)
+}
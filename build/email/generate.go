@@ -0,0 +1,197 @@
+package email
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	htmltemplate "html/template"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/utils"
+)
+
+//go:embed email.go.tmpl
+var emailTmpl string
+
+// OutputFile is the name of the generated file written to each package with emails
+const OutputFile = "emails_gen.go"
+
+var tmpl = template.Must(template.New("email").Parse(emailTmpl))
+
+// Email is an email template pair ready to be generated.  HTML is the html part with its stylesheets inlined.
+type Email struct {
+	Name         string
+	Subject      string
+	HTMLTemplate string
+	TextTemplate string
+	HTML         string
+	Text         string
+}
+
+// Func returns the name of the generated render function, e.g. welcome -> RenderWelcomeEmail
+func (e Email) Func() string {
+	return "Render" + utils.Camel(e.Name, "E") + "Email"
+}
+
+// Var returns the prefix of the unexported variables holding the parsed templates
+func (e Email) Var() string {
+	t := []rune(utils.Camel(e.Name, "E"))
+	t[0] = unicode.ToLower(t[0])
+	return string(t) + "Email"
+}
+
+// Parse reads both parts of an email from the filesystem, inlines the stylesheets of the html part and checks it for
+// constructs that email clients do not support.  The subject is the subject key in the front matter of the html part,
+// or of the text part, and is itself a text template executed with the same data.
+func Parse(f *fs.Filesystem, e fs.Email) (Email, []Issue, error) {
+	out := Email{Name: e.Name, HTMLTemplate: e.HTML, TextTemplate: e.Text}
+	var issues []Issue
+	for _, part := range []string{e.HTML, e.Text} {
+		if part == "" {
+			continue
+		}
+		m, err := f.Metadata(part)
+		if err != nil {
+			return Email{}, nil, err
+		}
+		if out.Subject = m.Get("subject", ""); out.Subject != "" {
+			break
+		}
+	}
+	first := e.HTML
+	if first == "" {
+		first = e.Text
+	}
+	if out.Subject == "" {
+		issues = append(issues, Issue{Template: first, Message: "email has no subject in its front matter"})
+	}
+	if _, err := template.New("subject").Parse(out.Subject); err != nil {
+		return Email{}, nil, fmt.Errorf("failed to parse subject of %s: %w", first, err)
+	}
+
+	if e.Text != "" {
+		src, err := f.ReadFile(e.Text)
+		if err != nil {
+			return Email{}, nil, err
+		}
+		if _, err := template.New(e.Name).Parse(string(src)); err != nil {
+			return Email{}, nil, fmt.Errorf("failed to parse %s: %w", e.Text, err)
+		}
+		out.Text = string(src)
+	} else {
+		issues = append(issues, Issue{Template: e.HTML, Message: "email has no plain text part, which spam filters penalize"})
+	}
+
+	if e.HTML != "" {
+		src, err := f.ReadFile(e.HTML)
+		if err != nil {
+			return Email{}, nil, err
+		}
+		inlined, err := Inline(src, loader(f, e.Dir))
+		if err != nil {
+			return Email{}, nil, fmt.Errorf("failed to inline stylesheets of %s: %w", e.HTML, err)
+		}
+		if _, err := htmltemplate.New(e.Name).Parse(string(inlined)); err != nil {
+			return Email{}, nil, fmt.Errorf("failed to parse %s: %w", e.HTML, err)
+		}
+		out.HTML = string(inlined)
+		issues = append(issues, Check(e.HTML, inlined)...)
+	}
+	return out, issues, nil
+}
+
+// loader reads linked stylesheets from the filesystem.  Relative links resolve against the directory of the email
+// and links starting with / against the module root.
+func loader(f *fs.Filesystem, dir string) Loader {
+	return func(href string) ([]byte, bool) {
+		if href == "" || strings.Contains(href, "://") || strings.HasPrefix(href, "//") || strings.Contains(href, "{{") {
+			return nil, false
+		}
+		p := path.Join(dir, href)
+		if strings.HasPrefix(href, "/") {
+			p = path.Clean(strings.TrimPrefix(href, "/"))
+		}
+		if !f.Exists(p) {
+			return nil, false
+		}
+		b, err := f.ReadFile(p)
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	}
+}
+
+// Generate writes the Go source for the render functions of the emails of a single package
+func Generate(w io.Writer, pkg string, emails []Email) error {
+	seen := make(map[string]string)
+	html := false
+	for _, e := range emails {
+		source := e.HTMLTemplate
+		if source == "" {
+			source = e.TextTemplate
+		}
+		if other, ok := seen[e.Func()]; ok {
+			return fmt.Errorf("email %s in %s conflicts with email of the same name in %s", e.Func(), source, other)
+		}
+		seen[e.Func()] = source
+		html = html || e.HTMLTemplate != ""
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"HTML":    html,
+		"Emails":  emails,
+	}); err != nil {
+		return fmt.Errorf("failed to generate emails for package %s: %w", pkg, err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated emails for package %s: %w", pkg, err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateAll parses every email of the input filesystem and writes one generated file per directory with emails to
+// the output filesystem.  Issues do not prevent generation and are returned for every email.
+func GenerateAll(in *fs.Filesystem, out *fs.Filesystem) ([]Issue, error) {
+	pairs, err := in.Emails()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	byDir := make(map[string][]Email)
+	var dirs []string
+	for _, pair := range pairs {
+		e, i, err := Parse(in, pair)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, i...)
+		dir := utils.NewPath(pair.Dir, "").PackageDir()
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], e)
+	}
+
+	for _, dir := range dirs {
+		var buf bytes.Buffer
+		if err := Generate(&buf, utils.NewPath(dir, "").PackageName(), byDir[dir]); err != nil {
+			return nil, err
+		}
+		if _, err := out.AddVirtual(utils.NewPath(dir, OutputFile).String(), buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to write generated emails for %s: %w", dir, err)
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,425 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// declaration is a single CSS property and value
+type declaration struct {
+	prop  string
+	value string
+}
+
+// compound is a simple selector such as td.cell or #header that matches a single element
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// selector is a chain of compounds separated by descendant (' ') or child ('>') combinators
+type selector struct {
+	parts       []compound
+	combinators []byte
+}
+
+// rule is a style rule whose selector can be inlined.  Order is the position of the rule in the stylesheets, which
+// breaks ties in specificity.
+type rule struct {
+	selector    selector
+	specificity [3]int
+	order       int
+	decls       []declaration
+}
+
+// element is an open element in the document being inlined
+type element struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// voidElements never have an end tag and are never ancestors of another element
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+var (
+	commentRE    = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	compoundRE   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*|\*)?((?:[.#][a-zA-Z_-][\w-]*)*)$`)
+	styleAttrRE  = regexp.MustCompile(`(?i)\sstyle\s*=\s*("[^"]*"|'[^']*')`)
+	simpleNameRE = regexp.MustCompile(`[.#][\w-]+`)
+)
+
+// Loader returns the content of the stylesheet linked from an email by href.  It returns false when the stylesheet
+// cannot be inlined, such as one on another host, and the link is left in the email.
+type Loader func(href string) ([]byte, bool)
+
+// Inline moves the rules of every <style> element and every linked stylesheet the loader can read into the style
+// attribute of the elements they match.  Declarations already in a style attribute take precedence.  Rules that
+// cannot be inlined, such as those with pseudo-classes, attribute selectors or inside @media, are kept in a single
+// <style> element in place of the first stylesheet.  The template source is otherwise copied unchanged, so classes
+// and ids set by template actions are not matched.
+func Inline(src []byte, load Loader) ([]byte, error) {
+	var rules []rule
+	var retained strings.Builder
+	if err := eachToken(src, func(z *html.Tokenizer, tt html.TokenType, t html.Token, _ []byte) error {
+		switch {
+		case tt == html.StartTagToken && t.Data == "style":
+			if z.Next() == html.TextToken {
+				rules = parseStylesheet(string(z.Raw()), rules, &retained)
+			}
+		case (tt == html.StartTagToken || tt == html.SelfClosingTagToken) && isStylesheet(t):
+			if css, ok := load(attr(t, "href")); ok {
+				rules = parseStylesheet(string(css), rules, &retained)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.specificity != b.specificity {
+			for k := range a.specificity {
+				if a.specificity[k] != b.specificity[k] {
+					return a.specificity[k] < b.specificity[k]
+				}
+			}
+		}
+		return a.order < b.order
+	})
+
+	var out bytes.Buffer
+	var stack []element
+	replaced := false
+	// replace drops a stylesheet, writing the rules that could not be inlined in place of the first one
+	replace := func() {
+		if !replaced && retained.Len() > 0 {
+			out.WriteString("<style>\n" + retained.String() + "</style>")
+		}
+		replaced = true
+	}
+	skipStyle := false
+	err := eachToken(src, func(_ *html.Tokenizer, tt html.TokenType, t html.Token, raw []byte) error {
+		switch {
+		case skipStyle:
+			if tt == html.EndTagToken && t.Data == "style" {
+				skipStyle = false
+			}
+			return nil
+		case tt == html.StartTagToken && t.Data == "style":
+			skipStyle = true
+			replace()
+			return nil
+		case (tt == html.StartTagToken || tt == html.SelfClosingTagToken) && isStylesheet(t):
+			if _, ok := load(attr(t, "href")); ok {
+				replace()
+				return nil
+			}
+		case tt == html.StartTagToken || tt == html.SelfClosingTagToken:
+			e := element{tag: t.Data, id: attr(t, "id"), classes: strings.Fields(attr(t, "class"))}
+			stack = append(stack, e)
+			raw = inlineStyle(raw, t, stack, rules)
+			if tt == html.SelfClosingTagToken || voidElements[t.Data] {
+				stack = stack[:len(stack)-1]
+			}
+		case tt == html.EndTagToken:
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == t.Data {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+		out.Write(raw)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// eachToken calls fn for every token in src with a copy of its raw source until the end of the document
+func eachToken(src []byte, fn func(z *html.Tokenizer, tt html.TokenType, t html.Token, raw []byte) error) error {
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to tokenize html: %w", z.Err())
+		}
+		// reading the tag lower cases its name and attribute keys in place, which must not change template actions
+		// in the copy of the source
+		raw := append([]byte(nil), z.Raw()...)
+		t := html.Token{Type: tt}
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken || tt == html.EndTagToken {
+			name, hasAttr := z.TagName()
+			t.Data = string(name)
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				t.Attr = append(t.Attr, html.Attribute{Key: string(k), Val: string(v)})
+			}
+		}
+		if err := fn(z, tt, t, raw); err != nil {
+			return err
+		}
+	}
+}
+
+func attr(t html.Token, key string) string {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isStylesheet(t html.Token) bool {
+	return t.Data == "link" && strings.EqualFold(attr(t, "rel"), "stylesheet")
+}
+
+// inlineStyle returns the raw start tag with the declarations of every matching rule added to its style attribute
+func inlineStyle(raw []byte, t html.Token, stack []element, rules []rule) []byte {
+	var decls []declaration
+	for _, r := range rules {
+		if r.selector.matches(stack) {
+			decls = append(decls, r.decls...)
+		}
+	}
+	if len(decls) == 0 {
+		return raw
+	}
+
+	// later declarations of a property override earlier ones
+	last := make(map[string]int, len(decls))
+	for i, d := range decls {
+		last[d.prop] = i
+	}
+	var parts []string
+	for i, d := range decls {
+		if last[d.prop] == i {
+			parts = append(parts, d.prop+": "+strings.ReplaceAll(d.value, `"`, "'"))
+		}
+	}
+	style := strings.Join(parts, "; ")
+
+	out := make([]byte, 0, len(raw)+len(style)+10)
+	if loc := styleAttrRE.FindSubmatchIndex(raw); loc != nil {
+		existing := strings.TrimSpace(string(raw[loc[2]+1 : loc[3]-1]))
+		quote := raw[loc[2]]
+		out = append(out, raw[:loc[2]]...)
+		out = append(out, quote)
+		out = append(out, style...)
+		if existing != "" {
+			out = append(out, "; "+existing...)
+		}
+		out = append(out, quote)
+		return append(out, raw[loc[3]:]...)
+	}
+	// the tag name is the same length in the raw source as in the token
+	end := 1 + len(t.Data)
+	out = append(out, raw[:end]...)
+	out = append(out, ` style="`+style+`"`...)
+	return append(out, raw[end:]...)
+}
+
+// parseStylesheet appends the rules of css that can be inlined to rules and writes the rest to retained
+func parseStylesheet(css string, rules []rule, retained *strings.Builder) []rule {
+	css = commentRE.ReplaceAllString(css, "")
+	for {
+		css = strings.TrimSpace(css)
+		open := strings.Index(css, "{")
+		if open < 0 {
+			if css != "" {
+				retained.WriteString(css + "\n")
+			}
+			return rules
+		}
+		prelude := strings.TrimSpace(css[:open])
+
+		// statements such as @import end at a semicolon before any block
+		if strings.HasPrefix(prelude, "@") {
+			if semi := strings.Index(prelude, ";"); semi >= 0 {
+				retained.WriteString(prelude[:semi+1] + "\n")
+				css = css[strings.Index(css, ";")+1:]
+				continue
+			}
+		}
+		close := matchingBrace(css, open)
+		body := css[open+1 : close]
+		css = css[min(close+1, len(css)):]
+		if strings.HasPrefix(prelude, "@") {
+			retained.WriteString(prelude + " {" + body + "}\n")
+			continue
+		}
+
+		decls := parseDeclarations(body)
+		var unsupported []string
+		for _, s := range strings.Split(prelude, ",") {
+			s = strings.TrimSpace(s)
+			sel, ok := parseSelector(s)
+			if !ok {
+				unsupported = append(unsupported, s)
+				continue
+			}
+			rules = append(rules, rule{selector: sel, specificity: sel.specificity(), order: len(rules), decls: decls})
+		}
+		if len(unsupported) > 0 {
+			retained.WriteString(strings.Join(unsupported, ", ") + " {" + body + "}\n")
+		}
+	}
+}
+
+// matchingBrace returns the index of the brace closing the block opened at open, or the end of css when it is not closed
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func parseDeclarations(body string) []declaration {
+	var decls []declaration
+	for _, d := range strings.Split(body, ";") {
+		colon := strings.Index(d, ":")
+		if colon < 0 {
+			continue
+		}
+		prop := strings.ToLower(strings.TrimSpace(d[:colon]))
+		value := strings.TrimSpace(d[colon+1:])
+		if prop == "" || value == "" {
+			continue
+		}
+		decls = append(decls, declaration{prop: prop, value: value})
+	}
+	return decls
+}
+
+// parseSelector parses selectors made of type, class and id selectors joined by descendant and child combinators.  It
+// returns false for any other selector.
+func parseSelector(s string) (selector, bool) {
+	var sel selector
+	combinator := byte(' ')
+	for _, field := range strings.Fields(strings.ReplaceAll(s, ">", " > ")) {
+		if field == ">" {
+			if len(sel.parts) == 0 || combinator == '>' {
+				return selector{}, false
+			}
+			combinator = '>'
+			continue
+		}
+		m := compoundRE.FindStringSubmatch(field)
+		if m == nil {
+			return selector{}, false
+		}
+		c := compound{tag: strings.ToLower(m[1])}
+		for _, name := range simpleNameRE.FindAllString(m[2], -1) {
+			switch name[0] {
+			case '#':
+				c.id = name[1:]
+			default:
+				c.classes = append(c.classes, name[1:])
+			}
+		}
+		if len(sel.parts) > 0 {
+			sel.combinators = append(sel.combinators, combinator)
+		}
+		sel.parts = append(sel.parts, c)
+		combinator = ' '
+	}
+	if len(sel.parts) == 0 || combinator == '>' {
+		return selector{}, false
+	}
+	return sel, true
+}
+
+// specificity counts the ids, classes and types in the selector
+func (s selector) specificity() [3]int {
+	var n [3]int
+	for _, c := range s.parts {
+		if c.id != "" {
+			n[0]++
+		}
+		n[1] += len(c.classes)
+		if c.tag != "" && c.tag != "*" {
+			n[2]++
+		}
+	}
+	return n
+}
+
+// matches reports whether the selector matches the last element of the stack of open elements
+func (s selector) matches(stack []element) bool {
+	return matchFrom(s, len(s.parts)-1, stack)
+}
+
+func matchFrom(s selector, part int, stack []element) bool {
+	if len(stack) == 0 || !s.parts[part].matches(stack[len(stack)-1]) {
+		return false
+	}
+	if part == 0 {
+		return true
+	}
+	ancestors := stack[:len(stack)-1]
+	if s.combinators[part-1] == '>' {
+		return matchFrom(s, part-1, ancestors)
+	}
+	for i := len(ancestors); i > 0; i-- {
+		if matchFrom(s, part-1, ancestors[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(e element) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != e.tag {
+		return false
+	}
+	if c.id != "" && c.id != e.id {
+		return false
+	}
+	for _, class := range c.classes {
+		found := false
+		for _, ec := range e.classes {
+			if ec == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,356 @@
+package form
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BTBurke/taevas/utils"
+	"golang.org/x/net/html"
+)
+
+// Kind is the Go type a form field is decoded into
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Float
+	Bool
+	Strings
+)
+
+// GoType returns the Go type used in the generated struct
+func (k Kind) GoType() string {
+	switch k {
+	case Int:
+		return "int"
+	case Float:
+		return "float64"
+	case Bool:
+		return "bool"
+	case Strings:
+		return "[]string"
+	default:
+		return "string"
+	}
+}
+
+// Field is a single named input, textarea or select in a form along with the constraints declared in the markup
+type Field struct {
+	Name      string
+	Type      string
+	Kind      Kind
+	Required  bool
+	Min       string
+	Max       string
+	Pattern   string
+	MinLength int
+	MaxLength int
+	Options   []string
+}
+
+// GoName returns the exported struct field name for the field
+func (f Field) GoName() string {
+	return utils.Camel(f.Name, "F")
+}
+
+// Form is a single <form> element parsed from a template
+type Form struct {
+	Name     string
+	Template string
+	Method   string
+	Action   string
+	Fields   []Field
+}
+
+// Type returns the name of the generated struct, e.g. signup -> SignupForm
+func (f Form) Type() string {
+	name := utils.Camel(f.Name, "F")
+	if strings.HasSuffix(name, "Form") {
+		return name
+	}
+	return name + "Form"
+}
+
+// input types that never carry a value that should be decoded
+var skipTypes = map[string]bool{
+	"submit": true,
+	"button": true,
+	"reset":  true,
+	"image":  true,
+	"file":   true,
+}
+
+// Parse finds all forms in a template.  Forms are named by their name or id attribute, falling back to
+// the first segment of the template file name.  Fields with dynamic names (containing template actions)
+// are skipped because their name can't be known at compile time.
+func Parse(template string, r io.Reader) ([]Form, error) {
+	var forms []Form
+	var current *Form
+	var option *string
+	optionParent := -1
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return nameForms(template, forms), nil
+			}
+			return nil, fmt.Errorf("failed to parse forms in %s: %w", template, z.Err())
+		case html.TextToken:
+			if option != nil && *option == "" {
+				*option = strings.TrimSpace(string(z.Text()))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			attrs := readAttrs(z)
+
+			if tt == html.EndTagToken {
+				switch {
+				case tag == "form" && current != nil:
+					if err := checkGoNames(*current); err != nil {
+						return nil, fmt.Errorf("failed to parse forms in %s: %w", template, err)
+					}
+					forms = append(forms, *current)
+					current = nil
+				case tag == "option" && option != nil:
+					addOption(current, optionParent, *option)
+					option = nil
+				case tag == "select":
+					if option != nil {
+						addOption(current, optionParent, *option)
+						option = nil
+					}
+					optionParent = -1
+				}
+				continue
+			}
+
+			if tag == "form" {
+				current = &Form{
+					Name:     firstAttr(attrs, "name", "id"),
+					Template: template,
+					Method:   strings.ToUpper(attrs["method"]),
+					Action:   attrs["action"],
+				}
+				continue
+			}
+			if current == nil {
+				continue
+			}
+
+			switch tag {
+			case "input", "textarea", "select":
+				if tag == "select" && option != nil {
+					option = nil
+				}
+				fname := attrs["name"]
+				if fname == "" || strings.Contains(fname, "{{") {
+					continue
+				}
+				ftype := strings.ToLower(attrs["type"])
+				switch tag {
+				case "textarea", "select":
+					ftype = tag
+				case "input":
+					if ftype == "" {
+						ftype = "text"
+					}
+				}
+				if skipTypes[ftype] {
+					continue
+				}
+				idx, err := addField(current, fname, ftype, attrs)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse form field %s in %s: %w", fname, template, err)
+				}
+				if tag == "select" {
+					optionParent = idx
+				}
+			case "option":
+				if option != nil {
+					addOption(current, optionParent, *option)
+				}
+				v, ok := attrs["value"]
+				option = &v
+				if ok && v == "" {
+					// explicit empty value is a placeholder and never a valid choice
+					option = nil
+				}
+			}
+		}
+	}
+}
+
+// addField records a field on the form, merging repeated names for radio and checkbox groups.  It returns
+// the index of the field.
+func addField(f *Form, name string, ftype string, attrs map[string]string) (int, error) {
+	_, required := attrs["required"]
+
+	for i, existing := range f.Fields {
+		if existing.Name != name {
+			continue
+		}
+		switch {
+		case ftype == "radio" && existing.Type == "radio":
+			f.Fields[i].Required = existing.Required || required
+			if v, ok := attrs["value"]; ok {
+				f.Fields[i].Options = append(f.Fields[i].Options, v)
+			}
+		case ftype == "checkbox" && existing.Type == "checkbox":
+			// multiple checkboxes with the same name submit a list of values
+			f.Fields[i].Kind = Strings
+			f.Fields[i].Required = existing.Required || required
+			if v, ok := attrs["value"]; ok {
+				f.Fields[i].Options = append(f.Fields[i].Options, v)
+			}
+		default:
+			return -1, fmt.Errorf("field declared more than once with conflicting types %s and %s", existing.Type, ftype)
+		}
+		return i, nil
+	}
+
+	field := Field{
+		Name:     name,
+		Type:     ftype,
+		Required: required,
+		Pattern:  attrs["pattern"],
+	}
+	var err error
+	if field.MaxLength, err = intAttr(attrs, "maxlength"); err != nil {
+		return -1, err
+	}
+	if field.MinLength, err = intAttr(attrs, "minlength"); err != nil {
+		return -1, err
+	}
+
+	switch ftype {
+	case "number", "range":
+		field.Kind = Int
+		for _, key := range []string{"step", "min", "max"} {
+			v := attrs[key]
+			if v == "" {
+				continue
+			}
+			if v == "any" && key == "step" {
+				field.Kind = Float
+				continue
+			}
+			if n, err := strconv.ParseFloat(v, 64); err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+				return -1, fmt.Errorf("%s must be a finite number for type %s, got %q", key, ftype, v)
+			}
+			if _, err := strconv.Atoi(v); err != nil {
+				field.Kind = Float
+			}
+		}
+		// bounds are emitted as Go constants, so they are normalized rather than copied, e.g. 010 is not octal
+		field.Min, field.Max = number(attrs["min"], field.Kind), number(attrs["max"], field.Kind)
+	case "date", "time", "datetime-local", "month", "week":
+		field.Min, field.Max = attrs["min"], attrs["max"]
+	case "checkbox":
+		// options are only used if this turns out to be a checkbox group
+		field.Kind = Bool
+		fallthrough
+	case "radio":
+		if v, ok := attrs["value"]; ok {
+			field.Options = []string{v}
+		}
+	case "select":
+		if _, multiple := attrs["multiple"]; multiple {
+			field.Kind = Strings
+		}
+	}
+	f.Fields = append(f.Fields, field)
+	return len(f.Fields) - 1, nil
+}
+
+// number returns a valid number attribute as a Go literal of the kind of its field
+func number(v string, kind Kind) string {
+	if v == "" {
+		return ""
+	}
+	if kind == Int {
+		n, _ := strconv.Atoi(v)
+		return strconv.Itoa(n)
+	}
+	n, _ := strconv.ParseFloat(v, 64)
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func addOption(f *Form, idx int, value string) {
+	if f == nil || idx < 0 || idx >= len(f.Fields) || strings.Contains(value, "{{") {
+		return
+	}
+	f.Fields[idx].Options = append(f.Fields[idx].Options, value)
+}
+
+// checkGoNames returns an error when two fields of a form map to the same struct field, e.g. first-name and
+// first_name, which would not compile
+func checkGoNames(f Form) error {
+	names := make(map[string]string, len(f.Fields))
+	for _, field := range f.Fields {
+		if other, ok := names[field.GoName()]; ok {
+			return fmt.Errorf("fields %s and %s both decode into %s", other, field.Name, field.GoName())
+		}
+		names[field.GoName()] = field.Name
+	}
+	return nil
+}
+
+// nameForms assigns a name to any unnamed forms based on the template file name
+func nameForms(template string, forms []Form) []Form {
+	base := strings.SplitN(filepath.Base(template), ".", 2)[0]
+	unnamed := 0
+	for i := range forms {
+		if forms[i].Name != "" {
+			continue
+		}
+		forms[i].Name = base
+		if unnamed > 0 {
+			forms[i].Name = base + strconv.Itoa(unnamed+1)
+		}
+		unnamed++
+	}
+	return forms
+}
+
+func readAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		k, v, more := z.TagAttr()
+		if len(k) > 0 {
+			attrs[strings.ToLower(string(k))] = string(v)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+func firstAttr(attrs map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := attrs[k]; v != "" && !strings.Contains(v, "{{") {
+			return v
+		}
+	}
+	return ""
+}
+
+func intAttr(attrs map[string]string, key string) (int, error) {
+	v, ok := attrs[key]
+	if !ok || v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return i, nil
+}
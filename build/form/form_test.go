@@ -0,0 +1,185 @@
+package form
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const signup = `{{ define "content" }}
+<form id="signup" method="post" action="/signup">
+  <input type="email" name="email" required value="{{ .Email }}">
+  <input name="user_name" minlength="3" maxlength="20" pattern="[a-z0-9]+">
+  <input type="number" name="age" min="018" max="120">
+  <input type="number" name="rank" min="010" max="1e2">
+  <input type="number" name="score" step="0.5">
+  <input type="date" name="start" min="2020-01-01">
+  <select name="plan" required>
+    <option value="">Choose a plan</option>
+    <option value="free">Free</option>
+    <option>pro</option>
+  </select>
+  <select name="tags" multiple><option>a</option><option>b</option></select>
+  <input type="radio" name="color" value="red" required>
+  <input type="radio" name="color" value="blue">
+  <input type="checkbox" name="agree" required>
+  <input type="checkbox" name="topics" value="go">
+  <input type="checkbox" name="topics" value="sql">
+  <input type="{{ .Type }}" name="{{ .Dynamic }}">
+  <textarea name="bio" maxlength="200"></textarea>
+  <input type="submit" value="Sign up">
+</form>
+{{ end }}`
+
+func TestParse(t *testing.T) {
+	forms, err := Parse("a/signup.layout.tmpl", strings.NewReader(signup))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(forms))
+
+	f := forms[0]
+	assert.Equal(t, "SignupForm", f.Type())
+	assert.Equal(t, "POST", f.Method)
+	assert.Equal(t, "/signup", f.Action)
+
+	expect := []Field{
+		{Name: "email", Type: "email", Kind: String, Required: true},
+		{Name: "user_name", Type: "text", Kind: String, MinLength: 3, MaxLength: 20, Pattern: "[a-z0-9]+"},
+		{Name: "age", Type: "number", Kind: Int, Min: "18", Max: "120"},
+		{Name: "rank", Type: "number", Kind: Float, Min: "10", Max: "100"},
+		{Name: "score", Type: "number", Kind: Float},
+		{Name: "start", Type: "date", Kind: String, Min: "2020-01-01"},
+		{Name: "plan", Type: "select", Kind: String, Required: true, Options: []string{"free", "pro"}},
+		{Name: "tags", Type: "select", Kind: Strings, Options: []string{"a", "b"}},
+		{Name: "color", Type: "radio", Kind: String, Required: true, Options: []string{"red", "blue"}},
+		{Name: "agree", Type: "checkbox", Kind: Bool, Required: true},
+		{Name: "topics", Type: "checkbox", Kind: Strings, Options: []string{"go", "sql"}},
+		{Name: "bio", Type: "textarea", Kind: String, MaxLength: 200},
+	}
+	assert.Equal(t, expect, f.Fields)
+	assert.Equal(t, "UserName", f.Fields[1].GoName())
+}
+
+func TestParseNaming(t *testing.T) {
+	in := `<form><input name="q"></form><form name="contact-form"></form><form></form>`
+	forms, err := Parse("search.layout.tmpl", strings.NewReader(in))
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range forms {
+		names = append(names, f.Type())
+	}
+	assert.Equal(t, []string{"SearchForm", "ContactForm", "Search2Form"}, names)
+}
+
+func TestParseConflictingField(t *testing.T) {
+	in := `<form><input name="q"><input type="number" name="q"></form>`
+	_, err := Parse("search.layout.tmpl", strings.NewReader(in))
+	assert.Error(t, err)
+
+	// names that differ only in punctuation would declare the same struct field twice
+	in = `<form><input name="first-name"><input name="first_name"></form>`
+	_, err = Parse("search.layout.tmpl", strings.NewReader(in))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fields first-name and first_name both decode into FirstName")
+
+	for _, attr := range []string{`min="Inf"`, `max="NaN"`, `step="x"`, `min="any"`, `max="1e400"`} {
+		in = `<form><input type="number" name="n" ` + attr + `></form>`
+		_, err = Parse("search.layout.tmpl", strings.NewReader(in))
+		assert.Error(t, err, "failed for %s", attr)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	forms, err := Parse("a/signup.layout.tmpl", strings.NewReader(signup))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "a", forms))
+
+	// generated code should compile
+	typeCheck(t, "a", buf.Bytes())
+
+	src := buf.String()
+	for _, s := range []string{
+		"package a",
+		"type SignupForm struct",
+		"func DecodeSignupForm(r *http.Request) (SignupForm, ValidationErrors)",
+		"UserName string   `form:\"user_name\"`",
+		"Age      int      `form:\"age\"`",
+		"if n < 18 {",
+		"if n > 100 {",
+		"Tags     []string `form:\"tags\"`",
+		`var signupFormUserNamePattern = regexp.MustCompile("^(?:[a-z0-9]+)$")`,
+		`"net/mail"`,
+		`case "free", "pro":`,
+	} {
+		assert.Contains(t, src, s)
+	}
+
+	// patterns must be valid RE2 expressions
+	forms[0].Fields[1].Pattern = "(?<=a)b"
+	assert.Error(t, Generate(&buf, "a", forms))
+
+	// duplicate form names in the same package would not compile
+	assert.Error(t, Generate(&buf, "a", append(forms, forms[0])))
+}
+
+func TestGenerateAll(t *testing.T) {
+	in, err := fs.New("/test")
+	require.NoError(t, err)
+	out, err := fs.New("/out")
+	require.NoError(t, err)
+
+	for path, content := range map[string]string{
+		"_layout.tmpl":                `<html>{{ template "content" . }}</html>`,
+		"a/signup.layout.tmpl":        signup,
+		"a/login.layout.tmpl":         `<form><input name="user" required></form>`,
+		"b/static.layout.tmpl":        `<p>no forms here</p>`,
+		"a/partial.tmpl":              `<form id="ignored"></form>`,
+		"components/input.tmpl":       `<input name="x">`,
+		"users/[id]/edit.layout.tmpl": `<form id="profile"><input name="bio"></form>`,
+	} {
+		_, err := in.AddVirtual(path, []byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, GenerateAll(in, out))
+
+	src, err := out.ReadFile("a/" + OutputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "func DecodeSignupForm(")
+	assert.Contains(t, string(src), "func DecodeLoginForm(")
+	assert.NotContains(t, string(src), "IgnoredForm")
+	typeCheck(t, "a", src)
+
+	_, err = out.ReadFile("b/" + OutputFile)
+	assert.Error(t, err)
+
+	// directories that are route parameters cannot be imported, so their forms are generated in the parent package
+	src, err = out.ReadFile("users/" + OutputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "package users")
+	assert.Contains(t, string(src), "func DecodeProfileForm(")
+}
+
+// sources imports packages from source for typeCheck, caching them between tests
+var sources = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck fails the test unless the generated source of package pkg compiles
+func typeCheck(t *testing.T, pkg string, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, OutputFile, src, 0)
+	require.NoError(t, err)
+	conf := types.Config{Importer: sources}
+	_, err = conf.Check(pkg, fset, []*ast.File{f}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,271 @@
+package form
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/utils"
+)
+
+//go:embed decode.go.tmpl
+var decodeTmpl string
+
+// OutputFile is the name of the generated file written to each package with forms
+const OutputFile = "forms_gen.go"
+
+var tmpl = template.Must(template.New("decode").Funcs(template.FuncMap{
+	"anchor":     anchor,
+	"patternVar": patternVar,
+	"decode":     decodeField,
+}).Parse(decodeTmpl))
+
+// Generate writes the Go source for the form structs, decoders and validators of a single package
+func Generate(w io.Writer, pkg string, forms []Form) error {
+	seen := make(map[string]string)
+	for _, f := range forms {
+		if other, ok := seen[f.Type()]; ok {
+			return fmt.Errorf("form %s in %s conflicts with form of the same name in %s", f.Type(), f.Template, other)
+		}
+		seen[f.Type()] = f.Template
+		for _, field := range f.Fields {
+			if field.Pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(anchor(field.Pattern)); err != nil {
+				return fmt.Errorf("invalid pattern for field %s in %s: %w", field.Name, f.Template, err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Imports": imports(forms),
+		"Forms":   forms,
+	}); err != nil {
+		return fmt.Errorf("failed to generate forms for package %s: %w", pkg, err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated forms for package %s: %w", pkg, err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateAll parses the forms in every target of the input filesystem and writes one generated file per
+// target directory to the output filesystem
+func GenerateAll(in *fs.Filesystem, out *fs.Filesystem) error {
+	targets, err := in.Targets()
+	if err != nil {
+		return err
+	}
+
+	byDir := make(map[string][]Form)
+	var dirs []string
+	for _, target := range targets {
+		b, err := in.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		forms, err := Parse(target, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		if len(forms) == 0 {
+			continue
+		}
+		// forms in directories that are route parameters are generated in the nearest package
+		dir := utils.ParsePath(target).PackageDir()
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], forms...)
+	}
+
+	for _, dir := range dirs {
+		var buf bytes.Buffer
+		if err := Generate(&buf, utils.NewPath(dir, "").PackageName(), byDir[dir]); err != nil {
+			return err
+		}
+		if _, err := out.AddVirtual(utils.NewPath(dir, OutputFile).String(), buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write generated forms for %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func imports(forms []Form) []string {
+	set := map[string]bool{
+		"net/http": true,
+		"sort":     true,
+		"strings":  true,
+	}
+	for _, f := range forms {
+		for _, field := range f.Fields {
+			switch {
+			case field.Kind == Int || field.Kind == Float:
+				set["strconv"] = true
+			case field.Type == "email":
+				set["net/mail"] = true
+			case field.Type == "url":
+				set["net/url"] = true
+			}
+			if field.Pattern != "" {
+				set["regexp"] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for imp := range set {
+		out = append(out, imp)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// anchor matches the semantics of the HTML pattern attribute, which must match the entire value
+func anchor(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+func patternVar(f Form, field Field) string {
+	t := []rune(f.Type())
+	t[0] = unicode.ToLower(t[0])
+	return string(t) + field.GoName() + "Pattern"
+}
+
+// decodeField returns the statements that decode and validate a single field
+func decodeField(f Form, field Field) string {
+	var b strings.Builder
+	name := strconv.Quote(field.Name)
+	w := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+	fail := func(format string, args ...interface{}) {
+		w("errs.add(%s, %s)", name, strconv.Quote(fmt.Sprintf(format, args...)))
+	}
+	required := func() {
+		if field.Required {
+			w("} else {")
+			fail("is required")
+		}
+		w("}")
+	}
+
+	switch field.Kind {
+	case Bool:
+		w("f.%s = r.Form.Get(%s) != \"\"", field.GoName(), name)
+		if field.Required {
+			w("if !f.%s {", field.GoName())
+			fail("is required")
+			w("}")
+		}
+	case Strings:
+		w("f.%s = r.Form[%s]", field.GoName(), name)
+		if field.Required {
+			w("if len(f.%s) == 0 {", field.GoName())
+			fail("is required")
+			w("}")
+		}
+		if len(field.Options) > 0 {
+			w("for _, v := range f.%s {", field.GoName())
+			choices(field, w, fail)
+			w("}")
+		}
+	case Int, Float:
+		w("if v := r.Form.Get(%s); v != \"\" {", name)
+		if field.Kind == Int {
+			w("n, err := strconv.Atoi(v)")
+		} else {
+			w("n, err := strconv.ParseFloat(v, 64)")
+		}
+		w("if err != nil {")
+		fail("must be a number")
+		w("} else {")
+		w("f.%s = n", field.GoName())
+		if field.Min != "" {
+			w("if n < %s {", field.Min)
+			fail("must be at least %s", field.Min)
+			w("}")
+		}
+		if field.Max != "" {
+			w("if n > %s {", field.Max)
+			fail("must be at most %s", field.Max)
+			w("}")
+		}
+		w("}")
+		required()
+	default:
+		w("if v := r.Form.Get(%s); v != \"\" {", name)
+		w("f.%s = v", field.GoName())
+		if field.MinLength > 0 {
+			w("if len([]rune(v)) < %d {", field.MinLength)
+			fail("must be at least %d characters", field.MinLength)
+			w("}")
+		}
+		if field.MaxLength > 0 {
+			w("if len([]rune(v)) > %d {", field.MaxLength)
+			fail("must be at most %d characters", field.MaxLength)
+			w("}")
+		}
+		if field.Pattern != "" {
+			w("if !%s.MatchString(v) {", patternVar(f, field))
+			fail("does not match the required format")
+			w("}")
+		}
+		// date and time inputs submit fixed width ISO 8601 values which compare lexically
+		if field.Min != "" {
+			w("if v < %s {", strconv.Quote(field.Min))
+			fail("must not be before %s", field.Min)
+			w("}")
+		}
+		if field.Max != "" {
+			w("if v > %s {", strconv.Quote(field.Max))
+			fail("must not be after %s", field.Max)
+			w("}")
+		}
+		switch field.Type {
+		case "email":
+			w("if _, err := mail.ParseAddress(v); err != nil {")
+			fail("must be a valid email address")
+			w("}")
+		case "url":
+			w("if u, err := url.ParseRequestURI(v); err != nil || u.Scheme == \"\" {")
+			fail("must be a valid URL")
+			w("}")
+		}
+		if len(field.Options) > 0 {
+			choices(field, w, fail)
+		}
+		required()
+	}
+	return b.String()
+}
+
+// choices restricts the value v to the options declared in the markup
+func choices(field Field, w func(string, ...interface{}), fail func(string, ...interface{})) {
+	seen := make(map[string]bool)
+	var quoted []string
+	for _, o := range field.Options {
+		if !seen[o] {
+			quoted = append(quoted, strconv.Quote(o))
+			seen[o] = true
+		}
+	}
+	w("switch v {")
+	w("case %s:", strings.Join(quoted, ", "))
+	w("default:")
+	fail("is not a valid choice")
+	w("}")
+}
@@ -0,0 +1,80 @@
+package fs
+
+import (
+	"fmt"
+
+	"github.com/BTBurke/taevas/utils"
+)
+
+// SetConfig sets a configuration value used by the template views, replacing any existing value
+func (f *Filesystem) SetConfig(key string, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.db.Exec("INSERT INTO config (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value", key, value); err != nil {
+		return fmt.Errorf("failed to set config %s: %w", key, err)
+	}
+	return nil
+}
+
+// Config returns the configuration value for key
+func (f *Filesystem) Config(key string) (string, error) {
+	var value string
+	if err := f.db.Get(&value, "SELECT value FROM config WHERE key = ? LIMIT 1", key); err != nil {
+		return "", fmt.Errorf("failed to get config %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetDirectoryLayout sets the default layout for targets in dir and its subdirectories that do not name a layout,
+// replacing any existing default.  The nearest directory with a default takes precedence.  Without a target suffix
+// every target names its layout, so it returns an error unless target_suffix is set first.
+func (f *Filesystem) SetDirectoryLayout(dir string, layout string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var suffix string
+	if err := f.db.Get(&suffix, "SELECT target_suffix FROM naming"); err != nil {
+		return fmt.Errorf("failed to set default layout for %s: %w", dir, err)
+	}
+	if suffix == "" {
+		return fmt.Errorf("failed to set default layout for %s: directory layouts require a target suffix, since targets otherwise name their layout", dir)
+	}
+	if _, err := f.db.Exec("INSERT INTO directory_layouts (dir, layout) VALUES (?, ?) ON CONFLICT(dir) DO UPDATE SET layout = excluded.layout", dir, layout); err != nil {
+		return fmt.Errorf("failed to set default layout for %s: %w", dir, err)
+	}
+	return nil
+}
+
+// SetExtension registers an additional template extension parsed as html or text, replacing the type of an
+// existing extension.  The extension may contain dots, e.g. .txt.tmpl, and the longest matching extension wins.
+func (f *Filesystem) SetExtension(ext string, typ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.db.Exec("INSERT INTO template_extensions (ext, type) VALUES (?, ?) ON CONFLICT(ext) DO UPDATE SET type = excluded.type", ext, typ); err != nil {
+		return fmt.Errorf("failed to set template extension %s: %w", ext, err)
+	}
+	return nil
+}
+
+// Extensions returns every template extension
+func (f *Filesystem) Extensions() ([]string, error) {
+	var exts []string
+	if err := f.db.Select(&exts, "SELECT ext FROM extensions ORDER BY ext"); err != nil {
+		return nil, fmt.Errorf("failed to query template extensions: %w", err)
+	}
+	return exts, nil
+}
+
+// SetRoute sets the URL path of the target at path, replacing any existing route.  A route in the front matter of
+// the target takes precedence.
+func (f *Filesystem) SetRoute(path string, route string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.db.Exec("INSERT INTO target_routes (path, route) VALUES (?, ?) ON CONFLICT(path) DO UPDATE SET route = excluded.route", utils.ParsePath(path).String(), route); err != nil {
+		return fmt.Errorf("failed to set route for %s: %w", path, err)
+	}
+	return nil
+}
@@ -6,16 +6,68 @@ CREATE TABLE IF NOT EXISTS config (
 
 CREATE UNIQUE INDEX IF NOT EXISTS cfg_idx ON config(key);
 
+-- primary template extension, parsed with html/template.  Additional extensions are in template_extensions.
 INSERT INTO config (key, value) VALUES ('template_extension', '.tmpl');
+-- layouts are templates whose file name starts with the prefix, e.g. _base.tmpl
+INSERT INTO config (key, value) VALUES ('layout_prefix', '_');
+-- when set, every template in this directory is a layout available to the whole tree and the prefix is optional
+INSERT INTO config (key, value) VALUES ('layout_directory', '');
+-- when set, targets are templates whose name ends in the suffix, e.g. .page for index.page.tmpl.  The layout
+-- may still be named before the suffix, e.g. index.admin.page.tmpl
+INSERT INTO config (key, value) VALUES ('target_suffix', '');
+-- when set, globals are the templates in this directory instead of those in directories without targets
+INSERT INTO config (key, value) VALUES ('globals_directory', '');
+-- layout used by targets that do not name one and have no directory default, which is only possible when a target suffix is set
+INSERT INTO config (key, value) VALUES ('default_layout', '');
+-- when set to path, locals and globals are named by their root relative path without the extension, e.g. components/button,
+-- instead of by their file name
+INSERT INTO config (key, value) VALUES ('partial_names', '');
+
+-- default layouts for targets in a directory and its subdirectories that do not name a layout, which requires a target suffix
+CREATE TABLE IF NOT EXISTS directory_layouts (
+  dir TEXT NOT NULL PRIMARY KEY CHECK(length(dir) > 0),
+  layout TEXT NOT NULL
+);
+
+-- URL paths of targets set by configuration, keyed by target path, e.g. blog/post.layout.tmpl -> /articles/post
+CREATE TABLE IF NOT EXISTS target_routes (
+  path TEXT NOT NULL PRIMARY KEY,
+  route TEXT NOT NULL CHECK(SUBSTR(route, 1, 1) = '/')
+);
+
+-- additional template extensions and whether templates with the extension are parsed with html/template or text/template,
+-- e.g. .txt.tmpl for plain text.  Extensions may contain dots and the longest matching extension wins.
+CREATE TABLE IF NOT EXISTS template_extensions (
+  ext TEXT NOT NULL PRIMARY KEY CHECK(length(ext) > 0),
+  type TEXT NOT NULL CHECK(type IN ('html', 'text'))
+);
 
 -- convenience view to return the template extension
 CREATE VIEW IF NOT EXISTS template_extension AS
   SELECT value FROM config WHERE key = 'template_extension' LIMIT 1;
 
+-- all template extensions with their type
+CREATE VIEW IF NOT EXISTS extensions AS
+  SELECT value as ext, 'html' as type FROM config WHERE key = 'template_extension'
+  UNION
+  SELECT ext, type FROM template_extensions;
+
+-- single row view of the naming conventions used to classify templates
+CREATE VIEW IF NOT EXISTS naming AS
+  SELECT
+    (SELECT value FROM config WHERE key = 'template_extension') as ext,
+    (SELECT value FROM config WHERE key = 'layout_prefix') as layout_prefix,
+    (SELECT value FROM config WHERE key = 'layout_directory') as layout_directory,
+    (SELECT value FROM config WHERE key = 'target_suffix') as target_suffix,
+    (SELECT value FROM config WHERE key = 'globals_directory') as globals_directory,
+    (SELECT value FROM config WHERE key = 'default_layout') as default_layout,
+    (SELECT value FROM config WHERE key = 'partial_names') as partial_names;
+
 -- creates filesystem view, optionally storing the content of virtual files in data
 -- backing indicates whether the file exists on disk or purely in memory: disk backed = 0; virtual = 1
 -- depth indicates the subdirectory depth relative to the module root (root = 0)
 -- entries are not created for directories, they implicitly exist when a file is created
+-- modtime is in nanoseconds since the Unix epoch
 CREATE TABLE IF NOT EXISTS fs (
   id INTEGER PRIMARY KEY AUTOINCREMENT,
   dir TEXT NOT NULL CHECK(length(dir) > 0),
@@ -23,11 +75,22 @@ CREATE TABLE IF NOT EXISTS fs (
   data BLOB,
   depth INTEGER NOT NULL DEFAULT 0 CHECK(depth >= 0),
   backing INTEGER NOT NULL DEFAULT 0 CHECK(backing >= 0 AND backing <= 1),
-  modtime INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+  body_offset INTEGER NOT NULL DEFAULT 0 CHECK(body_offset >= 0),
+  modtime INTEGER NOT NULL DEFAULT (strftime('%s', 'now') * 1000000000)
 );
 
 CREATE UNIQUE INDEX IF NOT EXISTS full_path_idx ON fs(dir,filename);
 
+-- key/value metadata from the front matter of a template.  Disk-backed templates with front matter record the
+-- length of the front matter block in fs.body_offset so that reads return only the template body.
+CREATE TABLE IF NOT EXISTS metadata (
+  fs_id INTEGER NOT NULL REFERENCES fs(id) ON DELETE CASCADE,
+  key TEXT NOT NULL CHECK(length(key) > 0),
+  value TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS metadata_idx ON metadata(fs_id, key);
+
 -- simplified view of the filesystem
 CREATE VIEW IF NOT EXISTS filesystem AS 
   SELECT 
@@ -35,6 +98,7 @@ CREATE VIEW IF NOT EXISTS filesystem AS
     dir || '/' || filename as path,
     data,
     backing,
+    body_offset,
     modtime as time
   FROM
     fs;
@@ -61,7 +125,7 @@ CREATE VIEW IF NOT EXISTS read_dir AS
     SUBSTR(d2.dir, length(d.dir)+2) as path,
     (SELECT NULL) as data,
     (SELECT -1) as backing,
-    (SELECT (strftime('%s', 'now'))) as time
+    (SELECT (strftime('%s', 'now') * 1000000000)) as time
     FROM directories d JOIN directories d2
     ON d2.dir LIKE d.dir || '%' AND d2.depth = d.depth + 1
   UNION ALL
@@ -71,47 +135,193 @@ CREATE VIEW IF NOT EXISTS read_dir AS
     d2.dir as path,
     (SELECT NULL) as data,
     (SELECT -1) as backing,
-    (SELECT (strftime('%s', 'now'))) as time
+    (SELECT (strftime('%s', 'now') * 1000000000)) as time
     FROM directories d JOIN directories d2
     ON (d.dir = '.' AND d2.depth = 1); 
 
--- finds layout templates that look start with a _.  Layouts may also inherit from other layouts using
--- _layout.parent.tmpl
-CREATE VIEW IF NOT EXISTS layouts AS 
-  SELECT * FROM fs WHERE filename LIKE '__%' || (SELECT * FROM template_extension) ESCAPE '_';
+-- all files with a template extension.  The stem is the file name without its longest matching extension, the type is html or
+-- text and the explicit layout is the layout named in the template by front matter or a {{/* layout: name */}} comment.  The
+-- param length is the length of a leading route parameter such as [id] or [page.slug], whose dots do not separate the layout.
+CREATE VIEW IF NOT EXISTS templates AS
+  SELECT
+    fs.id,
+    fs.dir,
+    fs.filename,
+    fs.data,
+    fs.depth,
+    fs.backing,
+    fs.modtime,
+    e.ext,
+    e.type,
+    SUBSTR(fs.filename, 1, length(fs.filename) - length(e.ext)) as stem,
+    CASE WHEN SUBSTR(fs.filename, 1, 1) = '[' THEN INSTR(fs.filename, ']') ELSE 0 END as param_length,
+    COALESCE((SELECT value FROM metadata m WHERE m.fs_id = fs.id AND m.key = 'layout'), '') as explicit_layout,
+    (n.globals_directory != '' AND (fs.dir = n.globals_directory OR SUBSTR(fs.dir, 1, length(n.globals_directory) + 1) = n.globals_directory || '/')) as in_globals_directory
+  FROM fs, naming n
+  JOIN extensions e ON length(fs.filename) > length(e.ext) AND SUBSTR(fs.filename, -length(e.ext)) = e.ext
+  WHERE NOT EXISTS (
+    SELECT 1 FROM extensions longer
+    WHERE length(longer.ext) > length(e.ext) AND length(fs.filename) > length(longer.ext) AND SUBSTR(fs.filename, -length(longer.ext)) = longer.ext
+  );
+
+-- Email templates are pairs of an html part <name>.email.html.tmpl and a text part <name>.email.txt.tmpl, which are rendered
+-- together by a generated Render<Name>Email function.  The parts may also use the .html.tmpl and .txt.tmpl extensions when they
+-- are configured.  The html part is parsed with html/template and the text part with text/template whatever the type of the
+-- extension.  Emails are not layouts, targets or partials and are parsed on their own.
+CREATE VIEW IF NOT EXISTS emails AS
+  SELECT e.id, e.dir, e.filename, e.data, e.depth, e.backing, e.modtime, e.name, e.part FROM (
+    SELECT
+      t.*,
+      CASE
+        WHEN SUBSTR(t.stem, -11) = '.email.html' THEN SUBSTR(t.stem, 1, length(t.stem) - 11)
+        WHEN SUBSTR(t.stem, -10) = '.email.txt' THEN SUBSTR(t.stem, 1, length(t.stem) - 10)
+        WHEN SUBSTR(t.stem, -6) = '.email' AND (SUBSTR(t.ext, 1, 6) = '.html.' OR SUBSTR(t.ext, 1, 5) = '.txt.') THEN SUBSTR(t.stem, 1, length(t.stem) - 6)
+      END as name,
+      CASE WHEN SUBSTR(t.stem, -11) = '.email.html' OR (SUBSTR(t.stem, -6) = '.email' AND SUBSTR(t.ext, 1, 6) = '.html.') THEN 'html' ELSE 'text' END as part
+    FROM templates t
+  ) e
+  WHERE length(e.name) > 0;
 
--- returns the short name of the template _layout.tmpl -> layout or _inherited.layout.tmpl -> inherited
+-- finds layout templates that start with the layout prefix (default _) or live in the layout directory.  Layouts may also
+-- inherit from other layouts using _layout.parent.tmpl.  The scope is the directory whose targets may use the layout, which
+-- is the whole tree for layouts in the layout directory.
+CREATE VIEW IF NOT EXISTS layouts AS
+  SELECT
+    t.id,
+    t.dir,
+    t.filename,
+    t.data,
+    t.depth,
+    t.backing,
+    t.modtime,
+    t.type,
+    CASE WHEN n.layout_directory != '' THEN '.' ELSE t.dir END as scope,
+    CASE WHEN n.layout_directory != '' THEN 0 ELSE t.depth END as scope_depth,
+    CASE WHEN length(n.layout_prefix) > 0 AND SUBSTR(t.stem, 1, length(n.layout_prefix)) = n.layout_prefix
+      THEN SUBSTR(t.stem, length(n.layout_prefix) + 1)
+      ELSE t.stem
+    END as name
+  FROM templates t, naming n
+  WHERE t.id NOT IN (SELECT id FROM emails)
+  AND (
+    (n.layout_directory = '' AND length(n.layout_prefix) > 0 AND SUBSTR(t.filename, 1, length(n.layout_prefix)) = n.layout_prefix)
+    OR (n.layout_directory != '' AND (t.dir = n.layout_directory OR SUBSTR(t.dir, 1, length(n.layout_directory) + 1) = n.layout_directory || '/'))
+  );
+
+-- returns the short name of the template _layout.tmpl -> layout or _inherited.layout.tmpl -> inherited, along with the
+-- short name of the parent layout it inherits from, if any
 CREATE VIEW IF NOT EXISTS layouts_short_name AS
   SELECT
     id,
     filename,
-    SUBSTR(filename, 2, INSTR(filename, '.')-2) as short_name
+    CASE WHEN INSTR(name, '.') > 0 THEN SUBSTR(name, 1, INSTR(name, '.') - 1) ELSE name END as short_name,
+    CASE WHEN INSTR(name, '.') > 0 THEN SUBSTR(name, INSTR(name, '.') + 1) ELSE NULL END as parent_name
   FROM layouts;
 
--- Target templates are of the form <name>.<layout>.tmpl.  Targets are the only template that is directly
--- rendered.  Convenience functions are created to render these targets from http.Handlers.
+-- Target templates are of the form <name>.<layout>.tmpl, or <name>[.<layout>]<suffix>.tmpl when a target suffix is configured.
+-- Any other template that names its layout explicitly is also a target.  Targets are the only template that is directly rendered.
+-- Convenience functions are created to render these targets from http.Handlers.  The name is the stem without the target suffix.
 CREATE VIEW IF NOT EXISTS targets AS
-  SELECT * FROM fs WHERE filename LIKE '%.%' || (SELECT * FROM template_extension)
-  AND id NOT IN (SELECT id FROM layouts);
+  SELECT
+    t.id,
+    t.dir,
+    t.filename,
+    t.data,
+    t.depth,
+    t.backing,
+    t.modtime,
+    t.type,
+    t.explicit_layout,
+    t.param_length,
+    CASE WHEN n.target_suffix != '' AND length(t.stem) > length(n.target_suffix) AND SUBSTR(t.stem, -length(n.target_suffix)) = n.target_suffix
+      THEN SUBSTR(t.stem, 1, length(t.stem) - length(n.target_suffix))
+      ELSE t.stem
+    END as name
+  FROM templates t, naming n
+  WHERE t.id NOT IN (SELECT id FROM layouts)
+  AND t.id NOT IN (SELECT id FROM emails)
+  AND NOT t.in_globals_directory
+  AND (
+    t.explicit_layout != ''
+    OR (n.target_suffix = '' AND INSTR(SUBSTR(t.stem, t.param_length + 1), '.') > 0)
+    OR (n.target_suffix != '' AND length(t.stem) > length(n.target_suffix) AND SUBSTR(t.stem, -length(n.target_suffix)) = n.target_suffix)
+  );
+
+-- The short name of the layout requested by a target.  An explicit layout takes precedence over the last dot separated segment
+-- of its name.  Targets that name neither use the default layout of the nearest directory that has one, or the default layout.
+CREATE VIEW IF NOT EXISTS targets_layout_name AS
+  SELECT
+    t.id,
+    t.filename,
+    CASE
+      WHEN t.explicit_layout != '' THEN t.explicit_layout
+      WHEN INSTR(SUBSTR(t.name, t.param_length + 1), '.') > 0 THEN SUBSTR(t.name, length(RTRIM(t.name, REPLACE(t.name, '.', ''))) + 1)
+      ELSE COALESCE(
+        (SELECT dl.layout FROM directory_layouts dl
+          WHERE dl.dir = '.' OR t.dir = dl.dir OR SUBSTR(t.dir, 1, length(dl.dir) + 1) = dl.dir || '/'
+          ORDER BY dl.dir = '.', length(dl.dir) DESC LIMIT 1),
+        (SELECT default_layout FROM naming)
+      )
+    END as layout_name
+  FROM targets t;
+
+-- The URL path that serves each target.  A route in the front matter takes precedence over one set by configuration.
+-- Otherwise the route is the directory of the target followed by the first dot separated segment of its name, so that
+-- blog/post.layout.tmpl is served at /blog/post.  Targets named index are served at their directory, e.g. / or /blog/.
+-- Route parameters in brackets are kept, e.g. users/[id:int]/profile.layout.tmpl is served at /users/[id:int]/profile.
+CREATE VIEW IF NOT EXISTS routes AS
+  SELECT
+    t.id,
+    t.dir || '/' || t.filename as target_path,
+    t.type,
+    COALESCE(
+      (SELECT value FROM metadata m WHERE m.fs_id = t.id AND m.key = 'route'),
+      (SELECT route FROM target_routes r WHERE r.path = t.dir || '/' || t.filename),
+      CASE WHEN t.dir = '.' THEN '/' ELSE '/' || t.dir || '/' END || CASE WHEN t.page = 'index' THEN '' ELSE t.page END
+    ) as route
+  FROM (
+    SELECT *, CASE WHEN INSTR(SUBSTR(name, param_length + 1), '.') > 0 THEN SUBSTR(name, 1, param_length + INSTR(SUBSTR(name, param_length + 1), '.') - 1) ELSE name END as page FROM targets
+  ) t;
+
+-- Collection items are the markdown (.md) and data (.json, .yaml, .yml) files that static builds render with targets declaring
+-- their directory as a collection.  The slug identifies an item in URLs and is the slug key of its metadata or its file name
+-- without the extension.
+CREATE VIEW IF NOT EXISTS collection_items AS
+  SELECT
+    fs.id,
+    fs.dir,
+    fs.filename,
+    COALESCE(
+      (SELECT value FROM metadata m WHERE m.fs_id = fs.id AND m.key = 'slug'),
+      SUBSTR(fs.filename, 1, length(fs.filename) - length(x.ext))
+    ) as slug
+  FROM fs
+  JOIN (SELECT '.md' as ext UNION SELECT '.json' UNION SELECT '.yaml' UNION SELECT '.yml') x
+    ON length(fs.filename) > length(x.ext) AND SUBSTR(fs.filename, -length(x.ext)) = x.ext
+  WHERE fs.id NOT IN (SELECT id FROM templates);
 
 -- Locals are partial templates in the same directory as a target template.  Locals are placed in the parse tree
 -- with their associated targets in the same package.  This allows per-package partial includes that do not affect templates
 -- in other directories/packages.
 CREATE VIEW IF NOT EXISTS locals AS
-  SELECT * FROM fs WHERE filename LIKE '%' || (SELECT * FROM template_extension) 
-  AND id NOT IN (SELECT id FROM layouts)
-  AND id NOT IN (SELECT id FROM targets)
-  AND dir IN (SELECT dir FROM targets);
+  SELECT t.id, t.dir, t.filename, t.data, t.depth, t.backing, t.modtime, t.type FROM templates t
+  WHERE t.id NOT IN (SELECT id FROM layouts)
+  AND t.id NOT IN (SELECT id FROM targets)
+  AND t.id NOT IN (SELECT id FROM emails)
+  AND NOT t.in_globals_directory
+  AND t.dir IN (SELECT dir FROM targets);
 
--- Globals are partial templates that are in directories with no targets.  These are placed in the parse tree for every
--- target.  This is useful for global templates such as UI components.
+-- Globals are partial templates that are in directories with no targets, or in the globals directory when one is
+-- configured.  These are placed in the parse tree for every target.  This is useful for global templates such as UI components.
 CREATE VIEW IF NOT EXISTS globals AS
-  SELECT * FROM fs WHERE filename LIKE '%' || (SELECT * FROM template_extension) 
-  AND id NOT IN (SELECT id FROM layouts)
-  AND id NOT IN (SELECT id FROM targets)
-  AND id NOT IN (SELECT id FROM locals);
+  SELECT t.id, t.dir, t.filename, t.data, t.depth, t.backing, t.modtime, t.type FROM templates t, naming n
+  WHERE t.id NOT IN (SELECT id FROM layouts)
+  AND t.id NOT IN (SELECT id FROM targets)
+  AND t.id NOT IN (SELECT id FROM locals)
+  AND t.id NOT IN (SELECT id FROM emails)
+  AND (n.globals_directory = '' OR t.in_globals_directory);
 
--- Finds the layout associated with the target.  It returns all layouts that match walking from the target
+-- Finds the layout associated with the target.  It returns all layouts of the same type that match walking from the target
 -- back to the module root.  This may result in layouts with the same name shadowing one higher up in the tree.
 CREATE VIEW IF NOT EXISTS target_layout AS
   SELECT 
@@ -121,12 +331,14 @@ CREATE VIEW IF NOT EXISTS target_layout AS
     layouts.id as layout_id,
     layouts.dir as layout_dir,
     layouts.dir || '/' || layouts.filename as layout_path    
-    FROM targets JOIN layouts
-    ON targets.filename LIKE '%.' || (SELECT short_name FROM layouts_short_name WHERE id = layouts.id) || '.%'
-    WHERE targets.dir LIKE LTRIM(layouts.dir, '.') || '%'
-    ORDER BY layouts.depth ASC;
+    FROM targets
+    JOIN targets_layout_name tln ON tln.id = targets.id
+    JOIN layouts_short_name lsn ON lsn.short_name = tln.layout_name
+    JOIN layouts ON layouts.id = lsn.id AND layouts.type = targets.type
+    WHERE layouts.scope = '.' OR targets.dir = layouts.scope OR SUBSTR(targets.dir, 1, length(layouts.scope) + 1) = layouts.scope || '/'
+    ORDER BY layouts.scope_depth ASC;
 
--- Finds all local partial templates in the same directory as the target
+-- Finds all local partial templates of the same type in the same directory as the target
 CREATE VIEW IF NOT EXISTS target_locals AS
   SELECT
     targets.id as target_id,
@@ -136,9 +348,9 @@ CREATE VIEW IF NOT EXISTS target_locals AS
     locals.dir as local_dir,
     locals.dir || '/' || locals.filename as local_path
     FROM targets JOIN locals
-    ON targets.dir = locals.dir;
+    ON targets.dir = locals.dir AND targets.type = locals.type;
 
--- Finds all global templates that should be included in the parse tree.  This is a convenience view to easily generate a tree of 
+-- Finds all global templates of the same type that should be included in the parse tree.  This is a convenience view to easily generate a tree of 
 -- templates to be parsed.
 CREATE VIEW IF NOT EXISTS target_globals AS
   SELECT
@@ -148,7 +360,7 @@ CREATE VIEW IF NOT EXISTS target_globals AS
     globals.id as global_id,
     globals.dir as global_dir,
     globals.dir || '/' || globals.filename as global_path
-    FROM targets CROSS JOIN globals;
+    FROM targets JOIN globals ON globals.type = targets.type;
 
 -- Finds the parent of a layout if it inherits from another layout.  This is used later in a recursive table expression to walk
 -- an arbitrary tree of layout template inheritance.
@@ -160,23 +372,51 @@ CREATE VIEW IF NOT EXISTS layout_parent AS
     l2.id as parent_id,
     l2.dir as parent_dir,
     l2.dir || '/' || l2.filename as parent_path    
-    FROM layouts l1 JOIN layouts l2 
-    ON l1.filename LIKE '%.' || (SELECT short_name FROM layouts_short_name WHERE id = l2.id) || '.%'
-    WHERE l1.dir LIKE LTRIM(l2.dir, '.') || '%'
-    ORDER BY l2.depth ASC;
+    FROM layouts l1
+    JOIN layouts_short_name s1 ON s1.id = l1.id
+    JOIN layouts_short_name s2 ON s2.short_name = s1.parent_name
+    JOIN layouts l2 ON l2.id = s2.id AND l2.type = l1.type
+    WHERE l2.scope = '.' OR l1.scope = l2.scope OR SUBSTR(l1.scope, 1, length(l2.scope) + 1) = l2.scope || '/'
+    ORDER BY l2.scope_depth ASC;
 
--- A recursive view that yields a tree of layouts needed to render a target
+-- A recursive view that yields a tree of layouts needed to render a target.  Visited records the layouts already in the tree
+-- so that an inheritance cycle stops the recursion instead of recursing forever.  Cycles are reported by layout_cycles.
 CREATE VIEW IF NOT EXISTS layout_tree AS 
-  WITH RECURSIVE layout_cte(target_dir, target_path, layout_dir, layout_path, ord) AS (
-    SELECT target_dir, target_path, layout_dir, layout_path, (SELECT 0) as ord
+  WITH RECURSIVE layout_cte(target_dir, target_path, layout_dir, layout_path, ord, visited) AS (
+    SELECT target_dir, target_path, layout_dir, layout_path, (SELECT 0) as ord, char(10) || layout_path || char(10) as visited
     FROM target_layout 
 re
     UNION ALL
 
-    SELECT cte.target_dir as target_dir, cte.target_path as target_path, lp.parent_dir as layout_dir, lp.parent_path as layout_path, cte.ord + 1 as ord
+    SELECT cte.target_dir as target_dir, cte.target_path as target_path, lp.parent_dir as layout_dir, lp.parent_path as layout_path, cte.ord + 1 as ord, cte.visited || lp.parent_path || char(10) as visited
     FROM layout_cte cte JOIN layout_parent lp ON cte.layout_path = lp.layout_path
+    WHERE INSTR(cte.visited, char(10) || lp.parent_path || char(10)) = 0
   )
-  SELECT * FROM layout_cte ORDER BY ord DESC; 
+  SELECT target_dir, target_path, layout_dir, layout_path, ord FROM layout_cte ORDER BY ord DESC; 
+
+-- Layouts that inherit from themselves through their parents.  The chain lists every layout in the cycle separated by newlines,
+-- starting and ending with the same layout.  A cycle is returned once for every layout in it.
+CREATE VIEW IF NOT EXISTS layout_cycles AS
+  WITH RECURSIVE walk(start_path, layout_path, chain, cycle) AS (
+    SELECT layout_path, parent_path, layout_path || char(10) || parent_path, layout_path = parent_path
+    FROM layout_parent
+
+    UNION ALL
+
+    SELECT w.start_path, lp.parent_path, w.chain || char(10) || lp.parent_path, lp.parent_path = w.start_path
+    FROM walk w JOIN layout_parent lp ON lp.layout_path = w.layout_path
+    WHERE NOT w.cycle
+    AND (lp.parent_path = w.start_path OR INSTR(char(10) || w.chain || char(10), char(10) || lp.parent_path || char(10)) = 0)
+  )
+  SELECT DISTINCT start_path as layout_path, chain FROM walk WHERE cycle;
+
+-- Targets whose layout short name matches no layout in scope.  These targets cannot be rendered and are missing from target_tree.
+CREATE VIEW IF NOT EXISTS unmatched_targets AS
+  SELECT
+    targets.dir || '/' || targets.filename as target_path,
+    tln.layout_name
+  FROM targets JOIN targets_layout_name tln ON tln.id = targets.id
+  WHERE targets.id NOT IN (SELECT target_id FROM target_layout);
 
 -- Yields the complete tree of templates that need to be parsed to render a target.  The precedence of templates matters:
 -- Layout (may be multiple if they inherit from each other) -> globals -> locals -> target
@@ -197,6 +437,29 @@ CREATE VIEW IF NOT EXISTS all_templates AS
   UNION ALL
   SELECT DISTINCT target_path as template_path FROM target_tree;
 
+-- Templates that are not a layout, target, local, global or email and are never parsed
+CREATE VIEW IF NOT EXISTS orphans AS
+  SELECT t.id, t.dir, t.filename FROM templates t
+  WHERE t.id NOT IN (SELECT id FROM layouts)
+  AND t.id NOT IN (SELECT id FROM emails)
+  AND t.id NOT IN (SELECT id FROM targets)
+  AND t.id NOT IN (SELECT id FROM locals)
+  AND t.id NOT IN (SELECT id FROM globals);
+
+-- Layouts that are not in the template tree of any target
+CREATE VIEW IF NOT EXISTS unused_layouts AS
+  SELECT l.id, l.dir, l.filename FROM layouts l
+  WHERE l.dir || '/' || l.filename NOT IN (SELECT layout_path FROM layout_tree);
+
+-- Front matter metadata of every template by path
+CREATE VIEW IF NOT EXISTS template_metadata AS
+  SELECT
+    t.id,
+    t.dir || '/' || t.filename as path,
+    m.key,
+    m.value
+  FROM templates t JOIN metadata m ON m.fs_id = t.id;
+
 -- Set of all directories that include template files
 CREATE VIEW IF NOT EXISTS all_template_directories AS
   SELECT DISTINCT template_dir FROM target_tree;
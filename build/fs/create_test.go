@@ -189,3 +189,277 @@ func TestComplexTrees(t *testing.T) {
 		assert.Contains(t, dirs, d)
 	}
 }
+
+func TestNamingConventions(t *testing.T) {
+	files := []string{
+		"layouts/base.html",
+		"layouts/admin.base.html",
+		"_legacy.html",
+		"components/button.html",
+		"components/forms/input.html",
+		"partials/unused.html",
+		"index.page.html",
+		"admin/users.admin.page.html",
+		"admin/row.html",
+		"about.html",
+	}
+
+	fs, err := New("/test")
+	require.NoError(t, err)
+	for key, value := range map[string]string{
+		"template_extension": ".html",
+		"layout_directory":   "layouts",
+		"target_suffix":      ".page",
+		"globals_directory":  "components",
+		"default_layout":     "base",
+	} {
+		require.NoError(t, fs.SetConfig(key, value))
+	}
+	for _, f := range files {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	expect := map[string][]string{
+		"layouts": {"layouts/base.html", "layouts/admin.base.html"},
+		"targets": {"./index.page.html", "admin/users.admin.page.html"},
+		"globals": {"components/button.html", "components/forms/input.html"},
+		// without the layout directory the prefix has no meaning, and templates beside targets are locals
+		"locals": {"./_legacy.html", "admin/row.html", "./about.html"},
+	}
+	for ttype, paths := range expect {
+		var templates []string
+		assert.NoError(t, fs.db.Select(&templates, "SELECT dir || '/' || filename FROM "+ttype))
+		assert.ElementsMatch(t, paths, templates, "failed for %s", ttype)
+	}
+
+	trees := map[string][]string{
+		"./index.page.html": {
+			"layouts/base.html",
+			"components/button.html",
+			"components/forms/input.html",
+			"./_legacy.html",
+			"./about.html",
+			"./index.page.html",
+		},
+		"admin/users.admin.page.html": {
+			"layouts/base.html",
+			"layouts/admin.base.html",
+			"components/button.html",
+			"components/forms/input.html",
+			"admin/row.html",
+			"admin/users.admin.page.html",
+		},
+	}
+	for target, expectedTree := range trees {
+		tree, err := fs.TargetTree(target)
+		require.NoError(t, err)
+		assert.Equal(t, expectedTree, tree, "failed for %s", target)
+	}
+}
+
+func TestLayoutScope(t *testing.T) {
+	// a layout only applies to targets in its own directory or below, not to sibling directories that share a prefix
+	fs, err := New("/test")
+	require.NoError(t, err)
+	for _, f := range []string{"a/_base.tmpl", "a/1.base.tmpl", "ab/1.base.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	tree, err := fs.TargetTree("ab/1.base.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(tree))
+
+	tree, err = fs.TargetTree("a/1.base.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/_base.tmpl", "a/1.base.tmpl"}, tree)
+}
+
+func TestExplicitLayout(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+
+	meta := map[string]Metadata{
+		"a/index.tmpl":      {"layout": "admin"},
+		"a/post.base.tmpl":  {"layout": "admin"},
+		"a/other.base.tmpl": {"title": "x"},
+	}
+	for _, f := range []string{"_base.tmpl", "_admin.tmpl", "a/index.tmpl", "a/post.base.tmpl", "a/other.base.tmpl", "a/row.tmpl"} {
+		id, err := fs.Add(f)
+		require.NoError(t, err)
+		if m, ok := meta[f]; ok {
+			require.NoError(t, fs.SetMetadata(id, 0, m))
+		}
+	}
+
+	targets, err := fs.Targets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/index.tmpl", "a/other.base.tmpl", "a/post.base.tmpl"}, targets)
+
+	// the explicit layout takes precedence over the file name
+	for target, expect := range map[string][]string{
+		"a/index.tmpl":      {"./_admin.tmpl", "a/row.tmpl", "a/index.tmpl"},
+		"a/post.base.tmpl":  {"./_admin.tmpl", "a/row.tmpl", "a/post.base.tmpl"},
+		"a/other.base.tmpl": {"./_base.tmpl", "a/row.tmpl", "a/other.base.tmpl"},
+	} {
+		tree, err := fs.TargetTree(target)
+		require.NoError(t, err)
+		assert.Equal(t, expect, tree, "failed for %s", target)
+	}
+}
+
+func TestDirectoryLayout(t *testing.T) {
+	// without a suffix every target names its layout, so a directory default would never apply
+	fs, err := New("/test")
+	require.NoError(t, err)
+	assert.Error(t, fs.SetDirectoryLayout("admin", "admin"))
+
+	fs, err = New("/test")
+	require.NoError(t, err)
+	require.NoError(t, fs.SetConfig("target_suffix", ".page"))
+	require.NoError(t, fs.SetConfig("default_layout", "base"))
+	require.NoError(t, fs.SetDirectoryLayout("admin", "admin"))
+	require.NoError(t, fs.SetDirectoryLayout("admin/public", "base"))
+
+	for _, f := range []string{"_base.tmpl", "_admin.tmpl", "index.page.tmpl", "admin/index.page.tmpl", "admin/users/list.page.tmpl", "admin/public/login.page.tmpl", "admin/plain.base.page.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	for target, layout := range map[string]string{
+		"./index.page.tmpl":            "./_base.tmpl",
+		"admin/index.page.tmpl":        "./_admin.tmpl",
+		"admin/users/list.page.tmpl":   "./_admin.tmpl",
+		"admin/public/login.page.tmpl": "./_base.tmpl",
+		"admin/plain.base.page.tmpl":   "./_base.tmpl",
+	} {
+		tree, err := fs.TargetTree(target)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(tree), "failed for %s", target)
+		assert.Equal(t, layout, tree[0], "failed for %s", target)
+	}
+}
+
+func TestLayoutCycles(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+	for _, f := range []string{"_a.b.tmpl", "_b.c.tmpl", "_c.a.tmpl", "_self.self.tmpl", "_ok.tmpl", "x/1.a.tmpl", "x/2.ok.tmpl", "x/3.missing.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	cycles, err := fs.LayoutCycles()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"./_a.b.tmpl", "./_b.c.tmpl", "./_c.a.tmpl", "./_a.b.tmpl"},
+		{"./_self.self.tmpl", "./_self.self.tmpl"},
+	}, cycles)
+
+	// the tree of a target using a layout in a cycle terminates
+	tree, err := fs.TargetTree("x/1.a.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"./_c.a.tmpl", "./_b.c.tmpl", "./_a.b.tmpl", "x/1.a.tmpl"}, tree)
+
+	unmatched, err := fs.UnmatchedTargets()
+	require.NoError(t, err)
+	assert.Equal(t, []UnmatchedTarget{{Path: "x/3.missing.tmpl", Layout: "missing"}}, unmatched)
+}
+
+func TestTemplateTypes(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+	require.NoError(t, fs.SetExtension(".html", TypeHTML))
+	require.NoError(t, fs.SetExtension(".txt.tmpl", TypeText))
+
+	for _, f := range []string{"_base.tmpl", "_base.txt.tmpl", "g/footer.html", "g/footer.txt.tmpl", "a/welcome.base.txt.tmpl", "a/index.base.html", "a/row.gohtml"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	exts, err := fs.Extensions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{".html", ".tmpl", ".txt.tmpl"}, exts)
+
+	for path, expect := range map[string]string{"./_base.tmpl": TypeHTML, "./_base.txt.tmpl": TypeText, "g/footer.html": TypeHTML, "a/welcome.base.txt.tmpl": TypeText} {
+		typ, err := fs.TemplateType(path)
+		require.NoError(t, err)
+		assert.Equal(t, expect, typ, "failed for %s", path)
+	}
+	_, err = fs.TemplateType("a/row.gohtml")
+	assert.Error(t, err)
+
+	// targets only use layouts and partials of their own type
+	for target, expect := range map[string][]string{
+		"a/welcome.base.txt.tmpl": {"./_base.txt.tmpl", "g/footer.txt.tmpl", "a/welcome.base.txt.tmpl"},
+		"a/index.base.html":       {"./_base.tmpl", "g/footer.html", "a/index.base.html"},
+	} {
+		tree, err := fs.TargetTree(target)
+		require.NoError(t, err)
+		assert.Equal(t, expect, tree, "failed for %s", target)
+	}
+}
+
+func TestEmails(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+	require.NoError(t, fs.SetExtension(".txt.tmpl", TypeText))
+
+	for _, f := range []string{"_base.tmpl", "mail/welcome.email.html.tmpl", "mail/welcome.email.txt.tmpl", "mail/reset.email.html.tmpl", "a/index.base.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+
+	emails, err := fs.Emails()
+	require.NoError(t, err)
+	assert.Equal(t, []Email{
+		{Dir: "mail", Name: "reset", HTML: "mail/reset.email.html.tmpl"},
+		{Dir: "mail", Name: "welcome", HTML: "mail/welcome.email.html.tmpl", Text: "mail/welcome.email.txt.tmpl"},
+	}, emails)
+
+	// emails are not targets or partials, so the mail directory does not make globals of them
+	targets, err := fs.Targets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/index.base.tmpl"}, targets)
+	globals, err := fs.Globals()
+	require.NoError(t, err)
+	assert.Empty(t, globals)
+	orphans, err := fs.Orphans()
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+}
+
+func TestRoutes(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+	require.NoError(t, fs.SetExtension(".txt.tmpl", TypeText))
+
+	for _, f := range []string{"_base.tmpl", "index.base.tmpl", "blog/index.base.tmpl", "blog/post.base.tmpl", "blog/draft.base.tmpl", "feed.base.txt.tmpl", "blog/local.tmpl", "users/[id:int]/profile.base.tmpl", "users/[id:int]/index.base.tmpl", "users/[id:int]/[tab.name].tmpl", "docs/[page.slug].base.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+	id, err := fs.AddVirtual("about.tmpl", []byte("about"))
+	require.NoError(t, err)
+	require.NoError(t, fs.SetMetadata(id, 0, Metadata{"layout": "base", "route": "/about-us"}))
+	require.NoError(t, fs.SetRoute("blog/draft.base.tmpl", "/drafts/latest"))
+	assert.Error(t, fs.SetRoute("blog/post.base.tmpl", "relative"))
+
+	routes, err := fs.Routes()
+	require.NoError(t, err)
+	assert.Equal(t, []Route{
+		{Target: "./index.base.tmpl", Route: "/", Type: TypeHTML},
+		{Target: "./about.tmpl", Route: "/about-us", Type: TypeHTML},
+		{Target: "blog/index.base.tmpl", Route: "/blog/", Type: TypeHTML},
+		{Target: "blog/post.base.tmpl", Route: "/blog/post", Type: TypeHTML},
+		{Target: "docs/[page.slug].base.tmpl", Route: "/docs/[page.slug]", Type: TypeHTML},
+		{Target: "blog/draft.base.tmpl", Route: "/drafts/latest", Type: TypeHTML},
+		{Target: "./feed.base.txt.tmpl", Route: "/feed", Type: TypeText},
+		{Target: "users/[id:int]/index.base.tmpl", Route: "/users/[id:int]/", Type: TypeHTML},
+		{Target: "users/[id:int]/profile.base.tmpl", Route: "/users/[id:int]/profile", Type: TypeHTML},
+	}, routes)
+
+	// dots inside a route parameter do not name a layout
+	locals, err := fs.Locals()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blog/local.tmpl", "users/[id:int]/[tab.name].tmpl"}, locals)
+}
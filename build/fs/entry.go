@@ -3,6 +3,7 @@ package fs
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -17,6 +18,8 @@ type Entry struct {
 	Data    []byte
 	Backing int
 	Time    int64 `sql:"time"`
+	// length of the front matter skipped when reading a disk-backed file
+	BodyOffset int64 `db:"body_offset"`
 
 	buf  *bytes.Reader
 	fh   *os.File
@@ -66,7 +69,7 @@ func (e Entry) Mode() fs.FileMode {
 }
 
 func (e Entry) ModTime() time.Time {
-	return time.Unix(e.Time, 0)
+	return time.Unix(0, e.Time)
 }
 
 func (e Entry) Sys() interface{} { return nil }
@@ -85,6 +88,10 @@ func (e *Entry) Read(b []byte) (int, error) {
 			if err != nil {
 				return 0, err
 			}
+			if _, err := fh.Seek(e.BodyOffset, io.SeekStart); err != nil {
+				fh.Close()
+				return 0, err
+			}
 			e.fh = fh
 			return e.fh.Read(b)
 		}
@@ -121,6 +128,9 @@ func (e *Entry) flush() error {
 	}
 
 	p := filepath.Join(e.root, e.Path)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", e.Path, err)
+	}
 	if err := os.WriteFile(p, e.Data, 0644); err != nil {
 		return fmt.Errorf("failed to flush file to disk: %w", err)
 	}
@@ -0,0 +1,235 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BTBurke/taevas/utils"
+)
+
+// Kinds of file reported by Explain
+const (
+	KindLayout = "layout"
+	KindTarget = "target"
+	KindLocal  = "local"
+	KindGlobal = "global"
+	// one part of an email template pair
+	KindEmail = "email"
+	// a template that is not part of any template tree
+	KindUnused = "unused"
+	// a file that is not a template, such as a stylesheet
+	KindFile = "file"
+)
+
+// Explanation describes how a file is classified and where it is used
+type Explanation struct {
+	Path   string
+	Kind   string
+	Reason string
+	// Layout is the layout a target resolves to or the parent of a layout.  When several layouts share the short
+	// name, this is the deepest one, which is parsed last and takes precedence.
+	Layout string
+	// Shadows lists the layouts with the same short name higher in the tree that are overridden by Layout for a
+	// target, or by the layout itself
+	Shadows []string
+	// Route is the URL path that serves a target
+	Route string
+	// Trees is the ordered list of templates parsed for every target the file participates in
+	Trees map[string][]string
+	// Targets lists the keys of Trees in order
+	Targets []string
+}
+
+type naming struct {
+	Ext              string `db:"ext"`
+	LayoutPrefix     string `db:"layout_prefix"`
+	LayoutDirectory  string `db:"layout_directory"`
+	TargetSuffix     string `db:"target_suffix"`
+	GlobalsDirectory string `db:"globals_directory"`
+	DefaultLayout    string `db:"default_layout"`
+	PartialNames     string `db:"partial_names"`
+}
+
+// Explain reports the classification of the file at path, why it was classified that way, how its layout resolves
+// and the template tree of every target it participates in
+func (f *Filesystem) Explain(path string) (*Explanation, error) {
+	p := utils.ParsePath(path).String()
+	if !f.Exists(p) {
+		return nil, fmt.Errorf("failed to explain %s: file has not been indexed", path)
+	}
+	var n naming
+	if err := f.db.Get(&n, "SELECT * FROM naming"); err != nil {
+		return nil, fmt.Errorf("failed to query naming conventions: %w", err)
+	}
+
+	e := &Explanation{Path: p, Trees: make(map[string][]string)}
+	var err error
+	switch {
+	case f.isIn("templates", p) == 0:
+		exts, err := f.Extensions()
+		if err != nil {
+			return nil, err
+		}
+		e.Kind = KindFile
+		e.Reason = fmt.Sprintf("it does not have a template extension (%s)", strings.Join(exts, ", "))
+		return e, nil
+	case f.isIn("emails", p) > 0:
+		var part struct {
+			Name string
+			Part string
+		}
+		if err := f.db.Get(&part, "SELECT name, part FROM emails WHERE dir || '/' || filename = ?", p); err != nil {
+			return nil, fmt.Errorf("failed to query email %s: %w", p, err)
+		}
+		e.Kind = KindEmail
+		e.Reason = fmt.Sprintf("its file name has the form <name>.email.<html|txt> and it is the %s part of email %s", part.Part, part.Name)
+		return e, nil
+	case f.isIn("layouts", p) > 0:
+		err = f.explainLayout(e, n)
+	case f.isIn("targets", p) > 0:
+		err = f.explainTarget(e, n)
+	case f.isIn("locals", p) > 0:
+		e.Kind = KindLocal
+		var targets []string
+		err = f.db.Select(&targets, "SELECT DISTINCT target_path FROM target_locals WHERE local_path = ? ORDER BY target_path", p)
+		e.Reason = fmt.Sprintf("it is in directory %s alongside the targets %s", utils.ParsePath(p).Dir(), strings.Join(targets, ", "))
+	case f.isIn("globals", p) > 0:
+		e.Kind = KindGlobal
+		e.Reason = fmt.Sprintf("its directory %s contains no targets", utils.ParsePath(p).Dir())
+		if n.GlobalsDirectory != "" {
+			e.Reason = fmt.Sprintf("it is in the globals directory %s", n.GlobalsDirectory)
+		}
+	default:
+		e.Kind = KindUnused
+		e.Reason = fmt.Sprintf("its directory %s contains no targets and it is not in the globals directory %s", utils.ParsePath(p).Dir(), n.GlobalsDirectory)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// targets without a layout have incomplete trees and are reported by the reason instead
+	if err := f.db.Select(&e.Targets, `SELECT DISTINCT target_path FROM target_tree WHERE (template_path = ? OR target_path = ?)
+		AND target_path IN (SELECT target_path FROM target_layout) ORDER BY target_path`, p, p); err != nil {
+		return nil, fmt.Errorf("failed to query targets using %s: %w", p, err)
+	}
+	for _, target := range e.Targets {
+		tree, err := f.TargetTree(target)
+		if err != nil {
+			return nil, err
+		}
+		e.Trees[target] = tree
+	}
+	return e, nil
+}
+
+func (f *Filesystem) explainLayout(e *Explanation, n naming) error {
+	e.Kind = KindLayout
+	var l struct {
+		Scope      string `db:"scope"`
+		ShortName  string `db:"short_name"`
+		ParentName string `db:"parent_name"`
+	}
+	if err := f.db.Get(&l, "SELECT l.scope, s.short_name, COALESCE(s.parent_name, '') as parent_name FROM layouts l JOIN layouts_short_name s ON s.id = l.id WHERE l.dir || '/' || l.filename = ?", e.Path); err != nil {
+		return fmt.Errorf("failed to query layout %s: %w", e.Path, err)
+	}
+	switch n.LayoutDirectory {
+	case "":
+		e.Reason = fmt.Sprintf("its file name starts with the layout prefix %s, it is named %s and applies to targets in %s", n.LayoutPrefix, l.ShortName, l.Scope)
+	default:
+		e.Reason = fmt.Sprintf("it is in the layout directory %s, it is named %s and applies to all targets", n.LayoutDirectory, l.ShortName)
+	}
+
+	if l.ParentName != "" {
+		var parents []string
+		if err := f.db.Select(&parents, "SELECT parent_path FROM layout_parent WHERE layout_path = ?", e.Path); err != nil {
+			return fmt.Errorf("failed to query parent of %s: %w", e.Path, err)
+		}
+		if len(parents) == 0 {
+			e.Reason += fmt.Sprintf(", but its parent layout %s does not exist", l.ParentName)
+		} else {
+			e.Layout = parents[len(parents)-1]
+			e.Reason += fmt.Sprintf(" and inherits from %s", l.ParentName)
+		}
+	}
+
+	// layouts with the same name whose scope contains the scope of this layout
+	if err := f.db.Select(&e.Shadows, `SELECT o.dir || '/' || o.filename FROM layouts o JOIN layouts_short_name s ON s.id = o.id
+		WHERE s.short_name = ? AND o.scope_depth < (SELECT scope_depth FROM layouts WHERE dir || '/' || filename = ?)
+		AND (o.scope = '.' OR ? = o.scope OR SUBSTR(?, 1, length(o.scope) + 1) = o.scope || '/')
+		ORDER BY o.scope_depth`, l.ShortName, e.Path, l.Scope, l.Scope); err != nil {
+		return fmt.Errorf("failed to query layouts shadowed by %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+func (f *Filesystem) explainTarget(e *Explanation, n naming) error {
+	e.Kind = KindTarget
+	var t struct {
+		Name           string `db:"name"`
+		Ext            string `db:"ext"`
+		ExplicitLayout string `db:"explicit_layout"`
+		LayoutName     string `db:"layout_name"`
+	}
+	if err := f.db.Get(&t, `SELECT t.name, tp.ext, t.explicit_layout, COALESCE(tln.layout_name, '') as layout_name FROM targets t
+		JOIN templates tp ON tp.id = t.id JOIN targets_layout_name tln ON tln.id = t.id WHERE t.dir || '/' || t.filename = ?`, e.Path); err != nil {
+		return fmt.Errorf("failed to query target %s: %w", e.Path, err)
+	}
+	if err := f.db.Get(&e.Route, "SELECT route FROM routes WHERE target_path = ?", e.Path); err != nil {
+		return fmt.Errorf("failed to query route of %s: %w", e.Path, err)
+	}
+	switch {
+	case t.ExplicitLayout != "":
+		e.Reason = fmt.Sprintf("it names the layout %s in its front matter or a layout comment", t.ExplicitLayout)
+	case n.TargetSuffix == "":
+		e.Reason = fmt.Sprintf("its file name has the form <name>.<layout>%s and names the layout %s", t.Ext, t.LayoutName)
+	case strings.Contains(t.Name, "."):
+		e.Reason = fmt.Sprintf("its file name ends in the target suffix %s and names the layout %s", n.TargetSuffix, t.LayoutName)
+	default:
+		e.Reason = fmt.Sprintf("its file name ends in the target suffix %s and it uses the default layout %s", n.TargetSuffix, t.LayoutName)
+	}
+
+	var layouts []string
+	if err := f.db.Select(&layouts, "SELECT layout_path FROM target_layout WHERE target_path = ?", e.Path); err != nil {
+		return fmt.Errorf("failed to query layout of %s: %w", e.Path, err)
+	}
+	if len(layouts) == 0 {
+		e.Reason += fmt.Sprintf(", but no layout named %s exists", t.LayoutName)
+		return nil
+	}
+	e.Layout = layouts[len(layouts)-1]
+	if len(layouts) > 1 {
+		e.Shadows = layouts[:len(layouts)-1]
+	}
+	return nil
+}
+
+// isIn returns the number of rows in a template view with the path
+func (f *Filesystem) isIn(view string, path string) int {
+	var n int
+	if err := f.db.Get(&n, "SELECT COUNT(*) FROM "+view+" WHERE dir || '/' || filename = ?", path); err != nil {
+		return 0
+	}
+	return n
+}
+
+// String formats the explanation as a human readable report
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s is a %s because %s\n", e.Path, e.Kind, e.Reason)
+	if e.Layout != "" {
+		fmt.Fprintf(&b, "  resolves to layout %s\n", e.Layout)
+	}
+	for _, s := range e.Shadows {
+		fmt.Fprintf(&b, "  shadows layout %s\n", s)
+	}
+	if e.Route != "" {
+		fmt.Fprintf(&b, "  served at %s\n", e.Route)
+	}
+	for _, target := range e.Targets {
+		fmt.Fprintf(&b, "  template tree for %s:\n", target)
+		for i, t := range e.Trees[target] {
+			fmt.Fprintf(&b, "    %d. %s\n", i+1, t)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	fs, err := New("/test")
+	require.NoError(t, err)
+	for _, f := range []string{"_base.tmpl", "g/1.tmpl", "b/_base.tmpl", "b/_sub.base.tmpl", "b/1.sub.tmpl", "b/local.tmpl", "b/2.none.tmpl", "b/site.css", "mail/welcome.email.txt.tmpl"} {
+		_, err := fs.Add(f)
+		require.NoError(t, err)
+	}
+	tree := []string{"./_base.tmpl", "b/_base.tmpl", "b/_sub.base.tmpl", "g/1.tmpl", "b/local.tmpl", "b/1.sub.tmpl"}
+
+	tt := []struct {
+		path   string
+		expect Explanation
+	}{
+		{path: "./b/1.sub.tmpl", expect: Explanation{
+			Path:    "b/1.sub.tmpl",
+			Kind:    KindTarget,
+			Reason:  "its file name has the form <name>.<layout>.tmpl and names the layout sub",
+			Layout:  "b/_sub.base.tmpl",
+			Route:   "/b/1",
+			Trees:   map[string][]string{"b/1.sub.tmpl": tree},
+			Targets: []string{"b/1.sub.tmpl"},
+		}},
+		{path: "b/_base.tmpl", expect: Explanation{
+			Path:    "b/_base.tmpl",
+			Kind:    KindLayout,
+			Reason:  "its file name starts with the layout prefix _, it is named base and applies to targets in b",
+			Shadows: []string{"./_base.tmpl"},
+			Trees:   map[string][]string{"b/1.sub.tmpl": tree},
+			Targets: []string{"b/1.sub.tmpl"},
+		}},
+		{path: "b/_sub.base.tmpl", expect: Explanation{
+			Path:    "b/_sub.base.tmpl",
+			Kind:    KindLayout,
+			Reason:  "its file name starts with the layout prefix _, it is named sub and applies to targets in b and inherits from base",
+			Layout:  "b/_base.tmpl",
+			Trees:   map[string][]string{"b/1.sub.tmpl": tree},
+			Targets: []string{"b/1.sub.tmpl"},
+		}},
+		{path: "b/local.tmpl", expect: Explanation{
+			Path:    "b/local.tmpl",
+			Kind:    KindLocal,
+			Reason:  "it is in directory b alongside the targets b/1.sub.tmpl, b/2.none.tmpl",
+			Trees:   map[string][]string{"b/1.sub.tmpl": tree},
+			Targets: []string{"b/1.sub.tmpl"},
+		}},
+		{path: "g/1.tmpl", expect: Explanation{
+			Path:    "g/1.tmpl",
+			Kind:    KindGlobal,
+			Reason:  "its directory g contains no targets",
+			Trees:   map[string][]string{"b/1.sub.tmpl": tree},
+			Targets: []string{"b/1.sub.tmpl"},
+		}},
+		{path: "b/2.none.tmpl", expect: Explanation{
+			Path:   "b/2.none.tmpl",
+			Kind:   KindTarget,
+			Reason: "its file name has the form <name>.<layout>.tmpl and names the layout none, but no layout named none exists",
+			Route:  "/b/2",
+			Trees:  map[string][]string{},
+		}},
+		{path: "mail/welcome.email.txt.tmpl", expect: Explanation{
+			Path:   "mail/welcome.email.txt.tmpl",
+			Kind:   KindEmail,
+			Reason: "its file name has the form <name>.email.<html|txt> and it is the text part of email welcome",
+			Trees:  map[string][]string{},
+		}},
+		{path: "b/site.css", expect: Explanation{
+			Path:   "b/site.css",
+			Kind:   KindFile,
+			Reason: "it does not have a template extension (.tmpl)",
+			Trees:  map[string][]string{},
+		}},
+	}
+	for _, tc := range tt {
+		e, err := fs.Explain(tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expect, *e, "failed for %s", tc.path)
+	}
+
+	_, err = fs.Explain("missing.tmpl")
+	assert.Error(t, err)
+
+	e, err := fs.Explain("b/1.sub.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, `b/1.sub.tmpl is a target because its file name has the form <name>.<layout>.tmpl and names the layout sub
+  resolves to layout b/_sub.base.tmpl
+  served at /b/1
+  template tree for b/1.sub.tmpl:
+    1. ./_base.tmpl
+    2. b/_base.tmpl
+    3. b/_sub.base.tmpl
+    4. g/1.tmpl
+    5. b/local.tmpl
+    6. b/1.sub.tmpl
+`, e.String())
+
+	// a target using a shadowed layout resolves to the deepest one
+	_, err = fs.Add("b/3.base.tmpl")
+	require.NoError(t, err)
+	e, err = fs.Explain("b/3.base.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "b/_base.tmpl", e.Layout)
+	assert.Equal(t, []string{"./_base.tmpl"}, e.Shadows)
+}
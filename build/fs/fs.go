@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/BTBurke/taevas/utils"
 	"github.com/jmoiron/sqlx"
@@ -39,13 +42,42 @@ func (f *Filesystem) add(name string, backing int, data []byte) (int, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	// disk-backed files keep their modification time so that builds can report when content last changed.  Times are
+	// stored in nanoseconds so that a rescan finds files saved twice within a second.
+	modtime := time.Now().UnixNano()
+	if backing == 0 {
+		if info, err := os.Stat(filepath.Join(f.root, p.Dir(), p.FileName())); err == nil {
+			modtime = info.ModTime().UnixNano()
+		}
+	}
+
 	var id int
-	if err := f.db.Get(&id, "INSERT INTO fs (dir, filename, depth, data, backing) VALUES (?,?,?,?,?) RETURNING id", p.Dir(), p.FileName(), d, data, backing); err != nil {
+	if err := f.db.Get(&id, "INSERT INTO fs (dir, filename, depth, data, backing, modtime) VALUES (?,?,?,?,?,?) RETURNING id", p.Dir(), p.FileName(), d, data, backing, modtime); err != nil {
 		return -1, err
 	}
 	return id, nil
 }
 
+// Remove deletes a file and its metadata from the filesystem.  Files on disk are left as they are.
+func (f *Filesystem) Remove(name string) error {
+	p := utils.ParsePath(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM metadata WHERE fs_id IN (SELECT id FROM fs WHERE dir = ? AND filename = ?)", p.Dir(), p.FileName()); err != nil {
+		return fmt.Errorf("failed to remove metadata of %s: %w", name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM fs WHERE dir = ? AND filename = ?", p.Dir(), p.FileName()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return tx.Commit()
+}
+
 // returns a blank entry with the root preserved
 func (f *Filesystem) newEntry() *Entry {
 	return &Entry{
@@ -171,3 +203,35 @@ func (f *Filesystem) Conn() *sqlx.DB {
 var _ fs.FS = &Filesystem{}
 var _ fs.ReadDirFS = &Filesystem{}
 var _ fs.ReadFileFS = &Filesystem{}
+
+// ModTime returns the latest modification time of the files at paths.  Disk-backed files record the modification time
+// of the file on disk when they are added and virtual files the time they were created.
+func (f *Filesystem) ModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		var t int64
+		if err := f.db.Get(&t, "SELECT time FROM filesystem WHERE path = ?", utils.ParsePath(path).String()); err != nil {
+			return time.Time{}, fmt.Errorf("failed to query modification time of %s: %w", path, err)
+		}
+		if m := time.Unix(0, t); m.After(latest) {
+			latest = m
+		}
+	}
+	return latest, nil
+}
+
+// ModTimes returns the modification time recorded for every disk-backed file by path
+func (f *Filesystem) ModTimes() (map[string]time.Time, error) {
+	var rows []struct {
+		Path string
+		Time int64
+	}
+	if err := f.db.Select(&rows, "SELECT path, time FROM filesystem WHERE backing = 0"); err != nil {
+		return nil, fmt.Errorf("failed to query modification times: %w", err)
+	}
+	times := make(map[string]time.Time, len(rows))
+	for _, r := range rows {
+		times[r.Path] = time.Unix(0, r.Time)
+	}
+	return times, nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -56,6 +57,23 @@ func TestDiskBackedRead(t *testing.T) {
 	b2, err := fs.ReadFile("./test.dat")
 	require.NoError(t, err)
 	assert.Equal(t, testData, b2)
+
+	// it should record the modification time on disk
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "old.dat"), testData, 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(td, "old.dat"), mtime, mtime))
+	_, err = fs.Add("old.dat")
+	require.NoError(t, err)
+	m, err := fs.ModTime("old.dat")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(m))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	m, err = fs.ModTime("old.dat", "test.dat")
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime().Unix(), m.Unix())
+	_, err = fs.ModTime("missing.dat")
+	assert.Error(t, err)
 }
 
 func TestVirtualRead(t *testing.T) {
@@ -108,12 +126,19 @@ func TestFlush(t *testing.T) {
 	if _, err := fs.AddVirtual("test.dat", testData); err != nil {
 		require.NoError(t, err)
 	}
+	// directories of virtual files are created
+	if _, err := fs.AddVirtual("a/b/test.dat", testData); err != nil {
+		require.NoError(t, err)
+	}
 
 	require.NoError(t, fs.Flush())
 
 	got, err := os.ReadFile(filepath.Join(td, "test.dat"))
 	require.NoError(t, err)
 	assert.Equal(t, testData, got)
+	got, err = os.ReadFile(filepath.Join(td, "a", "b", "test.dat"))
+	require.NoError(t, err)
+	assert.Equal(t, testData, got)
 
 }
 
@@ -170,3 +195,61 @@ func TestReadDirSort(t *testing.T) {
 	require.Equal(t, len(trueSort), len(expSort))
 	assert.Equal(t, trueSort, expSort)
 }
+
+func TestDirModTime(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	fs, err := New(td)
+	require.NoError(t, err)
+	for _, p := range []string{"1.dat", "a/1.dat"} {
+		_, err = fs.AddVirtual(p, []byte("test"))
+		require.NoError(t, err)
+	}
+
+	entries, err := fs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.True(t, entries[1].IsDir())
+	info, err := entries[1].Info()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+}
+
+func TestRemove(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "page.md"), []byte("---\ntitle: Page\n---\nbody"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(td, "page.md"), mtime, mtime))
+	fs, err := New(td)
+	require.NoError(t, err)
+	_, err = fs.AddData("page.md")
+	require.NoError(t, err)
+	_, err = fs.AddVirtual("virtual.dat", []byte("virtual"))
+	require.NoError(t, err)
+
+	// virtual files have no modification time on disk
+	times, err := fs.ModTimes()
+	require.NoError(t, err)
+	assert.Len(t, times, 1)
+	assert.True(t, mtime.Equal(times["./page.md"]))
+
+	require.NoError(t, fs.Remove("page.md"))
+	assert.False(t, fs.Exists("page.md"))
+	m, err := fs.Metadata("page.md")
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	_, err = os.Stat(filepath.Join(td, "page.md"))
+	assert.NoError(t, err)
+
+	// a removed file can be added again
+	_, err = fs.AddData("page.md")
+	require.NoError(t, err)
+	m, err = fs.Metadata("page.md")
+	require.NoError(t, err)
+	assert.Equal(t, "Page", m["title"])
+}
@@ -0,0 +1,392 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/taevas/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// layoutDirectiveRE matches a {{/* layout: name */}} comment naming the layout of a target at the start of its body
+var layoutDirectiveRE = regexp.MustCompile(`\A\s*{{-?\s*/\*\s*layout:\s*([\w.-]+)\s*\*/\s*-?}}`)
+
+// Metadata holds the front matter of a template.  Scalar values are stored as text and lists or tables
+// as JSON.
+type Metadata map[string]string
+
+// Get returns the value at key or def when it is not set
+func (m Metadata) Get(key string, def string) string {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	return def
+}
+
+// List returns the values at key, which may be a single value, a comma separated list or a list in front matter
+func (m Metadata) List(key string) ([]string, error) {
+	values, err := parseAliases(m[key])
+	if err != nil {
+		return nil, fmt.Errorf("invalid list for %s: %w", key, err)
+	}
+	return values, nil
+}
+
+// ParseFrontMatter reads the front matter at the start of a template and returns its metadata along with
+// the length in bytes of the front matter block.  YAML front matter is delimited by --- lines:
+//
+//	---
+//	title: About us
+//	layout: base
+//	tags: [company, team]
+//	---
+//
+// and TOML style front matter by +++ lines with key = value pairs.  Templates without front matter
+// return nil metadata and a length of 0.
+func ParseFrontMatter(src []byte) (Metadata, int, error) {
+	m, _, body, err := parseFrontMatter(src)
+	return m, body, err
+}
+
+// parseFrontMatter returns the front matter both as metadata and with its values typed as they are written, along
+// with the length of the front matter block
+func parseFrontMatter(src []byte) (Metadata, map[string]interface{}, int, error) {
+	var delim string
+	switch {
+	case hasDelimiter(src, "---"):
+		delim = "---"
+	case hasDelimiter(src, "+++"):
+		delim = "+++"
+	default:
+		return nil, nil, 0, nil
+	}
+
+	start := bytes.IndexByte(src, '\n') + 1
+	end := start
+	for end < len(src) {
+		next := bytes.IndexByte(src[end:], '\n')
+		line := src[end:]
+		if next >= 0 {
+			line = src[end : end+next]
+		}
+		if string(bytes.TrimRight(line, " \t\r")) == delim {
+			body := end + len(line)
+			if next >= 0 {
+				body++
+			}
+			m, values, err := parseBlock(delim, src[start:end])
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			return m, values, body, nil
+		}
+		if next < 0 {
+			break
+		}
+		end += next + 1
+	}
+	return nil, nil, 0, fmt.Errorf("front matter is missing closing %s", delim)
+}
+
+func hasDelimiter(src []byte, delim string) bool {
+	if !bytes.HasPrefix(src, []byte(delim)) {
+		return false
+	}
+	rest := bytes.TrimLeft(src[len(delim):], " \t\r")
+	return len(rest) > 0 && rest[0] == '\n'
+}
+
+func parseBlock(delim string, block []byte) (Metadata, map[string]interface{}, error) {
+	if delim == "+++" {
+		return parseTOML(block)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(block, &values); err != nil {
+		return nil, nil, fmt.Errorf("invalid yaml front matter: %w", err)
+	}
+	m := make(Metadata, len(values))
+	for k, v := range values {
+		s, err := metadataValue(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid front matter value for %s: %w", k, err)
+		}
+		m[k] = s
+	}
+	return m, values, nil
+}
+
+// parseTOML supports the subset of TOML used for front matter: one key = value pair per line, where values are
+// strings, numbers, booleans or inline arrays, and # comments.  Tables are not supported.
+func parseTOML(block []byte) (Metadata, map[string]interface{}, error) {
+	m := make(Metadata)
+	values := make(map[string]interface{})
+	s := bufio.NewScanner(bytes.NewReader(block))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 1 {
+			return nil, nil, fmt.Errorf("invalid toml front matter on line %d: expected key = value", n)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"`)
+		value := strings.TrimSpace(line[eq+1:])
+		var typed interface{}
+		switch {
+		case strings.HasPrefix(value, `"`):
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid toml front matter on line %d: %w", n, err)
+			}
+			value, typed = v, v
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return nil, nil, fmt.Errorf("invalid toml front matter on line %d: unterminated string", n)
+			}
+			value = value[1 : len(value)-1]
+			typed = value
+		case strings.HasPrefix(value, "["):
+			// inline arrays share their syntax with YAML flow sequences
+			var list []interface{}
+			if err := yaml.Unmarshal([]byte(value), &list); err != nil {
+				return nil, nil, fmt.Errorf("invalid toml front matter on line %d: %w", n, err)
+			}
+			v, err := metadataValue(list)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid toml front matter on line %d: %w", n, err)
+			}
+			value, typed = v, list
+		default:
+			if i := strings.Index(value, "#"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			typed = tomlScalar(value)
+		}
+		m[key] = value
+		values[key] = typed
+	}
+	return m, values, s.Err()
+}
+
+// tomlScalar returns a bare TOML value as a boolean or number.  Any other value, such as a date, is kept as text.
+func tomlScalar(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64); err == nil {
+		return int(n)
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err == nil {
+		return f
+	}
+	return value
+}
+
+func metadataValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(val), nil
+	case time.Time:
+		return val.Format(time.RFC3339), nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// LayoutDirective returns the layout named by a {{/* layout: name */}} comment at the start of a template.  Only
+// whitespace may precede it, so that an example directive later in the body is not taken for one.
+func LayoutDirective(src []byte) (string, bool) {
+	m := layoutDirectiveRE.FindSubmatch(src)
+	if m == nil {
+		return "", false
+	}
+	return string(m[1]), true
+}
+
+// AddTemplate indexes a disk-backed template, storing any front matter as metadata.  Reads of the template return
+// only the body that follows the front matter.  A layout named by a {{/* layout: name */}} comment is stored as
+// the layout key unless the front matter already sets one.
+func (f *Filesystem) AddTemplate(name string) (int, error) {
+	src, err := os.ReadFile(filepath.Join(f.root, name))
+	if err != nil {
+		return -1, err
+	}
+	m, offset, err := ParseFrontMatter(src)
+	if err != nil {
+		return -1, fmt.Errorf("failed to parse front matter in %s: %w", name, err)
+	}
+	if layout, ok := LayoutDirective(src[offset:]); ok && m.Get("layout", "") == "" {
+		if m == nil {
+			m = make(Metadata)
+		}
+		m["layout"] = layout
+	}
+	id, err := f.Add(name)
+	if err != nil {
+		return -1, err
+	}
+	if len(m) == 0 {
+		return id, nil
+	}
+	if err := f.SetMetadata(id, offset, m); err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+// AddData indexes a disk-backed markdown or data file so that it can be queried as a collection item.  The front
+// matter of a .md file is stored as metadata and reads return only the body that follows it.  The top level keys of
+// a .json, .yaml or .yml object are stored as metadata, while data files holding a list, such as the entries of a
+// route with parameters, are indexed without metadata.
+func (f *Filesystem) AddData(name string) (int, error) {
+	src, err := os.ReadFile(filepath.Join(f.root, name))
+	if err != nil {
+		return -1, err
+	}
+	var m Metadata
+	var offset int
+	switch filepath.Ext(name) {
+	case ".md":
+		m, offset, err = ParseFrontMatter(src)
+	case ".json", ".yaml", ".yml":
+		m, err = parseData(src)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	id, err := f.Add(name)
+	if err != nil {
+		return -1, err
+	}
+	if len(m) == 0 {
+		return id, nil
+	}
+	if err := f.SetMetadata(id, offset, m); err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+// parseData returns the top level keys of a JSON or YAML object as metadata, or nil metadata for any other value
+func parseData(src []byte) (Metadata, error) {
+	// JSON is parsed as YAML, which it is a subset of
+	var data interface{}
+	if err := yaml.Unmarshal(src, &data); err != nil {
+		return nil, fmt.Errorf("invalid data: %w", err)
+	}
+	values, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	m := make(Metadata, len(values))
+	for k, v := range values {
+		s, err := metadataValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", k, err)
+		}
+		m[k] = s
+	}
+	return m, nil
+}
+
+// SetMetadata stores the front matter of the file with the given id.  The offset is the length of the front matter
+// block that is skipped when reading a disk-backed file.  Virtual files should be added without their front matter
+// and an offset of 0.
+func (f *Filesystem) SetMetadata(id int, offset int, m Metadata) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.db.Exec("UPDATE fs SET body_offset = ? WHERE id = ? AND backing = 0", offset, id); err != nil {
+		return fmt.Errorf("failed to set body offset: %w", err)
+	}
+	for key, value := range m {
+		if _, err := f.db.Exec("INSERT INTO metadata (fs_id, key, value) VALUES (?, ?, ?) ON CONFLICT(fs_id, key) DO UPDATE SET value = excluded.value", id, key, value); err != nil {
+			return fmt.Errorf("failed to set metadata %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// FrontMatter returns the front matter of a disk-backed file with its values typed as they are written, e.g. as
+// booleans, numbers or lists, rather than as the text stored as metadata.  Virtual files and files without front
+// matter return nil.
+func (f *Filesystem) FrontMatter(path string) (map[string]interface{}, error) {
+	src, err := f.frontMatterSource(path)
+	if err != nil || src == nil {
+		return nil, err
+	}
+	_, values, _, err := parseFrontMatter(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse front matter in %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// FrontMatterLines returns the number of lines of front matter that reads of a disk-backed file skip, which is added
+// to a line number in the body to find the line in the file
+func (f *Filesystem) FrontMatterLines(path string) (int, error) {
+	src, err := f.frontMatterSource(path)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Count(src, []byte("\n")), nil
+}
+
+// frontMatterSource returns the front matter block of a disk-backed file as it is on disk, or nil when reads of the
+// file skip nothing
+func (f *Filesystem) frontMatterSource(path string) ([]byte, error) {
+	file, err := f.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e := file.(*Entry)
+	if e.Backing != 0 || e.BodyOffset == 0 {
+		return nil, nil
+	}
+	fh, err := os.Open(filepath.Join(f.root, path))
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	src := make([]byte, e.BodyOffset)
+	if _, err := io.ReadFull(fh, src); err != nil {
+		return nil, fmt.Errorf("failed to read front matter of %s: %w", path, err)
+	}
+	return src, nil
+}
+
+// Metadata returns the front matter of the file at path.  Files without front matter return empty metadata.
+func (f *Filesystem) Metadata(path string) (Metadata, error) {
+	var rows []struct {
+		Key   string
+		Value string
+	}
+	if err := f.db.Select(&rows, "SELECT m.key, m.value FROM metadata m JOIN filesystem f ON f.id = m.fs_id WHERE f.path = ?", utils.ParsePath(path).String()); err != nil {
+		return nil, fmt.Errorf("failed to query metadata for %s: %w", path, err)
+	}
+	m := make(Metadata, len(rows))
+	for _, r := range rows {
+		m[r.Key] = r.Value
+	}
+	return m, nil
+}
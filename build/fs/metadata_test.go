@@ -0,0 +1,191 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tt := []struct {
+		name      string
+		src       string
+		expect    Metadata
+		body      string
+		shouldErr bool
+	}{
+		{name: "none", src: "<p>---</p>", body: "<p>---</p>"},
+		{name: "yaml", src: "---\ntitle: About us\ncache: 5m\ndraft: true\ntags: [a, b]\n---\n<p>x</p>", expect: Metadata{"title": "About us", "cache": "5m", "draft": "true", "tags": `["a","b"]`}, body: "<p>x</p>"},
+		{name: "yaml crlf", src: "--- \r\nlayout: base\r\n---\r\nbody", expect: Metadata{"layout": "base"}, body: "body"},
+		{name: "yaml nested", src: "---\ndata:\n  type: Post\n---\n", expect: Metadata{"data": `{"type":"Post"}`}, body: ""},
+		{name: "toml", src: "+++\n# comment\ntitle = \"Hello \\\"world\\\"\"\nroute = '/about'\nweight = 10 # order\ntags = [\"a\", \"b\"]\n+++\nbody", expect: Metadata{"title": `Hello "world"`, "route": "/about", "weight": "10", "tags": `["a","b"]`}, body: "body"},
+		{name: "unterminated", src: "---\ntitle: x\n", shouldErr: true},
+		{name: "invalid toml", src: "+++\n[table]\n+++\n", shouldErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m, offset, err := ParseFrontMatter([]byte(tc.src))
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, m)
+			assert.Equal(t, tc.body, tc.src[offset:])
+		})
+	}
+}
+
+func TestMetadataList(t *testing.T) {
+	m := Metadata{"one": "/a", "comma": "/a, /b", "list": `["/a","/b"]`, "invalid": "[/a"}
+	for key, expect := range map[string][]string{"one": {"/a"}, "comma": {"/a", "/b"}, "list": {"/a", "/b"}, "missing": nil} {
+		values, err := m.List(key)
+		require.NoError(t, err)
+		assert.Equal(t, expect, values, "failed for %s", key)
+	}
+	_, err := m.List("invalid")
+	assert.Error(t, err)
+}
+
+func TestAddTemplate(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "a"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "a", "index.layout.tmpl"), []byte("---\ntitle: Home\nroute: /\n---\n<p>home</p>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "a", "post.md"), []byte("+++\ndraft = false\nweight = 2.5\ntags = [\"a\"]\ndate = 2021-01-02\n+++\nbody"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "_layout.tmpl"), []byte("<html></html>"), 0644))
+
+	fs, err := New(td)
+	require.NoError(t, err)
+	_, err = fs.AddTemplate("a/index.layout.tmpl")
+	require.NoError(t, err)
+	_, err = fs.AddTemplate("_layout.tmpl")
+	require.NoError(t, err)
+
+	// reads skip the front matter
+	b, err := fs.ReadFile("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "<p>home</p>", string(b))
+	b, err = fs.ReadFile("_layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(b))
+
+	m, err := fs.Metadata("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{"title": "Home", "route": "/"}, m)
+	assert.Equal(t, "default", m.Get("cache", "default"))
+
+	m, err = fs.Metadata("_layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(m))
+
+	// front matter keeps the types of its values
+	values, err := fs.FrontMatter("a/index.layout.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"title": "Home", "route": "/"}, values)
+	values, err = fs.FrontMatter("_layout.tmpl")
+	require.NoError(t, err)
+	assert.Nil(t, values)
+	_, err = fs.AddData("a/post.md")
+	require.NoError(t, err)
+	values, err = fs.FrontMatter("a/post.md")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"draft": false, "weight": 2.5, "tags": []interface{}{"a"}, "date": "2021-01-02"}, values)
+
+	var titles []string
+	require.NoError(t, fs.db.Select(&titles, "SELECT path || ':' || value FROM template_metadata WHERE key = 'title'"))
+	assert.Equal(t, []string{"a/index.layout.tmpl:Home"}, titles)
+}
+
+func TestLayoutDirective(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	files := map[string]string{
+		"comment.tmpl":  "{{- /* layout: admin */ -}}\n<p>x</p>",
+		"override.tmpl": "---\nlayout: base\n---\n{{/* layout: admin */}}",
+	}
+	fs, err := New(td)
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(td, name), []byte(content), 0644))
+		_, err := fs.AddTemplate(name)
+		require.NoError(t, err)
+	}
+
+	// front matter takes precedence over the comment
+	for name, expect := range map[string]string{"comment.tmpl": "admin", "override.tmpl": "base"} {
+		m, err := fs.Metadata(name)
+		require.NoError(t, err)
+		assert.Equal(t, expect, m["layout"], "failed for %s", name)
+	}
+
+	_, ok := LayoutDirective([]byte("{{/* a comment */}}"))
+	assert.False(t, ok)
+
+	layout, ok := LayoutDirective([]byte("\n  {{/* layout: admin */}}"))
+	assert.True(t, ok)
+	assert.Equal(t, "admin", layout)
+	_, ok = LayoutDirective([]byte("<p>x</p>\n{{/* layout: admin */}}"))
+	assert.False(t, ok)
+}
+
+func TestCollection(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	files := map[string]string{
+		"posts/first.md":          "---\ntitle: First\ndate: 2022-01-02\ndraft: false\n---\nHello",
+		"posts/second.md":         "---\ntitle: Second\ndate: 2022-03-01\nslug: two\ndraft: false\n---\nWorld",
+		"posts/wip.md":            "---\ntitle: WIP\ndate: 2022-04-01\ndraft: true\n---\n",
+		"posts/third.json":        `{"title": "Third", "date": "2022-02-01", "draft": false, "tags": ["go"]}`,
+		"posts/list.yaml":         "- a\n- b\n",
+		"posts/old/post.md":       "nested",
+		"posts/index.layout.tmpl": "",
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "posts", "old"), 0755))
+	fs, err := New(td)
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(td, name), []byte(content), 0644))
+		if filepath.Ext(name) == ".tmpl" {
+			_, err = fs.AddTemplate(name)
+		} else {
+			_, err = fs.AddData(name)
+		}
+		require.NoError(t, err)
+	}
+
+	b, err := fs.ReadFile("posts/first.md")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(b))
+
+	items, err := fs.Collection("posts", CollectionQuery{Where: map[string]string{"draft": "false"}, Sort: "date", Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Item{
+		{Path: "posts/second.md", Slug: "two", Metadata: Metadata{"title": "Second", "date": "2022-03-01T00:00:00Z", "slug": "two", "draft": "false"}},
+		{Path: "posts/third.json", Slug: "third", Metadata: Metadata{"title": "Third", "date": "2022-02-01", "draft": "false", "tags": `["go"]`}},
+		{Path: "posts/first.md", Slug: "first", Metadata: Metadata{"title": "First", "date": "2022-01-02T00:00:00Z", "draft": "false"}},
+	}, items)
+
+	// without a query every item in the directory is returned by file name
+	items, err = fs.Collection("./posts/", CollectionQuery{})
+	require.NoError(t, err)
+	var slugs []string
+	for _, i := range items {
+		slugs = append(slugs, i.Slug)
+	}
+	assert.Equal(t, []string{"first", "list", "two", "third", "wip"}, slugs)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(td, "invalid.json"), []byte(`{"a":`), 0644))
+	_, err = fs.AddData("invalid.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BTBurke/taevas/utils"
+)
+
+// TemplateName returns the name a template is parsed under along with any aliases from the alias key of its front
+// matter.  Templates are named by their file name, except for locals and globals when partial names are set to
+// path, which are named by their root relative path without the extension, e.g. components/button.
+func (f *Filesystem) TemplateName(path string) (string, []string, error) {
+	p := utils.ParsePath(path)
+	var t struct {
+		Partial      bool   `db:"partial"`
+		Ext          string `db:"ext"`
+		PartialNames string `db:"partial_names"`
+		Aliases      string `db:"aliases"`
+	}
+	if err := f.db.Get(&t, `SELECT
+		(t.id IN (SELECT id FROM locals) OR t.id IN (SELECT id FROM globals)) as partial,
+		t.ext,
+		n.partial_names,
+		COALESCE((SELECT value FROM metadata m WHERE m.fs_id = t.id AND m.key = 'alias'), '') as aliases
+		FROM templates t, naming n WHERE t.dir || '/' || t.filename = ?`, p.String()); err != nil {
+		return "", nil, fmt.Errorf("failed to query template name of %s: %w", path, err)
+	}
+
+	name := p.FileName()
+	if t.Partial && t.PartialNames == "path" {
+		name = strings.TrimSuffix(p.RootRelative(), t.Ext)
+	}
+	aliases, err := parseAliases(t.Aliases)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid alias in %s: %w", path, err)
+	}
+	return name, aliases, nil
+}
+
+// parseAliases accepts a single alias, a comma separated list or a list in front matter
+func parseAliases(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "[") {
+		var aliases []string
+		if err := json.Unmarshal([]byte(value), &aliases); err != nil {
+			return nil, err
+		}
+		return aliases, nil
+	}
+	var aliases []string
+	for _, a := range strings.Split(value, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases, nil
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateName(t *testing.T) {
+	for _, namespaced := range []bool{false, true} {
+		fs, err := New("/test")
+		require.NoError(t, err)
+		if namespaced {
+			require.NoError(t, fs.SetConfig("partial_names", "path"))
+		}
+		meta := map[string]Metadata{
+			"components/button.tmpl": {"alias": "button"},
+			"admin/button.tmpl":      {"alias": `["abutton", "admin-button"]`},
+		}
+		for _, f := range []string{"_base.tmpl", "components/button.tmpl", "admin/button.tmpl", "admin/index.base.tmpl"} {
+			id, err := fs.Add(f)
+			require.NoError(t, err)
+			if m, ok := meta[f]; ok {
+				require.NoError(t, fs.SetMetadata(id, 0, m))
+			}
+		}
+
+		expect := map[string]string{
+			"./_base.tmpl":           "_base.tmpl",
+			"components/button.tmpl": "button.tmpl",
+			"admin/button.tmpl":      "button.tmpl",
+			"admin/index.base.tmpl":  "index.base.tmpl",
+		}
+		if namespaced {
+			expect["components/button.tmpl"] = "components/button"
+			expect["admin/button.tmpl"] = "admin/button"
+		}
+		for path, name := range expect {
+			n, _, err := fs.TemplateName(path)
+			require.NoError(t, err)
+			assert.Equal(t, name, n, "failed for %s", path)
+		}
+
+		_, aliases, err := fs.TemplateName("admin/button.tmpl")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abutton", "admin-button"}, aliases)
+		_, aliases, err = fs.TemplateName("components/button.tmpl")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"button"}, aliases)
+	}
+}
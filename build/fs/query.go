@@ -0,0 +1,303 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BTBurke/taevas/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+// Targets returns the paths of all target templates, ordered by directory
+func (f *Filesystem) Targets() ([]string, error) {
+	var targets []string
+	if err := f.db.Select(&targets, "SELECT dir || '/' || filename FROM targets ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query targets: %w", err)
+	}
+	return targets, nil
+}
+
+// TargetTree returns the ordered list of templates that must be parsed to render the target
+func (f *Filesystem) TargetTree(target string) ([]string, error) {
+	var tree []string
+	if err := f.db.Select(&tree, "SELECT template_path FROM target_tree WHERE target_path = ?", target); err != nil {
+		return nil, fmt.Errorf("failed to query template tree for %s: %w", target, err)
+	}
+	return tree, nil
+}
+
+// Dependents returns the targets whose template tree includes any of the templates at paths, in order.  A target is
+// in its own tree.
+func (f *Filesystem) Dependents(paths ...string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, len(paths))
+	for i, p := range paths {
+		normalized[i] = utils.ParsePath(p).String()
+	}
+	query, args, err := sqlx.In("SELECT DISTINCT target_path FROM target_tree WHERE template_path IN (?) ORDER BY target_path", normalized)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	if err := f.db.Select(&targets, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to query dependents: %w", err)
+	}
+	return targets, nil
+}
+
+// AllTemplates returns the set of all templates needed to render every target
+func (f *Filesystem) AllTemplates() ([]string, error) {
+	var templates []string
+	if err := f.db.Select(&templates, "SELECT DISTINCT template_path FROM all_templates ORDER BY template_path"); err != nil {
+		return nil, fmt.Errorf("failed to query templates: %w", err)
+	}
+	return templates, nil
+}
+
+// Layouts returns the paths of all layout templates
+func (f *Filesystem) Layouts() ([]string, error) {
+	var layouts []string
+	if err := f.db.Select(&layouts, "SELECT dir || '/' || filename FROM layouts ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query layouts: %w", err)
+	}
+	return layouts, nil
+}
+
+// Exists reports whether a file has been indexed at path
+func (f *Filesystem) Exists(path string) bool {
+	var n int
+	if err := f.db.Get(&n, "SELECT COUNT(*) FROM filesystem WHERE path = ?", utils.ParsePath(path).String()); err != nil {
+		return false
+	}
+	return n > 0
+}
+
+// LayoutCycles returns every layout inheritance cycle as the chain of layouts from the first layout in the cycle back
+// to itself, e.g. [a/_a.b.tmpl a/_b.a.tmpl a/_a.b.tmpl]
+func (f *Filesystem) LayoutCycles() ([][]string, error) {
+	var rows []struct {
+		LayoutPath string `db:"layout_path"`
+		Chain      string
+	}
+	if err := f.db.Select(&rows, "SELECT layout_path, chain FROM layout_cycles ORDER BY layout_path"); err != nil {
+		return nil, fmt.Errorf("failed to query layout cycles: %w", err)
+	}
+
+	// every layout in a cycle yields the same cycle, so only the one starting at the first layout by path is kept
+	var cycles [][]string
+	for _, r := range rows {
+		chain := strings.Split(r.Chain, "\n")
+		first := true
+		for _, l := range chain {
+			if l < r.LayoutPath {
+				first = false
+				break
+			}
+		}
+		if first {
+			cycles = append(cycles, chain)
+		}
+	}
+	return cycles, nil
+}
+
+// UnmatchedTarget is a target whose layout does not exist
+type UnmatchedTarget struct {
+	Path   string `db:"target_path"`
+	Layout string `db:"layout_name"`
+}
+
+// UnmatchedTargets returns targets whose layout short name matches no layout in scope
+func (f *Filesystem) UnmatchedTargets() ([]UnmatchedTarget, error) {
+	var targets []UnmatchedTarget
+	if err := f.db.Select(&targets, "SELECT target_path, COALESCE(layout_name, '') as layout_name FROM unmatched_targets ORDER BY target_path"); err != nil {
+		return nil, fmt.Errorf("failed to query unmatched targets: %w", err)
+	}
+	return targets, nil
+}
+
+// Globals returns the paths of all global templates
+func (f *Filesystem) Globals() ([]string, error) {
+	var globals []string
+	if err := f.db.Select(&globals, "SELECT dir || '/' || filename FROM globals ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query globals: %w", err)
+	}
+	return globals, nil
+}
+
+// Locals returns the paths of all local templates
+func (f *Filesystem) Locals() ([]string, error) {
+	var locals []string
+	if err := f.db.Select(&locals, "SELECT dir || '/' || filename FROM locals ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query locals: %w", err)
+	}
+	return locals, nil
+}
+
+// Orphans returns the paths of templates that are not a layout, target, local or global, such as templates outside
+// the globals directory in directories without targets
+func (f *Filesystem) Orphans() ([]string, error) {
+	var orphans []string
+	if err := f.db.Select(&orphans, "SELECT dir || '/' || filename FROM orphans ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query orphaned templates: %w", err)
+	}
+	return orphans, nil
+}
+
+// UnusedLayouts returns the paths of layouts that are not in the template tree of any target
+func (f *Filesystem) UnusedLayouts() ([]string, error) {
+	var layouts []string
+	if err := f.db.Select(&layouts, "SELECT dir || '/' || filename FROM unused_layouts ORDER BY dir, filename"); err != nil {
+		return nil, fmt.Errorf("failed to query unused layouts: %w", err)
+	}
+	return layouts, nil
+}
+
+// Template types returned by TemplateType
+const (
+	TypeHTML = "html"
+	TypeText = "text"
+)
+
+// TemplateType returns whether the template at path is parsed with html/template or text/template
+func (f *Filesystem) TemplateType(path string) (string, error) {
+	var typ string
+	if err := f.db.Get(&typ, "SELECT type FROM templates WHERE dir || '/' || filename = ?", utils.ParsePath(path).String()); err != nil {
+		return "", fmt.Errorf("failed to query template type of %s: %w", path, err)
+	}
+	return typ, nil
+}
+
+// Parts of an email template
+const (
+	PartHTML = "html"
+	PartText = "text"
+)
+
+// Email is a pair of html and text templates rendered together.  Either part may be empty when the template for it does
+// not exist.
+type Email struct {
+	Dir  string
+	Name string
+	HTML string
+	Text string
+}
+
+// Emails returns every email template pair, ordered by directory and name
+func (f *Filesystem) Emails() ([]Email, error) {
+	var rows []struct {
+		Dir      string
+		Filename string
+		Name     string
+		Part     string
+	}
+	if err := f.db.Select(&rows, "SELECT dir, filename, name, part FROM emails ORDER BY dir, name, part"); err != nil {
+		return nil, fmt.Errorf("failed to query emails: %w", err)
+	}
+
+	var emails []Email
+	for _, r := range rows {
+		if len(emails) == 0 || emails[len(emails)-1].Dir != r.Dir || emails[len(emails)-1].Name != r.Name {
+			emails = append(emails, Email{Dir: r.Dir, Name: r.Name})
+		}
+		e := &emails[len(emails)-1]
+		path := utils.NewPath(r.Dir, r.Filename).String()
+		switch r.Part {
+		case PartHTML:
+			if e.HTML != "" {
+				return nil, fmt.Errorf("email %s has two html parts %s and %s", r.Name, e.HTML, path)
+			}
+			e.HTML = path
+		default:
+			if e.Text != "" {
+				return nil, fmt.Errorf("email %s has two text parts %s and %s", r.Name, e.Text, path)
+			}
+			e.Text = path
+		}
+	}
+	return emails, nil
+}
+
+// Route is the URL path that serves a target
+type Route struct {
+	Target string `db:"target_path"`
+	Route  string `db:"route"`
+	// Type is TypeHTML or TypeText
+	Type string `db:"type"`
+}
+
+// Routes returns the route of every target, ordered by route and target
+func (f *Filesystem) Routes() ([]Route, error) {
+	var routes []Route
+	if err := f.db.Select(&routes, "SELECT target_path, route, type FROM routes ORDER BY route, target_path"); err != nil {
+		return nil, fmt.Errorf("failed to query routes: %w", err)
+	}
+	return routes, nil
+}
+
+// CollectionQuery filters and orders the items of a collection
+type CollectionQuery struct {
+	// Where keeps only items whose metadata has the value at each key
+	Where map[string]string
+	// Sort orders items by the value of a metadata key and then by file name.  Values are compared as text, so dates
+	// should be written as YYYY-MM-DD.
+	Sort string
+	// Desc reverses the order
+	Desc bool
+}
+
+// Item is a markdown or data file in a collection
+type Item struct {
+	Path     string
+	Slug     string
+	Metadata Metadata
+}
+
+// Collection returns the items in dir, not including its subdirectories, that match the query
+func (f *Filesystem) Collection(dir string, q CollectionQuery) ([]Item, error) {
+	query := "SELECT dir, filename, slug FROM collection_items c WHERE dir = ?"
+	args := []interface{}{utils.ParsePath(dir).Dir()}
+
+	keys := make([]string, 0, len(q.Where))
+	for key := range q.Where {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query += " AND EXISTS (SELECT 1 FROM metadata m WHERE m.fs_id = c.id AND m.key = ? AND m.value = ?)"
+		args = append(args, key, q.Where[key])
+	}
+
+	order := "ASC"
+	if q.Desc {
+		order = "DESC"
+	}
+	if q.Sort != "" {
+		query += fmt.Sprintf(" ORDER BY (SELECT value FROM metadata m WHERE m.fs_id = c.id AND m.key = ?) %s, filename %s", order, order)
+		args = append(args, q.Sort)
+	} else {
+		query += " ORDER BY filename " + order
+	}
+
+	var rows []struct {
+		Dir      string
+		Filename string
+		Slug     string
+	}
+	if err := f.db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to query collection %s: %w", dir, err)
+	}
+	items := make([]Item, 0, len(rows))
+	for _, r := range rows {
+		path := utils.NewPath(r.Dir, r.Filename).String()
+		m, err := f.Metadata(path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, Item{Path: path, Slug: r.Slug, Metadata: m})
+	}
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/i18n"
+)
+
+// ExtractMessages collects the translatable messages from every template and merges them into the
+// catalog for each locale.  Existing translations are preserved.  The updated catalogs are saved to the
+// catalog directory and returned.
+func (c *Context) ExtractMessages(locales ...string) ([]*i18n.Catalog, error) {
+	msgs, err := i18n.Extract(c.InputFS)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*i18n.Catalog, len(locales))
+	for i, locale := range locales {
+		cat, err := i18n.LoadCatalog(c.opts.catalogDir, locale)
+		if err != nil {
+			return nil, err
+		}
+		cat.Merge(msgs)
+		if err := cat.Save(c.opts.catalogDir); err != nil {
+			return nil, err
+		}
+		out[i] = cat
+	}
+	return out, nil
+}
+
+// Localize compiles a translated copy of the template set for each locale into OutputFS, keyed by locale.
+// Each locale is written to its own subdirectory of the output directory when flushed.  Translations missing
+// from a locale's catalog are taken from the catalogs along its fallback chain (pt-BR -> pt -> en), and
+// messages missing from every catalog are returned per locale and fall back to the source text.
+func (c *Context) Localize(locales ...string) (map[string][]i18n.Message, error) {
+	missing := make(map[string][]i18n.Message)
+	for _, locale := range locales {
+		cat, err := i18n.LoadCatalogChain(c.opts.catalogDir, locale)
+		if err != nil {
+			return nil, err
+		}
+		out, err := fs.New(filepath.Join(c.opts.outDir, locale))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output filesystem for %s: %w", locale, err)
+		}
+		if err := c.opts.configure(out); err != nil {
+			return nil, err
+		}
+		m, err := i18n.Localize(c.InputFS, out, cat)
+		if err != nil {
+			return nil, err
+		}
+		c.OutputFS[locale] = out
+		if len(m) > 0 {
+			missing[locale] = m
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Catalog holds the translations for a single locale, keyed by message ID.  Untranslated messages have
+// an empty translation.
+type Catalog struct {
+	Locale   string
+	Messages map[string]string
+}
+
+// NewCatalog returns an empty catalog for the locale
+func NewCatalog(locale string) *Catalog {
+	return &Catalog{
+		Locale:   locale,
+		Messages: make(map[string]string),
+	}
+}
+
+// LoadCatalog reads the catalog for locale from <dir>/<locale>.json.  A missing file results in an empty catalog.
+func LoadCatalog(dir string, locale string) (*Catalog, error) {
+	c := NewCatalog(locale)
+	b, err := os.ReadFile(catalogPath(dir, locale))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read catalog for %s: %w", locale, err)
+	}
+	if err := json.Unmarshal(b, &c.Messages); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog for %s: %w", locale, err)
+	}
+	return c, nil
+}
+
+// LoadCatalogChain loads the catalog for locale and fills in any missing translations from the catalogs
+// along its fallback chain, e.g. pt-BR falls back to pt and then the default locale
+func LoadCatalogChain(dir string, locale string) (*Catalog, error) {
+	var c *Catalog
+	for _, l := range Fallback(locale) {
+		next, err := LoadCatalog(dir, l)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			c = next
+			continue
+		}
+		for id, t := range next.Messages {
+			if existing := c.Messages[id]; existing == "" {
+				c.Messages[id] = t
+			}
+		}
+	}
+	return c, nil
+}
+
+// Merge adds any new messages to the catalog with an empty translation, leaving existing translations
+// untouched.  It returns the IDs of the messages that were added.
+func (c *Catalog) Merge(msgs []Message) []string {
+	var added []string
+	for _, m := range msgs {
+		if _, ok := c.Messages[m.ID]; !ok {
+			c.Messages[m.ID] = ""
+			added = append(added, m.ID)
+		}
+	}
+	return added
+}
+
+// Lookup returns the translation for the message ID if one exists
+func (c *Catalog) Lookup(id string) (string, bool) {
+	t, ok := c.Messages[id]
+	return t, ok && t != ""
+}
+
+// Missing returns the sorted IDs of all messages without a translation
+func (c *Catalog) Missing() []string {
+	var out []string
+	for id, t := range c.Messages {
+		if t == "" {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Save writes the catalog to <dir>/<locale>.json, creating the directory if necessary
+func (c *Catalog) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+	// map keys are sorted when marshaled, which keeps catalog diffs stable.  Markup is left unescaped so
+	// that catalogs remain readable by translators.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c.Messages); err != nil {
+		return fmt.Errorf("failed to encode catalog for %s: %w", c.Locale, err)
+	}
+	if err := os.WriteFile(catalogPath(dir, c.Locale), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write catalog for %s: %w", c.Locale, err)
+	}
+	return nil
+}
+
+func catalogPath(dir string, locale string) string {
+	return filepath.Join(dir, locale+".json")
+}
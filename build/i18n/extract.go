@@ -0,0 +1,282 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"golang.org/x/net/html"
+)
+
+// Attr marks an element whose content should be translated.  If the attribute has a value, it is used
+// as the message ID instead of the element content.  Void elements such as <img> and <input> have their alt,
+// placeholder, title and aria-label attributes translated instead.
+const Attr = "data-i18n"
+
+// Message is a translatable string and the templates in which it was found
+type Message struct {
+	ID        string
+	Locations []string
+}
+
+var (
+	// template actions, including trimmed {{- -}} forms
+	actionRE = regexp.MustCompile(`(?s){{.*?}}`)
+	// a t function call with an interpreted or raw string literal as the first argument
+	tCallRE = regexp.MustCompile("(^|[\\s(|{-])t\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+)
+
+// Extract walks every template needed to render a target and returns all translatable messages sorted by ID
+func Extract(in *fs.Filesystem) ([]Message, error) {
+	templates, err := in.AllTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make(map[string][]string)
+	for _, template := range templates {
+		b, err := in.ReadFile(template)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := extract(template, b)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			locations[id] = appendUnique(locations[id], template)
+		}
+	}
+
+	out := make([]Message, 0, len(locations))
+	for id, locs := range locations {
+		out = append(out, Message{ID: id, Locations: locs})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// extract returns the message IDs in a single template in the order they appear
+func extract(template string, src []byte) ([]string, error) {
+	var ids []string
+	for _, action := range actionRE.FindAll(src, -1) {
+		for _, m := range tCallRE.FindAllSubmatch(action, -1) {
+			id, err := strconv.Unquote(string(m[2]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid message in %s: %w", template, err)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	_, err := rewriteElements(src, func(id string, _ []byte) []byte {
+		if id != "" {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract messages from %s: %w", template, err)
+	}
+	return ids, nil
+}
+
+// rewriteElements calls fn for every element marked with the i18n attribute, passing the message ID and the
+// raw inner content of the element.  The template is returned with the inner content of each element
+// replaced by the result of fn, or unchanged when fn returns nil.  Void and self-closing elements have no content,
+// so fn is called for each of their translatable attributes instead.
+func rewriteElements(src []byte, fn func(id string, inner []byte) []byte) ([]byte, error) {
+	var out, inner bytes.Buffer
+	var id string
+	var tag string
+	depth := 0
+
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return nil, z.Err()
+			}
+			if depth > 0 {
+				return nil, fmt.Errorf("element <%s %s> is not closed", tag, Attr)
+			}
+			return out.Bytes(), nil
+		}
+		raw := z.Raw()
+
+		if depth == 0 {
+			if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+				out.Write(raw)
+				continue
+			}
+			// raw is reused by the tokenizer when reading attributes
+			raw = append([]byte(nil), raw...)
+			name, hasAttr := z.TagName()
+			if !hasAttr {
+				out.Write(raw)
+				continue
+			}
+			attrs := attrs(z)
+			v, ok := attrs[Attr]
+			switch {
+			case !ok:
+				out.Write(raw)
+			case tt == html.SelfClosingTagToken || voidElements[string(name)]:
+				out.Write(rewriteAttrs(raw, v, attrs, fn))
+			default:
+				out.Write(raw)
+				tag = string(name)
+				id = v
+				depth = 1
+				inner.Reset()
+			}
+			continue
+		}
+
+		name, _ := z.TagName()
+		switch {
+		case tt == html.StartTagToken && string(name) == tag:
+			depth++
+		case tt == html.EndTagToken && string(name) == tag:
+			depth--
+		}
+		if depth > 0 {
+			inner.Write(raw)
+			continue
+		}
+
+		// element closed, replace the inner content if necessary
+		content := inner.Bytes()
+		msg := id
+		if msg == "" {
+			msg = collapse(string(content))
+		}
+		if replaced := fn(msg, content); replaced != nil {
+			out.Write(replaced)
+		} else {
+			out.Write(content)
+		}
+		out.Write(raw)
+	}
+}
+
+// voidElements have no end tag, so marking one translates its attributes
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// translatedAttrs are the attributes of a marked void element that are translated, in the order in which an
+// explicit message ID applies to them
+var translatedAttrs = []string{"alt", "placeholder", "title", "aria-label"}
+
+// rewriteAttrs calls fn for the translatable attributes of a marked void element and returns its raw tag with their
+// values replaced.  The message ID of the first attribute is the value of the i18n attribute when it has one, and
+// otherwise the attribute text.  Attributes containing template actions are left as they are.
+func rewriteAttrs(raw []byte, id string, attrs map[string]string, fn func(id string, inner []byte) []byte) []byte {
+	for _, key := range translatedAttrs {
+		v, ok := attrs[key]
+		if !ok || strings.Contains(v, "{{") {
+			continue
+		}
+		msg := id
+		if msg == "" {
+			msg = collapse(v)
+		}
+		id = ""
+		if msg == "" {
+			continue
+		}
+		replaced := fn(msg, []byte(v))
+		if replaced == nil {
+			continue
+		}
+		if start, end, ok := attrValue(raw, key); ok {
+			value := `"` + html.EscapeString(string(replaced)) + `"`
+			raw = append(append(append([]byte(nil), raw[:start]...), value...), raw[end:]...)
+		}
+	}
+	return raw
+}
+
+// attrValue returns the span of the value of an attribute in a raw start tag, including any quotes
+func attrValue(raw []byte, key string) (int, int, bool) {
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+	i := bytes.IndexFunc(raw, func(r rune) bool { return r <= ' ' || r == '/' || r == '>' })
+	if i < 0 {
+		return 0, 0, false
+	}
+	for i < len(raw) {
+		for i < len(raw) && (isSpace(raw[i]) || raw[i] == '/') {
+			i++
+		}
+		start := i
+		for i < len(raw) && !isSpace(raw[i]) && raw[i] != '=' && raw[i] != '>' && raw[i] != '/' {
+			i++
+		}
+		if i == start {
+			return 0, 0, false
+		}
+		name := strings.ToLower(string(raw[start:i]))
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		if i >= len(raw) || raw[i] != '=' {
+			continue
+		}
+		i++
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		vstart := i
+		if i < len(raw) && (raw[i] == '"' || raw[i] == '\'') {
+			end := bytes.IndexByte(raw[i+1:], raw[i])
+			if end < 0 {
+				return 0, 0, false
+			}
+			i += end + 2
+		} else {
+			for i < len(raw) && !isSpace(raw[i]) && raw[i] != '>' {
+				i++
+			}
+		}
+		if name == key {
+			return vstart, i, true
+		}
+	}
+	return 0, 0, false
+}
+
+// attrs returns the attributes of the current tag by lower case name
+func attrs(z *html.Tokenizer) map[string]string {
+	out := make(map[string]string)
+	for {
+		k, v, more := z.TagAttr()
+		if len(k) > 0 {
+			out[string(k)] = strings.TrimSpace(string(v))
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+// collapse trims and collapses runs of whitespace so that reformatting a template doesn't change the message ID
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, existing := range s {
+		if existing == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,264 @@
+package i18n
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter formats numbers, currencies, dates and plurals for a single locale.  Locale data is resolved
+// along the fallback chain, so an unknown locale formats like its language or the default locale.
+type Formatter struct {
+	Locale string
+	// Now returns the current time used as the base for relative times
+	Now func() time.Time
+
+	data       localeData
+	categories []string
+	rule       pluralRule
+}
+
+// NewFormatter returns a formatter for the locale
+func NewFormatter(locale string) *Formatter {
+	categories, rule := pluralRules(locale)
+	return &Formatter{
+		Locale:     Canonical(locale),
+		Now:        time.Now,
+		data:       resolve(locale),
+		categories: categories,
+		rule:       rule,
+	}
+}
+
+// Funcs returns the template functions for the locale.  Generated render functions pass the locale of
+// the request so that every function formats for the same locale.
+//
+//	{{ t "Hello %s" .Name }}
+//	{{ plural .Count "%d item" "%d items" }}
+//	{{ number 1234.5 }} {{ currency .Total "EUR" }}
+//	{{ date .Created "long" }} {{ reltime .Updated }}
+//	<div dir="{{ dir }}">
+func Funcs(locale string) template.FuncMap {
+	f := NewFormatter(locale)
+	return template.FuncMap{
+		"t":        f.T,
+		"plural":   f.Plural,
+		"number":   f.Number,
+		"currency": f.Currency,
+		"date":     f.Date,
+		"reltime":  f.RelativeTime,
+		"dir":      func() string { return Dir(locale) },
+	}
+}
+
+// T formats a message that has already been translated at compile time
+func (f *Formatter) T(msg string, args ...interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Plural selects a form for n using the plural rules of the locale.  Forms are listed in the CLDR order of
+// the locale's categories (zero, one, two, few, many, other).  With fewer forms than categories, zero has no
+// form of its own and the last form is other, which is used for any category without its own form.  Forms
+// containing a formatting verb are formatted with the localized number.
+func (f *Formatter) Plural(n interface{}, forms ...string) string {
+	form := selectForm(f.categories, f.rule(newOperands(n)), forms)
+	if strings.Contains(form, "%") {
+		form = strings.NewReplacer("%d", f.Number(n), "%v", f.Number(n), "%s", f.Number(n)).Replace(form)
+	}
+	return form
+}
+
+// Number formats an integer or float with the grouping and decimal separators of the locale, using at most
+// three fraction digits
+func (f *Formatter) Number(n interface{}) string {
+	v, ok := toFloat(n)
+	if !ok {
+		return fmt.Sprint(n)
+	}
+	return f.formatFloat(v, 0, 3)
+}
+
+// Currency formats an amount of the ISO 4217 currency code using the currency pattern of the locale
+func (f *Formatter) Currency(n interface{}, code string) string {
+	v, ok := toFloat(n)
+	if !ok {
+		return fmt.Sprint(n)
+	}
+	code = strings.ToUpper(code)
+	digits, ok := currencyDigits[code]
+	if !ok {
+		digits = 2
+	}
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		symbol = code
+	}
+	num := f.formatFloat(math.Abs(v), digits, digits)
+	out := strings.NewReplacer("#", num, "¤", symbol).Replace(f.data.currency)
+	if v < 0 {
+		return "-" + out
+	}
+	return out
+}
+
+// Date formats a time using the short or long date style of the locale.  Any other style is treated as a
+// CLDR date pattern, e.g. "d MMMM y".
+func (f *Formatter) Date(t time.Time, style string) string {
+	pattern := style
+	switch style {
+	case "", "short":
+		pattern = f.data.dateShort
+	case "long":
+		pattern = f.data.dateLong
+	}
+	return f.formatDate(t, pattern)
+}
+
+// RelativeTime describes t relative to now in the largest whole unit, e.g. "3 days ago" or "in 2 hours"
+func (f *Formatter) RelativeTime(t time.Time) string {
+	d := t.Sub(f.Now())
+	pattern := f.data.future
+	if d < 0 {
+		pattern = f.data.past
+		d = -d
+	}
+
+	var unit string
+	var n int64
+	switch {
+	case d < time.Minute:
+		unit, n = "second", int64(d/time.Second)
+	case d < time.Hour:
+		unit, n = "minute", int64(d/time.Minute)
+	case d < 24*time.Hour:
+		unit, n = "hour", int64(d/time.Hour)
+	case d < 30*24*time.Hour:
+		unit, n = "day", int64(d/(24*time.Hour))
+	case d < 365*24*time.Hour:
+		unit, n = "month", int64(d/(30*24*time.Hour))
+	default:
+		unit, n = "year", int64(d/(365*24*time.Hour))
+	}
+
+	forms := f.data.units[unit]
+	phrase, ok := forms[f.rule(newOperands(n))]
+	if !ok {
+		phrase = forms[Other]
+	}
+	phrase = strings.ReplaceAll(phrase, "{0}", f.Number(n))
+	return strings.ReplaceAll(pattern, "{0}", phrase)
+}
+
+// formatFloat rounds v to at most maxFrac fraction digits, keeping at least minFrac, and applies the
+// separators and digits of the locale
+func (f *Formatter) formatFloat(v float64, minFrac int, maxFrac int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', maxFrac, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	for len(frac) > minFrac && strings.HasSuffix(frac, "0") {
+		frac = frac[:len(frac)-1]
+	}
+
+	var b strings.Builder
+	if v < 0 && (strings.Trim(intPart, "0") != "" || strings.Trim(frac, "0") != "") {
+		b.WriteString("-")
+	}
+	if len(intPart) >= f.data.minGroup {
+		for i, r := range intPart {
+			if i > 0 && (len(intPart)-i)%3 == 0 {
+				b.WriteString(f.data.group)
+			}
+			b.WriteRune(r)
+		}
+	} else {
+		b.WriteString(intPart)
+	}
+	if frac != "" {
+		b.WriteString(f.data.decimal)
+		b.WriteString(frac)
+	}
+	return f.localizeDigits(b.String())
+}
+
+// formatDate supports the subset of CLDR date pattern fields d, dd, M, MM, MMMM, y and yy.  Text in single
+// quotes and any other character are copied literally.
+func (f *Formatter) formatDate(t time.Time, pattern string) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '\'' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			b.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+			continue
+		}
+		if r != 'd' && r != 'M' && r != 'y' {
+			b.WriteRune(r)
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(runes) && runes[i+n] == r {
+			n++
+		}
+		i += n
+
+		switch {
+		case r == 'd' && n == 1:
+			b.WriteString(f.localizeDigits(strconv.Itoa(t.Day())))
+		case r == 'd':
+			b.WriteString(f.localizeDigits(fmt.Sprintf("%02d", t.Day())))
+		case r == 'M' && n >= 3 && len(f.data.months) == 12:
+			b.WriteString(f.data.months[t.Month()-1])
+		case r == 'M' && n == 2:
+			b.WriteString(f.localizeDigits(fmt.Sprintf("%02d", int(t.Month()))))
+		case r == 'M':
+			b.WriteString(f.localizeDigits(strconv.Itoa(int(t.Month()))))
+		case r == 'y' && n == 2:
+			b.WriteString(f.localizeDigits(fmt.Sprintf("%02d", t.Year()%100)))
+		default:
+			b.WriteString(f.localizeDigits(strconv.Itoa(t.Year())))
+		}
+	}
+	return b.String()
+}
+
+// localizeDigits replaces latin digits with the native digits of the locale
+func (f *Formatter) localizeDigits(s string) string {
+	if f.data.digits == "" {
+		return s
+	}
+	native := []rune(f.data.digits)
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return native[r-'0']
+		}
+		return r
+	}, s)
+}
+
+func toFloat(n interface{}) (float64, bool) {
+	switch v := n.(type) {
+	case int:
+		return float64(v), true
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return float64(toInt64(v)), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
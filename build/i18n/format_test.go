@@ -0,0 +1,197 @@
+package i18n
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallback(t *testing.T) {
+	assert.Equal(t, []string{"pt-BR", "pt", "en"}, Fallback("pt_br"))
+	assert.Equal(t, []string{"zh-Hant-TW", "zh-Hant", "zh", "en"}, Fallback("zh-hant-tw"))
+	assert.Equal(t, []string{"en"}, Fallback("en"))
+	assert.Equal(t, []string{"en"}, Fallback(""))
+}
+
+func TestPluralCategory(t *testing.T) {
+	tt := []struct {
+		locale string
+		n      interface{}
+		expect string
+	}{
+		{"en", 1, One},
+		{"en", 0, Other},
+		{"en", 1.0, One},
+		{"en", "1.0", Other},
+		{"fr", 0, One},
+		{"fr", 1.5, One},
+		{"fr", 2, Other},
+		{"fr", 1000000, Many},
+		{"pt-BR", 0, One},
+		{"pt-PT", 0, Other},
+		{"ru", 1, One},
+		{"ru", 21, One},
+		{"ru", 11, Many},
+		{"ru", 3, Few},
+		{"ru", 14, Many},
+		{"ru", 1.5, Other},
+		{"pl", 1, One},
+		{"pl", 22, Few},
+		{"pl", 21, Many},
+		{"pl", 12, Many},
+		{"ar", 0, Zero},
+		{"ar", 2, Two},
+		{"ar", 105, Few},
+		{"ar", 111, Many},
+		{"ar", 100, Other},
+		{"he", 2, Two},
+		{"he", uint8(5), Other},
+		{"ja", 1, Other},
+		{"xx", 1, One},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expect, PluralCategory(tc.locale, tc.n), "%s %v", tc.locale, tc.n)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	assert.Equal(t, "1 item", NewFormatter("en").Plural(1, "%d item", "%d items"))
+	assert.Equal(t, "1,000 items", NewFormatter("en").Plural(1000, "%d item", "%d items"))
+	// romance languages have a many category for millions which falls back to the last form
+	assert.Equal(t, "1.000.000 elementos", NewFormatter("es").Plural(1000000, "%d elemento", "%d elementos"))
+	ru := NewFormatter("ru")
+	assert.Equal(t, "2 файла", ru.Plural(2, "%d файл", "%d файла", "%d файлов"))
+	assert.Equal(t, "5 файлов", ru.Plural(5, "%d файл", "%d файла", "%d файлов"))
+	assert.Equal(t, "", ru.Plural(5))
+	// zero has no form of its own unless every category has a form
+	ar := NewFormatter("ar")
+	assert.Equal(t, "٠ ملفات", ar.Plural(0, "%d ملف", "%d ملفات"))
+	assert.Equal(t, "١ ملف", ar.Plural(1, "%d ملف", "%d ملفات"))
+	assert.Equal(t, "لا ملفات", ar.Plural(0, "لا ملفات", "ملف", "ملفان", "ملفات", "ملفًا", "ملف"))
+}
+
+func TestNumber(t *testing.T) {
+	tt := []struct {
+		locale string
+		n      interface{}
+		expect string
+	}{
+		{"en", 1234567.891, "1,234,567.891"},
+		{"en", -1234.5, "-1,234.5"},
+		{"en", 0.12345, "0.123"},
+		{"de", 1234.5, "1.234,5"},
+		{"es", 1234, "1234"},
+		{"es", 12345, "12.345"},
+		{"fr", 1234.5, "1 234,5"},
+		{"ru", int64(1234), "1 234"},
+		{"ar", 1234.5, "١٬٢٣٤٫٥"},
+		{"pt-BR", 1234.5, "1.234,5"},
+		{"xx", 1234.5, "1,234.5"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expect, NewFormatter(tc.locale).Number(tc.n), "%s %v", tc.locale, tc.n)
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	assert.Equal(t, "$1,234.50", NewFormatter("en").Currency(1234.5, "USD"))
+	assert.Equal(t, "-$3.00", NewFormatter("en").Currency(-3, "usd"))
+	assert.Equal(t, "1.234,50 €", NewFormatter("de").Currency(1234.5, "EUR"))
+	assert.Equal(t, "R$ 1.234,50", NewFormatter("pt-BR").Currency(1234.5, "BRL"))
+	assert.Equal(t, "¥1,235", NewFormatter("ja").Currency(1234.6, "JPY"))
+	assert.Equal(t, "12.00 CHF", NewFormatter("he").Currency(12, "CHF"))
+}
+
+func TestDate(t *testing.T) {
+	d := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	tt := []struct {
+		locale string
+		style  string
+		expect string
+	}{
+		{"en", "short", "1/2/06"},
+		{"en", "long", "January 2, 2006"},
+		{"en-GB", "short", "02/01/2006"},
+		{"de", "long", "2. Januar 2006"},
+		{"es", "long", "2 de enero de 2006"},
+		{"pt-BR", "long", "2 de janeiro de 2006"},
+		{"ru", "long", "2 января 2006 г."},
+		{"pl", "long", "2 stycznia 2006"},
+		{"he", "long", "2 בינואר 2006"},
+		{"ar", "short", "٢/١/٢٠٠٦"},
+		{"ja", "long", "2006年1月2日"},
+		{"zh", "short", "2006/1/2"},
+		{"en", "MMMM y", "January 2006"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expect, NewFormatter(tc.locale).Date(d, tc.style), "%s %s", tc.locale, tc.style)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	tt := []struct {
+		locale string
+		d      time.Duration
+		expect string
+	}{
+		{"en", -3 * 24 * time.Hour, "3 days ago"},
+		{"en", time.Hour, "in 1 hour"},
+		{"en", 30 * time.Second, "in 30 seconds"},
+		{"de", -2 * time.Hour, "vor 2 Stunden"},
+		{"fr", -400 * 24 * time.Hour, "il y a 1 an"},
+		{"ru", -5 * time.Minute, "5 минут назад"},
+		{"ru", 22 * time.Minute, "через 22 минуты"},
+		{"pl", -60 * 24 * time.Hour, "2 miesiące temu"},
+		{"ar", -2 * 24 * time.Hour, "قبل يومين"},
+		{"he", 24 * time.Hour, "בעוד יום"},
+		{"ja", -3 * time.Hour, "3 時間前"},
+		{"pt-BR", -10 * time.Minute, "há 10 minutos"},
+	}
+	for _, tc := range tt {
+		f := NewFormatter(tc.locale)
+		f.Now = func() time.Time { return now }
+		assert.Equal(t, tc.expect, f.RelativeTime(now.Add(tc.d)), "%s %v", tc.locale, tc.d)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	tmpl, err := template.New("test").Funcs(Funcs("de")).Parse(`{{ plural .N "%d Datei" "%d Dateien" }} {{ currency .Total "EUR" }} {{ t "Hallo %s" .Name }}`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, map[string]interface{}{"N": 1500, "Total": 9.99, "Name": "Welt"}))
+	assert.Equal(t, "1.500 Dateien 9,99 € Hallo Welt", buf.String())
+}
+
+func TestLoadCatalogChain(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	for locale, msgs := range map[string]map[string]string{
+		"pt-BR": {"Home": "Início", "Bus": "Ônibus", "Train": ""},
+		"pt":    {"Home": "Página inicial", "Train": "Comboio", "Log out": "Sair"},
+		"en":    {"Help": "Help"},
+	} {
+		c := NewCatalog(locale)
+		c.Messages = msgs
+		require.NoError(t, c.Save(td))
+	}
+
+	c, err := LoadCatalogChain(td, "pt-BR")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Home":    "Início",
+		"Bus":     "Ônibus",
+		"Train":   "Comboio",
+		"Log out": "Sair",
+		"Help":    "Help",
+	}, c.Messages)
+	assert.Equal(t, "pt-BR", c.Locale)
+}
@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"os"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const page = `{{ define "content" }}
+<h1 data-i18n>
+  Welcome   back
+</h1>
+<p data-i18n="greeting.body">Hello, <b>friend</b></p>
+<p>{{ t "Signed in as %s" .User }}</p>
+<a title="{{t ` + "`Log out`" + `}}">{{- t "Log out" -}}</a>
+<div>{{ printf "%s" (t "Nested") }}</div>
+<p data-i18n></p>
+{{ end }}`
+
+const search = `<img data-i18n alt="A cat" src="cat.png">
+<input data-i18n="search.hint" placeholder='Search' title=Find&#32;pages />
+<input data-i18n placeholder="{{ .Hint }}">`
+
+func TestExtract(t *testing.T) {
+	ids, err := extract("a/page.layout.tmpl", []byte(page))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Signed in as %s", "Log out", "Log out", "Nested", "Welcome back", "greeting.body"}, ids)
+
+	in, err := fs.New("/test")
+	require.NoError(t, err)
+	for path, content := range map[string]string{
+		"_layout.tmpl":         `<title>{{ t "Home" }}</title>{{ template "content" . }}`,
+		"a/page.layout.tmpl":   page,
+		"g/footer.tmpl":        `<footer>{{ t "Log out" }}</footer>`,
+		"unused/_other.tmpl":   `{{ t "Unused" }}`,
+		"b/broken.layout.tmpl": `<p data-i18n>never closed`,
+	} {
+		_, err := in.AddVirtual(path, []byte(content))
+		require.NoError(t, err)
+	}
+	_, err = Extract(in)
+	assert.Error(t, err)
+
+	_, err = in.Conn().Exec("DELETE FROM fs WHERE dir = 'b'")
+	require.NoError(t, err)
+	msgs, err := Extract(in)
+	require.NoError(t, err)
+
+	expect := []Message{
+		{ID: "Home", Locations: []string{"./_layout.tmpl"}},
+		{ID: "Log out", Locations: []string{"a/page.layout.tmpl", "g/footer.tmpl"}},
+		{ID: "Nested", Locations: []string{"a/page.layout.tmpl"}},
+		{ID: "Signed in as %s", Locations: []string{"a/page.layout.tmpl"}},
+		{ID: "Welcome back", Locations: []string{"a/page.layout.tmpl"}},
+		{ID: "greeting.body", Locations: []string{"a/page.layout.tmpl"}},
+	}
+	assert.Equal(t, expect, msgs)
+
+	// void elements are complete without an end tag and have their attributes translated
+	ids, err = extract("a/search.layout.tmpl", []byte(search))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A cat", "search.hint", "Find pages"}, ids)
+}
+
+func TestCatalog(t *testing.T) {
+	td, err := os.MkdirTemp("", "taevas")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	c, err := LoadCatalog(td, "es")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(c.Messages))
+
+	added := c.Merge([]Message{{ID: "Home"}, {ID: "Log out"}})
+	assert.Equal(t, []string{"Home", "Log out"}, added)
+	c.Messages["Home"] = "Inicio"
+	require.NoError(t, c.Save(td))
+
+	c2, err := LoadCatalog(td, "es")
+	require.NoError(t, err)
+	assert.Equal(t, []string(nil), c2.Merge([]Message{{ID: "Home"}}))
+	assert.Equal(t, []string{"Log out"}, c2.Missing())
+	tr, ok := c2.Lookup("Home")
+	assert.True(t, ok)
+	assert.Equal(t, "Inicio", tr)
+}
+
+func TestTranslate(t *testing.T) {
+	c := NewCatalog("es")
+	c.Messages = map[string]string{
+		"Welcome back":    "Bienvenido de nuevo",
+		"greeting.body":   "Hola, <b>amigo</b>",
+		"Signed in as %s": "Conectado como %s",
+		"Log out":         "Cerrar \"sesión\"",
+		"Nested":          "",
+	}
+
+	out, missing, err := Translate("a/page.layout.tmpl", []byte(page), c)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Nested"}, missing)
+
+	expect := `{{ define "content" }}
+<h1 data-i18n>Bienvenido de nuevo</h1>
+<p data-i18n="greeting.body">Hola, <b>amigo</b></p>
+<p>{{ t "Conectado como %s" .User }}</p>
+<a title="{{t "Cerrar \"sesión\""}}">{{- t "Cerrar \"sesión\"" -}}</a>
+<div>{{ printf "%s" (t "Nested") }}</div>
+<p data-i18n></p>
+{{ end }}`
+	assert.Equal(t, expect, string(out))
+	// translations of marked elements are inserted as markup and cannot add template actions
+	c.Messages["greeting.body"] = `Hola, {{ .Password }}`
+	_, _, err = Translate("a/page.layout.tmpl", []byte(page), c)
+	assert.Error(t, err)
+
+	c.Messages = map[string]string{"A cat": `Un "gato"`, "search.hint": "Buscar"}
+	out, missing, err = Translate("a/search.layout.tmpl", []byte(search), c)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Find pages"}, missing)
+	assert.Equal(t, `<img data-i18n alt="Un &#34;gato&#34;" src="cat.png">
+<input data-i18n="search.hint" placeholder="Buscar" title=Find&#32;pages />
+<input data-i18n placeholder="{{ .Hint }}">`, string(out))
+}
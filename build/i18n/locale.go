@@ -0,0 +1,312 @@
+package i18n
+
+import "strings"
+
+// DefaultLocale terminates every fallback chain
+const DefaultLocale = "en"
+
+// Canonical normalizes a locale tag to language-REGION form, e.g. pt_br -> pt-BR
+func Canonical(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(locale, "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			// script subtags are title case, e.g. zh-Hant
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Fallback returns the chain of locales to search, from most to least specific, always ending in the
+// default locale, e.g. pt-BR -> pt-BR, pt, en
+func Fallback(locale string) []string {
+	var chain []string
+	if locale != "" {
+		parts := strings.Split(Canonical(locale), "-")
+		for i := len(parts); i > 0; i-- {
+			chain = append(chain, strings.Join(parts[:i], "-"))
+		}
+	}
+	if len(chain) == 0 || chain[len(chain)-1] != DefaultLocale {
+		chain = append(chain, DefaultLocale)
+	}
+	return chain
+}
+
+// localeData holds the formatting conventions for a locale.  Regional variants only set the fields that
+// differ from their language and are merged along the fallback chain.
+type localeData struct {
+	decimal string
+	group   string
+	// minimum number of digits in the integer part before grouping is applied (CLDR minimumGroupingDigits + 3)
+	minGroup int
+	// native digits 0-9, or empty for latin digits
+	digits string
+	// currency pattern where # is the number and ¤ the symbol
+	currency string
+	// format context month names used by MMMM
+	months    []string
+	dateShort string
+	dateLong  string
+	// relative time patterns where {0} is the unit phrase
+	future string
+	past   string
+	// unit phrases keyed by unit, then plural category, where {0} is the number
+	units map[string]map[string]string
+}
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+var locales = map[string]localeData{
+	"en": {
+		decimal: ".", group: ",", minGroup: 4, currency: "¤#",
+		months:    []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		dateShort: "M/d/yy", dateLong: "MMMM d, y",
+		future: "in {0}", past: "{0} ago",
+		units: map[string]map[string]string{
+			"second": {One: "{0} second", Other: "{0} seconds"},
+			"minute": {One: "{0} minute", Other: "{0} minutes"},
+			"hour":   {One: "{0} hour", Other: "{0} hours"},
+			"day":    {One: "{0} day", Other: "{0} days"},
+			"month":  {One: "{0} month", Other: "{0} months"},
+			"year":   {One: "{0} year", Other: "{0} years"},
+		},
+	},
+	"en-GB": {
+		dateShort: "dd/MM/y", dateLong: "d MMMM y",
+	},
+	"de": {
+		decimal: ",", group: ".", minGroup: 4, currency: "#" + nbsp + "¤",
+		months:    []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		dateShort: "dd.MM.yy", dateLong: "d. MMMM y",
+		future: "in {0}", past: "vor {0}",
+		units: map[string]map[string]string{
+			"second": {One: "{0} Sekunde", Other: "{0} Sekunden"},
+			"minute": {One: "{0} Minute", Other: "{0} Minuten"},
+			"hour":   {One: "{0} Stunde", Other: "{0} Stunden"},
+			"day":    {One: "{0} Tag", Other: "{0} Tagen"},
+			"month":  {One: "{0} Monat", Other: "{0} Monaten"},
+			"year":   {One: "{0} Jahr", Other: "{0} Jahren"},
+		},
+	},
+	"es": {
+		decimal: ",", group: ".", minGroup: 5, currency: "#" + nbsp + "¤",
+		months:    []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		dateShort: "d/M/yy", dateLong: "d 'de' MMMM 'de' y",
+		future: "dentro de {0}", past: "hace {0}",
+		units: map[string]map[string]string{
+			"second": {One: "{0} segundo", Other: "{0} segundos"},
+			"minute": {One: "{0} minuto", Other: "{0} minutos"},
+			"hour":   {One: "{0} hora", Other: "{0} horas"},
+			"day":    {One: "{0} día", Other: "{0} días"},
+			"month":  {One: "{0} mes", Other: "{0} meses"},
+			"year":   {One: "{0} año", Other: "{0} años"},
+		},
+	},
+	"fr": {
+		decimal: ",", group: narrowNbsp, minGroup: 4, currency: "#" + narrowNbsp + "¤",
+		months:    []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		dateShort: "dd/MM/y", dateLong: "d MMMM y",
+		future: "dans {0}", past: "il y a {0}",
+		units: map[string]map[string]string{
+			"second": {One: "{0} seconde", Other: "{0} secondes"},
+			"minute": {One: "{0} minute", Other: "{0} minutes"},
+			"hour":   {One: "{0} heure", Other: "{0} heures"},
+			"day":    {One: "{0} jour", Other: "{0} jours"},
+			"month":  {One: "{0} mois", Other: "{0} mois"},
+			"year":   {One: "{0} an", Other: "{0} ans"},
+		},
+	},
+	"it": {
+		decimal: ",", group: ".", minGroup: 4, currency: "#" + nbsp + "¤",
+		months:    []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		dateShort: "dd/MM/yy", dateLong: "d MMMM y",
+		future: "tra {0}", past: "{0} fa",
+		units: map[string]map[string]string{
+			"second": {One: "{0} secondo", Other: "{0} secondi"},
+			"minute": {One: "{0} minuto", Other: "{0} minuti"},
+			"hour":   {One: "{0} ora", Other: "{0} ore"},
+			"day":    {One: "{0} giorno", Other: "{0} giorni"},
+			"month":  {One: "{0} mese", Other: "{0} mesi"},
+			"year":   {One: "{0} anno", Other: "{0} anni"},
+		},
+	},
+	"pt": {
+		decimal: ",", group: ".", minGroup: 4, currency: "#" + nbsp + "¤",
+		months:    []string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		dateShort: "dd/MM/y", dateLong: "d 'de' MMMM 'de' y",
+		future: "em {0}", past: "há {0}",
+		units: map[string]map[string]string{
+			"second": {One: "{0} segundo", Other: "{0} segundos"},
+			"minute": {One: "{0} minuto", Other: "{0} minutos"},
+			"hour":   {One: "{0} hora", Other: "{0} horas"},
+			"day":    {One: "{0} dia", Other: "{0} dias"},
+			"month":  {One: "{0} mês", Other: "{0} meses"},
+			"year":   {One: "{0} ano", Other: "{0} anos"},
+		},
+	},
+	"pt-BR": {
+		currency: "¤" + nbsp + "#",
+	},
+	"pt-PT": {
+		group: nbsp, minGroup: 5, future: "dentro de {0}",
+	},
+	"ru": {
+		decimal: ",", group: nbsp, minGroup: 4, currency: "#" + nbsp + "¤",
+		months:    []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		dateShort: "dd.MM.y", dateLong: "d MMMM y 'г'.",
+		future: "через {0}", past: "{0} назад",
+		units: map[string]map[string]string{
+			"second": {One: "{0} секунду", Few: "{0} секунды", Many: "{0} секунд", Other: "{0} секунды"},
+			"minute": {One: "{0} минуту", Few: "{0} минуты", Many: "{0} минут", Other: "{0} минуты"},
+			"hour":   {One: "{0} час", Few: "{0} часа", Many: "{0} часов", Other: "{0} часа"},
+			"day":    {One: "{0} день", Few: "{0} дня", Many: "{0} дней", Other: "{0} дня"},
+			"month":  {One: "{0} месяц", Few: "{0} месяца", Many: "{0} месяцев", Other: "{0} месяца"},
+			"year":   {One: "{0} год", Few: "{0} года", Many: "{0} лет", Other: "{0} года"},
+		},
+	},
+	"pl": {
+		decimal: ",", group: nbsp, minGroup: 5, currency: "#" + nbsp + "¤",
+		months:    []string{"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca", "lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+		dateShort: "d.MM.y", dateLong: "d MMMM y",
+		future: "za {0}", past: "{0} temu",
+		units: map[string]map[string]string{
+			"second": {One: "{0} sekundę", Few: "{0} sekundy", Many: "{0} sekund", Other: "{0} sekundy"},
+			"minute": {One: "{0} minutę", Few: "{0} minuty", Many: "{0} minut", Other: "{0} minuty"},
+			"hour":   {One: "{0} godzinę", Few: "{0} godziny", Many: "{0} godzin", Other: "{0} godziny"},
+			"day":    {One: "{0} dzień", Few: "{0} dni", Many: "{0} dni", Other: "{0} dnia"},
+			"month":  {One: "{0} miesiąc", Few: "{0} miesiące", Many: "{0} miesięcy", Other: "{0} miesiąca"},
+			"year":   {One: "{0} rok", Few: "{0} lata", Many: "{0} lat", Other: "{0} roku"},
+		},
+	},
+	"ar": {
+		decimal: "٫", group: "٬", minGroup: 4, digits: "٠١٢٣٤٥٦٧٨٩", currency: "#" + nbsp + "¤",
+		months:    []string{"يناير", "فبراير", "مارس", "أبريل", "مايو", "يونيو", "يوليو", "أغسطس", "سبتمبر", "أكتوبر", "نوفمبر", "ديسمبر"},
+		dateShort: "d/M/y", dateLong: "d MMMM y",
+		future: "خلال {0}", past: "قبل {0}",
+		units: map[string]map[string]string{
+			"second": {Zero: "{0} ثانية", One: "ثانية واحدة", Two: "ثانيتين", Few: "{0} ثوانٍ", Many: "{0} ثانية", Other: "{0} ثانية"},
+			"minute": {Zero: "{0} دقيقة", One: "دقيقة واحدة", Two: "دقيقتين", Few: "{0} دقائق", Many: "{0} دقيقة", Other: "{0} دقيقة"},
+			"hour":   {Zero: "{0} ساعة", One: "ساعة واحدة", Two: "ساعتين", Few: "{0} ساعات", Many: "{0} ساعة", Other: "{0} ساعة"},
+			"day":    {Zero: "{0} يوم", One: "يوم واحد", Two: "يومين", Few: "{0} أيام", Many: "{0} يومًا", Other: "{0} يوم"},
+			"month":  {Zero: "{0} شهر", One: "شهر واحد", Two: "شهرين", Few: "{0} أشهر", Many: "{0} شهرًا", Other: "{0} شهر"},
+			"year":   {Zero: "{0} سنة", One: "سنة واحدة", Two: "سنتين", Few: "{0} سنوات", Many: "{0} سنة", Other: "{0} سنة"},
+		},
+	},
+	"he": {
+		decimal: ".", group: ",", minGroup: 4, currency: "#" + nbsp + "¤",
+		months:    []string{"ינואר", "פברואר", "מרץ", "אפריל", "מאי", "יוני", "יולי", "אוגוסט", "ספטמבר", "אוקטובר", "נובמבר", "דצמבר"},
+		dateShort: "d.M.y", dateLong: "d בMMMM y",
+		future: "בעוד {0}", past: "לפני {0}",
+		units: map[string]map[string]string{
+			"second": {One: "שנייה", Two: "שתי שניות", Other: "{0} שניות"},
+			"minute": {One: "דקה", Two: "שתי דקות", Other: "{0} דקות"},
+			"hour":   {One: "שעה", Two: "שעתיים", Other: "{0} שעות"},
+			"day":    {One: "יום", Two: "יומיים", Other: "{0} ימים"},
+			"month":  {One: "חודש", Two: "חודשיים", Other: "{0} חודשים"},
+			"year":   {One: "שנה", Two: "שנתיים", Other: "{0} שנים"},
+		},
+	},
+	"ja": {
+		decimal: ".", group: ",", minGroup: 4, currency: "¤#",
+		dateShort: "y/MM/dd", dateLong: "y年M月d日",
+		future: "{0}後", past: "{0}前",
+		units: map[string]map[string]string{
+			"second": {Other: "{0} 秒"},
+			"minute": {Other: "{0} 分"},
+			"hour":   {Other: "{0} 時間"},
+			"day":    {Other: "{0} 日"},
+			"month":  {Other: "{0} か月"},
+			"year":   {Other: "{0} 年"},
+		},
+	},
+	"zh": {
+		decimal: ".", group: ",", minGroup: 4, currency: "¤#",
+		dateShort: "y/M/d", dateLong: "y年M月d日",
+		future: "{0}后", past: "{0}前",
+		units: map[string]map[string]string{
+			"second": {Other: "{0}秒钟"},
+			"minute": {Other: "{0}分钟"},
+			"hour":   {Other: "{0}小时"},
+			"day":    {Other: "{0}天"},
+			"month":  {Other: "{0}个月"},
+			"year":   {Other: "{0}年"},
+		},
+	},
+}
+
+// resolve merges locale data along the fallback chain, with more specific locales taking precedence
+func resolve(locale string) localeData {
+	chain := Fallback(locale)
+	var d localeData
+	for i := len(chain) - 1; i >= 0; i-- {
+		l, ok := locales[chain[i]]
+		if !ok {
+			continue
+		}
+		// a different language replaces everything, a regional variant only overrides what it sets
+		if l.months != nil || l.units != nil {
+			d = l
+			continue
+		}
+		if l.decimal != "" {
+			d.decimal = l.decimal
+		}
+		if l.group != "" {
+			d.group = l.group
+		}
+		if l.minGroup != 0 {
+			d.minGroup = l.minGroup
+		}
+		if l.currency != "" {
+			d.currency = l.currency
+		}
+		if l.dateShort != "" {
+			d.dateShort = l.dateShort
+		}
+		if l.dateLong != "" {
+			d.dateLong = l.dateLong
+		}
+		if l.future != "" {
+			d.future = l.future
+		}
+		if l.past != "" {
+			d.past = l.past
+		}
+	}
+	return d
+}
+
+// currency symbols for common ISO 4217 codes.  Unknown codes are displayed as the code itself.
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"BRL": "R$",
+	"RUB": "₽",
+	"PLN": "zł",
+	"ILS": "₪",
+	"INR": "₹",
+	"CAD": "CA$",
+	"AUD": "A$",
+	"MXN": "MX$",
+	"KRW": "₩",
+}
+
+// currencies that are not displayed with two fraction digits
+var currencyDigits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"CLP": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
@@ -0,0 +1,267 @@
+package i18n
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Plural categories defined by CLDR
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// operands are the CLDR plural operands for a number.  See
+// https://unicode.org/reports/tr35/tr35-numbers.html#Operands
+type operands struct {
+	n float64 // absolute value
+	i int64   // integer digits
+	v int     // number of visible fraction digits
+	f int64   // visible fraction digits
+}
+
+func newOperands(v interface{}) operands {
+	var s string
+	switch n := v.(type) {
+	case int:
+		s = strconv.Itoa(n)
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = strconv.FormatInt(toInt64(n), 10)
+	case float32:
+		s = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case string:
+		s = n
+	default:
+		return operands{}
+	}
+	s = strings.TrimPrefix(s, "-")
+
+	var op operands
+	op.n, _ = strconv.ParseFloat(s, 64)
+	parts := strings.SplitN(s, ".", 2)
+	op.i, _ = strconv.ParseInt(parts[0], 10, 64)
+	if len(parts) == 2 {
+		op.v = len(parts[1])
+		op.f, _ = strconv.ParseInt(parts[1], 10, 64)
+	}
+	return op
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+// isInt reports whether n is an integer, which some rules require before applying a modulus
+func (op operands) isInt() bool {
+	return op.n == math.Trunc(op.n)
+}
+
+func between(v int64, lo int64, hi int64) bool {
+	return v >= lo && v <= hi
+}
+
+// pluralRule selects the plural category for a number
+type pluralRule func(op operands) string
+
+// millions is the CLDR many category for romance languages, e.g. 1 000 000 de personnes
+func millions(op operands) bool {
+	return op.v == 0 && op.i != 0 && op.i%1000000 == 0
+}
+
+// rules are keyed by language.  The categories are listed in CLDR order and determine how positional plural forms
+// map to categories.
+var rules = map[string]struct {
+	categories []string
+	rule       pluralRule
+}{
+	"en": {[]string{One, Other}, oneIfIntegerOne},
+	"de": {[]string{One, Other}, oneIfIntegerOne},
+	"nl": {[]string{One, Other}, oneIfIntegerOne},
+	"sv": {[]string{One, Other}, oneIfIntegerOne},
+	"it": {[]string{One, Many, Other}, func(op operands) string {
+		switch {
+		case op.i == 1 && op.v == 0:
+			return One
+		case millions(op):
+			return Many
+		}
+		return Other
+	}},
+	"es": {[]string{One, Many, Other}, func(op operands) string {
+		switch {
+		case op.n == 1:
+			return One
+		case millions(op):
+			return Many
+		}
+		return Other
+	}},
+	"fr": {[]string{One, Many, Other}, func(op operands) string {
+		switch {
+		case op.i == 0 || op.i == 1:
+			return One
+		case millions(op):
+			return Many
+		}
+		return Other
+	}},
+	"pt": {[]string{One, Many, Other}, func(op operands) string {
+		switch {
+		case op.i == 0 || op.i == 1:
+			return One
+		case millions(op):
+			return Many
+		}
+		return Other
+	}},
+	"pt-PT": {[]string{One, Many, Other}, func(op operands) string {
+		switch {
+		case op.i == 1 && op.v == 0:
+			return One
+		case millions(op):
+			return Many
+		}
+		return Other
+	}},
+	"ru": {[]string{One, Few, Many, Other}, eastSlavic},
+	"uk": {[]string{One, Few, Many, Other}, eastSlavic},
+	"pl": {[]string{One, Few, Many, Other}, func(op operands) string {
+		switch {
+		case op.v != 0:
+			return Other
+		case op.i == 1:
+			return One
+		case between(op.i%10, 2, 4) && !between(op.i%100, 12, 14):
+			return Few
+		}
+		return Many
+	}},
+	"ar": {[]string{Zero, One, Two, Few, Many, Other}, func(op operands) string {
+		switch {
+		case op.n == 0:
+			return Zero
+		case op.n == 1:
+			return One
+		case op.n == 2:
+			return Two
+		case op.isInt() && between(op.i%100, 3, 10):
+			return Few
+		case op.isInt() && between(op.i%100, 11, 99):
+			return Many
+		}
+		return Other
+	}},
+	"he": {[]string{One, Two, Other}, func(op operands) string {
+		switch {
+		case op.i == 1 && op.v == 0:
+			return One
+		case op.i == 2 && op.v == 0:
+			return Two
+		}
+		return Other
+	}},
+	"ja": {[]string{Other}, otherOnly},
+	"zh": {[]string{Other}, otherOnly},
+	"ko": {[]string{Other}, otherOnly},
+}
+
+func oneIfIntegerOne(op operands) string {
+	if op.i == 1 && op.v == 0 {
+		return One
+	}
+	return Other
+}
+
+func eastSlavic(op operands) string {
+	switch {
+	case op.v != 0:
+		return Other
+	case op.i%10 == 1 && op.i%100 != 11:
+		return One
+	case between(op.i%10, 2, 4) && !between(op.i%100, 12, 14):
+		return Few
+	}
+	return Many
+}
+
+func otherOnly(op operands) string {
+	return Other
+}
+
+// PluralCategory returns the CLDR plural category of n for the locale, following the fallback chain until
+// a locale with plural rules is found
+func PluralCategory(locale string, n interface{}) string {
+	_, rule := pluralRules(locale)
+	return rule(newOperands(n))
+}
+
+func pluralRules(locale string) ([]string, pluralRule) {
+	for _, l := range Fallback(locale) {
+		if r, ok := rules[l]; ok {
+			return r.categories, r.rule
+		}
+	}
+	r := rules[DefaultLocale]
+	return r.categories, r.rule
+}
+
+// selectForm picks the form for the category given forms listed in the CLDR order of the locale's categories
+func selectForm(categories []string, category string, forms []string) string {
+	if len(forms) == 0 {
+		return ""
+	}
+	for i, c := range formCategories(categories, len(forms)) {
+		if c == category {
+			return forms[i]
+		}
+	}
+	return forms[len(forms)-1]
+}
+
+// formCategories returns the category of each of n positional forms.  When fewer forms are given than the
+// locale has categories, zero is dropped first since it is usually written like other, the last form is other
+// and the forms before it take the remaining categories in CLDR order.  Categories without a form of their own
+// use the other form.
+func formCategories(categories []string, n int) []string {
+	if n >= len(categories) {
+		return categories
+	}
+	var rest []string
+	for _, c := range categories {
+		if c == Other || c == Zero {
+			continue
+		}
+		rest = append(rest, c)
+	}
+	if len(rest) > n-1 {
+		rest = rest[:n-1]
+	}
+	return append(rest, Other)
+}
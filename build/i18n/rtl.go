@@ -0,0 +1,260 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// languages written right to left
+var rtlLanguages = map[string]bool{
+	"ar": true,
+	"dv": true,
+	"fa": true,
+	"he": true,
+	"ps": true,
+	"sd": true,
+	"ug": true,
+	"ur": true,
+	"yi": true,
+}
+
+// IsRTL reports whether the locale is written right to left
+func IsRTL(locale string) bool {
+	chain := Fallback(locale)
+	// the last element is always the default locale, the one before it is the language
+	if len(chain) < 2 {
+		return rtlLanguages[chain[0]]
+	}
+	return rtlLanguages[chain[len(chain)-2]]
+}
+
+// Dir returns the value of the HTML dir attribute for the locale
+func Dir(locale string) string {
+	if IsRTL(locale) {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+var (
+	htmlLangDirRE = regexp.MustCompile(`(?i)\s+(lang|dir)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	htmlOpenRE    = regexp.MustCompile(`(?i)^<html`)
+	hrefRE        = regexp.MustCompile(`(?i)(\shref\s*=\s*)("[^"]*"|'[^']*'|[^\s>]+)`)
+	// a template action, or a declaration block that may contain template actions
+	blockRE     = regexp.MustCompile(`{{(?s:.*?)}}|{(?:[^{}]|{{(?s:.*?)}})*}`)
+	leftRightRE = regexp.MustCompile(`\b(left|right)\b`)
+)
+
+// SetHTMLLang sets the lang attribute of the <html> element in a layout, and dir="rtl" for right to left
+// locales, replacing any values already in the markup
+func SetHTMLLang(src []byte, locale string) ([]byte, error) {
+	attrs := fmt.Sprintf(` lang="%s"`, Canonical(locale))
+	if IsRTL(locale) {
+		attrs += ` dir="rtl"`
+	}
+	return rewriteTags(src, func(tt html.TokenType, name string, raw []byte, z *html.Tokenizer) []byte {
+		if name != "html" || tt == html.EndTagToken {
+			return nil
+		}
+		tag := htmlLangDirRE.ReplaceAll(raw, nil)
+		return htmlOpenRE.ReplaceAll(tag, []byte("${0}"+attrs))
+	})
+}
+
+// FlipStyles mirrors inline <style> blocks for right to left locales and points stylesheet links to their
+// .rtl.css variant when exists reports that the variant is available.  Relative hrefs are resolved against
+// dir, and root relative hrefs against the root of the filesystem.
+func FlipStyles(src []byte, dir string, exists func(path string) bool) ([]byte, error) {
+	inStyle := false
+	return rewriteTags(src, func(tt html.TokenType, name string, raw []byte, z *html.Tokenizer) []byte {
+		switch {
+		case name == "style":
+			inStyle = tt == html.StartTagToken
+		case tt == html.TextToken && inStyle:
+			return []byte(FlipCSS(string(raw)))
+		case name == "link" && tt != html.EndTagToken:
+			var rel, href string
+			for {
+				k, v, more := z.TagAttr()
+				switch strings.ToLower(string(k)) {
+				case "rel":
+					rel = strings.ToLower(string(v))
+				case "href":
+					href = string(v)
+				}
+				if !more {
+					break
+				}
+			}
+			if !strings.Contains(rel, "stylesheet") {
+				return nil
+			}
+			variant, ok := rtlVariant(href, dir, exists)
+			if !ok {
+				return nil
+			}
+			return hrefRE.ReplaceAllFunc(raw, func(m []byte) []byte {
+				sub := hrefRE.FindSubmatch(m)
+				return []byte(string(sub[1]) + `"` + variant + `"`)
+			})
+		}
+		return nil
+	})
+}
+
+// rtlVariant returns the href of the .rtl.css variant of a stylesheet if it exists
+func rtlVariant(href string, dir string, exists func(path string) bool) (string, bool) {
+	if href == "" || strings.Contains(href, "{{") || strings.Contains(href, "://") || strings.HasPrefix(href, "//") {
+		return "", false
+	}
+	// ignore query strings and fragments used for cache busting
+	clean := href
+	if i := strings.IndexAny(clean, "?#"); i >= 0 {
+		clean = clean[:i]
+	}
+	if !strings.HasSuffix(clean, ".css") || strings.HasSuffix(clean, ".rtl.css") {
+		return "", false
+	}
+	variant := strings.TrimSuffix(clean, ".css") + ".rtl.css"
+
+	p := path.Join(dir, variant)
+	if strings.HasPrefix(variant, "/") {
+		p = strings.TrimPrefix(variant, "/")
+	}
+	if !exists(p) {
+		return "", false
+	}
+	return variant + href[len(clean):], true
+}
+
+// FlipCSS mirrors the physical horizontal properties in a stylesheet for right to left layouts.  Properties
+// and keyword values referring to left and right are swapped, and four value margin, padding, border and
+// border-radius shorthands are mirrored.  Logical properties such as margin-inline-start already follow the
+// direction of the document and are left unchanged.  Template actions are copied as they are, including those in
+// declaration blocks.
+func FlipCSS(css string) string {
+	return blockRE.ReplaceAllStringFunc(css, func(block string) string {
+		if strings.HasPrefix(block, "{{") {
+			return block
+		}
+		// actions are set aside so that their text is neither split into declarations nor mirrored
+		actions := actionRE.FindAllString(block, -1)
+		block = actionRE.ReplaceAllLiteralString(block, "\x00")
+		decls := strings.Split(block[1:len(block)-1], ";")
+		for i, decl := range decls {
+			decls[i] = flipDeclaration(decl)
+		}
+		out := "{" + strings.Join(decls, ";") + "}"
+		for _, a := range actions {
+			out = strings.Replace(out, "\x00", a, 1)
+		}
+		return out
+	})
+}
+
+func flipDeclaration(decl string) string {
+	colon := strings.Index(decl, ":")
+	if colon < 0 || strings.Contains(decl, "rtl:ignore") {
+		return decl
+	}
+	prop, value := decl[:colon], decl[colon+1:]
+	name := strings.ToLower(strings.TrimSpace(prop))
+
+	switch name {
+	case "float", "clear", "text-align", "caption-side":
+		value = swapLeftRight(value)
+	case "margin", "padding", "border-width", "border-style", "border-color", "inset":
+		value = swapValues(value, 1, 3)
+	case "border-radius":
+		value = mirrorRadius(value)
+	}
+	return swapLeftRight(prop) + ":" + value
+}
+
+func swapLeftRight(s string) string {
+	return leftRightRE.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "left" {
+			return "right"
+		}
+		return "left"
+	})
+}
+
+// swapValues swaps two values of a four value shorthand, preserving surrounding whitespace and !important
+func swapValues(value string, i int, j int) string {
+	fields := strings.Fields(value)
+	important := len(fields) > 0 && fields[len(fields)-1] == "!important"
+	if important {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) != 4 {
+		return value
+	}
+	fields[i], fields[j] = fields[j], fields[i]
+	if important {
+		fields = append(fields, "!important")
+	}
+	return leading(value) + strings.Join(fields, " ") + trailing(value)
+}
+
+// mirrorRadius mirrors top-left/top-right and bottom-right/bottom-left radii
+func mirrorRadius(value string) string {
+	parts := strings.SplitN(value, "/", 2)
+	for i, p := range parts {
+		fields := strings.Fields(p)
+		switch len(fields) {
+		case 2:
+			fields = []string{fields[1], fields[0], fields[1], fields[0]}
+		case 3:
+			fields = []string{fields[1], fields[0], fields[1], fields[2]}
+		case 4:
+			fields = []string{fields[1], fields[0], fields[3], fields[2]}
+		default:
+			continue
+		}
+		parts[i] = leading(p) + strings.Join(fields, " ") + trailing(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func leading(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t\n"))]
+}
+
+func trailing(s string) string {
+	return s[len(strings.TrimRight(s, " \t\n")):]
+}
+
+// rewriteTags copies a template token by token, replacing the raw bytes of a token whenever fn returns a
+// non-nil result
+func rewriteTags(src []byte, fn func(tt html.TokenType, name string, raw []byte, z *html.Tokenizer) []byte) ([]byte, error) {
+	var out bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return nil, z.Err()
+			}
+			return out.Bytes(), nil
+		}
+		// raw is only valid until the next call to Next or TagAttr
+		raw := append([]byte(nil), z.Raw()...)
+		var name string
+		if tt == html.StartTagToken || tt == html.EndTagToken || tt == html.SelfClosingTagToken {
+			n, _ := z.TagName()
+			name = string(n)
+		}
+		if replaced := fn(tt, name, raw, z); replaced != nil {
+			out.Write(replaced)
+			continue
+		}
+		out.Write(raw)
+	}
+}
@@ -0,0 +1,116 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRTL(t *testing.T) {
+	assert.True(t, IsRTL("ar"))
+	assert.True(t, IsRTL("ar-EG"))
+	assert.True(t, IsRTL("he_IL"))
+	assert.False(t, IsRTL("en"))
+	assert.False(t, IsRTL("pt-BR"))
+	assert.Equal(t, "rtl", Dir("fa"))
+	assert.Equal(t, "ltr", Dir(""))
+}
+
+func TestSetHTMLLang(t *testing.T) {
+	src := `<!DOCTYPE html><html lang="en" class="{{ .Class }}" dir='ltr'><body></body></html>`
+
+	out, err := SetHTMLLang([]byte(src), "ar-eg")
+	require.NoError(t, err)
+	assert.Equal(t, `<!DOCTYPE html><html lang="ar-EG" dir="rtl" class="{{ .Class }}"><body></body></html>`, string(out))
+
+	out, err = SetHTMLLang([]byte(`<HTML><p>x</p></HTML>`), "de")
+	require.NoError(t, err)
+	assert.Equal(t, `<HTML lang="de"><p>x</p></HTML>`, string(out))
+}
+
+func TestFlipCSS(t *testing.T) {
+	tt := []struct {
+		in     string
+		expect string
+	}{
+		{".a { margin-left: 4px; float: left }", ".a { margin-right: 4px; float: right }"},
+		{".nav-left:hover { text-align: right; }", ".nav-left:hover { text-align: left; }"},
+		{".a{padding:1px 2px 3px 4px !important}", ".a{padding:1px 4px 3px 2px !important}"},
+		{".a { border-radius: 1px 2px 3px 4px; left: 0 }", ".a { border-radius: 2px 1px 4px 3px; right: 0 }"},
+		{".a { border-radius: 1px 2px / 3px }", ".a { border-radius: 2px 1px 2px 1px / 3px }"},
+		{"@media (min-width: 10px) { .a { border-left-color: red } }", "@media (min-width: 10px) { .a { border-right-color: red } }"},
+		{".a { margin-inline-start: 4px; background: url(left.png) }", ".a { margin-inline-start: 4px; background: url(left.png) }"},
+		{".a { float: left /* rtl:ignore */ }", ".a { float: left /* rtl:ignore */ }"},
+		{".a { color: {{ .Color }}; margin-left: 4px }", ".a { color: {{ .Color }}; margin-right: 4px }"},
+		{`.a { float: {{ index .Sides "left" }}; {{ if .X }}left: 0;{{ end }} }`, `.a { float: {{ index .Sides "left" }}; {{ if .X }}right: 0;{{ end }} }`},
+		{`{{ range .Rules }}.{{ .Name }} { float: left }{{ end }}{{ print "x:left" }}`, `{{ range .Rules }}.{{ .Name }} { float: right }{{ end }}{{ print "x:left" }}`},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expect, FlipCSS(tc.in))
+	}
+}
+
+func TestFlipStyles(t *testing.T) {
+	exists := func(p string) bool {
+		return p == "a/css/site.rtl.css" || p == "static/app.rtl.css"
+	}
+	src := `<head>
+<link rel="stylesheet" href="css/site.css?v=2">
+<link href="/static/app.css" rel="stylesheet" />
+<link rel="stylesheet" href="https://cdn.example.com/x.css">
+<link rel="stylesheet" href="other.css">
+<link rel="icon" href="favicon.css">
+<style>.a { padding-left: 2px }</style>
+</head><p style="x">left</p>`
+
+	expect := `<head>
+<link rel="stylesheet" href="css/site.rtl.css?v=2">
+<link href="/static/app.rtl.css" rel="stylesheet" />
+<link rel="stylesheet" href="https://cdn.example.com/x.css">
+<link rel="stylesheet" href="other.css">
+<link rel="icon" href="favicon.css">
+<style>.a { padding-right: 2px }</style>
+</head><p style="x">left</p>`
+
+	out, err := FlipStyles([]byte(src), "a", exists)
+	require.NoError(t, err)
+	assert.Equal(t, expect, string(out))
+}
+
+func TestLocalizeRTL(t *testing.T) {
+	in, err := fs.New("/test")
+	require.NoError(t, err)
+	for path, content := range map[string]string{
+		"_layout.tmpl":        `<html lang="en"><head><link rel="stylesheet" href="/site.css"></head>{{ template "content" . }}</html>`,
+		"a/index.layout.tmpl": `{{ define "content" }}<style>.x { float: left }</style>{{ end }}`,
+		"site.css":            `.x {}`,
+		"site.rtl.css":        `.x {}`,
+	} {
+		_, err := in.AddVirtual(path, []byte(content))
+		require.NoError(t, err)
+	}
+
+	for locale, expect := range map[string][]string{
+		"he": {
+			`<html lang="he" dir="rtl"><head><link rel="stylesheet" href="/site.rtl.css"></head>{{ template "content" . }}</html>`,
+			`{{ define "content" }}<style>.x { float: right }</style>{{ end }}`,
+		},
+		"fr": {
+			`<html lang="fr"><head><link rel="stylesheet" href="/site.css"></head>{{ template "content" . }}</html>`,
+			`{{ define "content" }}<style>.x { float: left }</style>{{ end }}`,
+		},
+	} {
+		out, err := fs.New("/out")
+		require.NoError(t, err)
+		_, err = Localize(in, out, NewCatalog(locale))
+		require.NoError(t, err)
+
+		for i, path := range []string{"_layout.tmpl", "a/index.layout.tmpl"} {
+			b, err := out.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, expect[i], string(b), "%s %s", locale, path)
+		}
+	}
+}
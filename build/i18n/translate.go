@@ -0,0 +1,138 @@
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/utils"
+)
+
+// Translate rewrites a template for the locale of the catalog.  Messages without a translation keep the
+// source text and their IDs are returned as missing.  Translations of elements marked with the i18n attribute are
+// inserted as markup, or as escaped text in the attributes of void elements, and must not contain template actions.
+func Translate(template string, src []byte, c *Catalog) ([]byte, []string, error) {
+	var missing []string
+	lookup := func(id string) (string, bool) {
+		t, ok := c.Lookup(id)
+		if !ok {
+			missing = appendUnique(missing, id)
+		}
+		return t, ok
+	}
+
+	var err error
+	out := actionRE.ReplaceAllFunc(src, func(action []byte) []byte {
+		return tCallRE.ReplaceAllFunc(action, func(call []byte) []byte {
+			m := tCallRE.FindSubmatch(call)
+			id, uerr := strconv.Unquote(string(m[2]))
+			if uerr != nil {
+				err = fmt.Errorf("invalid message in %s: %w", template, uerr)
+				return call
+			}
+			t, ok := lookup(id)
+			if !ok {
+				return call
+			}
+			return []byte(string(call[:len(call)-len(m[2])]) + strconv.Quote(t))
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, rerr := rewriteElements(out, func(id string, _ []byte) []byte {
+		if id == "" {
+			return nil
+		}
+		t, ok := lookup(id)
+		if !ok {
+			return nil
+		}
+		// the translation is inserted as markup, so an action would be executed as part of the template
+		if strings.Contains(t, "{{") || strings.Contains(t, "}}") {
+			err = fmt.Errorf("translation of %q contains a template action", id)
+			return nil
+		}
+		return []byte(t)
+	})
+	if err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to translate %s: %w", template, err)
+	}
+	return out, missing, nil
+}
+
+// Localize writes a translated copy of every template needed to render a target into out.  HTML layouts receive
+// the lang of the locale on their <html> element, and HTML templates for right to left locales have their
+// styles mirrored.  It returns the messages that are missing a translation along with the templates in
+// which they appear.
+func Localize(in *fs.Filesystem, out *fs.Filesystem, c *Catalog) ([]Message, error) {
+	templates, err := in.AllTemplates()
+	if err != nil {
+		return nil, err
+	}
+	layouts, err := in.Layouts()
+	if err != nil {
+		return nil, err
+	}
+	isLayout := make(map[string]bool, len(layouts))
+	for _, l := range layouts {
+		isLayout[l] = true
+	}
+
+	locations := make(map[string][]string)
+	var ids []string
+	for _, template := range templates {
+		b, err := in.ReadFile(template)
+		if err != nil {
+			return nil, err
+		}
+		translated, missing, err := Translate(template, b, c)
+		if err != nil {
+			return nil, err
+		}
+		// plain text templates have no markup to annotate
+		typ, err := in.TemplateType(template)
+		if err != nil {
+			return nil, err
+		}
+		if isLayout[template] && typ == fs.TypeHTML {
+			if translated, err = SetHTMLLang(translated, c.Locale); err != nil {
+				return nil, fmt.Errorf("failed to set lang in %s: %w", template, err)
+			}
+		}
+		if IsRTL(c.Locale) && typ == fs.TypeHTML {
+			if translated, err = FlipStyles(translated, utils.ParsePath(template).Dir(), in.Exists); err != nil {
+				return nil, fmt.Errorf("failed to mirror styles in %s: %w", template, err)
+			}
+		}
+		id, err := out.AddVirtual(template, translated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s for locale %s: %w", template, c.Locale, err)
+		}
+		// front matter was stripped when reading the template, so it is carried over separately
+		meta, err := in.Metadata(template)
+		if err != nil {
+			return nil, err
+		}
+		if err := out.SetMetadata(id, 0, meta); err != nil {
+			return nil, fmt.Errorf("failed to copy metadata of %s for locale %s: %w", template, c.Locale, err)
+		}
+		for _, id := range missing {
+			if _, ok := locations[id]; !ok {
+				ids = append(ids, id)
+			}
+			locations[id] = appendUnique(locations[id], template)
+		}
+	}
+
+	report := make([]Message, len(ids))
+	for i, id := range ids {
+		report[i] = Message{ID: id, Locations: locations[id]}
+	}
+	return report, nil
+}
@@ -0,0 +1,202 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BTBurke/taevas/build/fs"
+)
+
+// templateRE matches {{ template "name" }} actions
+var templateRE = regexp.MustCompile("{{-?\\s*template\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// UnusedTemplate is a partial template that is never referenced
+type UnusedTemplate struct {
+	Path string
+	// Kind is fs.KindGlobal, fs.KindLocal or fs.KindUnused for templates that are not in any template tree
+	Kind string
+}
+
+// LintReport lists templates and blocks that can be deleted or inlined
+type LintReport struct {
+	// UnusedTemplates are globals and locals whose definitions are never referenced by {{ template }} from the
+	// tree of any target, along with templates that are not in any tree
+	UnusedTemplates []UnusedTemplate
+	// UnusedLayouts are layouts that no target uses, directly or through inheritance
+	UnusedLayouts []string
+	// DeadBlocks are {{ block }} definitions that no template in any tree overrides
+	DeadBlocks []Definition
+}
+
+// Empty reports whether the lint found nothing
+func (r *LintReport) Empty() bool {
+	return len(r.UnusedTemplates) == 0 && len(r.UnusedLayouts) == 0 && len(r.DeadBlocks) == 0
+}
+
+func (r *LintReport) String() string {
+	var b strings.Builder
+	for _, t := range r.UnusedTemplates {
+		switch t.Kind {
+		case fs.KindUnused:
+			fmt.Fprintf(&b, "%s: template is not parsed for any target\n", t.Path)
+		default:
+			fmt.Fprintf(&b, "%s: %s template is never referenced\n", t.Path, t.Kind)
+		}
+	}
+	for _, l := range r.UnusedLayouts {
+		fmt.Fprintf(&b, "%s: layout is not used by any target\n", l)
+	}
+	for _, d := range r.DeadBlocks {
+		fmt.Fprintf(&b, "%s: block %q is never overridden\n", d, d.Name)
+	}
+	return b.String()
+}
+
+// References returns the names of the templates executed by {{ template }} actions
+func References(src []byte) []string {
+	var refs []string
+	for _, m := range templateRE.FindAllSubmatch(src, -1) {
+		name, err := strconv.Unquote(string(m[1]))
+		if err != nil {
+			continue
+		}
+		refs = append(refs, name)
+	}
+	return refs
+}
+
+// Lint reports partial templates that are never referenced, layouts without targets and blocks that are never
+// overridden.  A partial is referenced when another template in the same tree executes one of its definitions, its
+// name or one of its aliases.
+func (c *Context) Lint() (*LintReport, error) {
+	report := &LintReport{}
+
+	type parsed struct {
+		defs  []Definition
+		refs  []string
+		names []string
+	}
+	cache := make(map[string]parsed)
+	parse := func(template string) (parsed, error) {
+		if p, ok := cache[template]; ok {
+			return p, nil
+		}
+		b, err := c.InputFS.ReadFile(template)
+		if err != nil {
+			return parsed{}, err
+		}
+		name, aliases, err := c.InputFS.TemplateName(template)
+		if err != nil {
+			return parsed{}, err
+		}
+		defs, err := c.definitions(template)
+		if err != nil {
+			return parsed{}, err
+		}
+		cache[template] = parsed{defs: defs, refs: References(b), names: append([]string{name}, aliases...)}
+		return cache[template], nil
+	}
+
+	targets, err := c.InputFS.Targets()
+	if err != nil {
+		return nil, err
+	}
+	// other reports whether a template other than self is in the set
+	other := func(set map[string]bool, self string) bool {
+		for t := range set {
+			if t != self {
+				return true
+			}
+		}
+		return false
+	}
+
+	// templates referenced from another template in a shared tree, and blocks defined by another template in a
+	// shared tree
+	referenced := make(map[string]bool)
+	overridden := make(map[Definition]bool)
+	for _, target := range targets {
+		tree, err := c.InputFS.TargetTree(target)
+		if err != nil {
+			return nil, err
+		}
+		refs := make(map[string]map[string]bool)
+		defs := make(map[string]map[string]bool)
+		for _, template := range tree {
+			p, err := parse(template)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range p.refs {
+				if refs[r] == nil {
+					refs[r] = make(map[string]bool)
+				}
+				refs[r][template] = true
+			}
+			for _, d := range p.defs {
+				if defs[d.Name] == nil {
+					defs[d.Name] = make(map[string]bool)
+				}
+				defs[d.Name][template] = true
+			}
+		}
+
+		for _, template := range tree {
+			p := cache[template]
+			for _, name := range p.names {
+				if other(refs[name], template) {
+					referenced[template] = true
+				}
+			}
+			for _, d := range p.defs {
+				if other(refs[d.Name], template) {
+					referenced[template] = true
+				}
+				if d.Block && other(defs[d.Name], template) {
+					overridden[d] = true
+				}
+			}
+		}
+	}
+
+	for _, partials := range []struct {
+		kind  string
+		query func() ([]string, error)
+	}{
+		{fs.KindGlobal, c.InputFS.Globals},
+		{fs.KindLocal, c.InputFS.Locals},
+		{fs.KindUnused, c.InputFS.Orphans},
+	} {
+		paths, err := partials.query()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			if !referenced[p] {
+				report.UnusedTemplates = append(report.UnusedTemplates, UnusedTemplate{Path: p, Kind: partials.kind})
+			}
+		}
+	}
+
+	if report.UnusedLayouts, err = c.InputFS.UnusedLayouts(); err != nil {
+		return nil, err
+	}
+
+	// blocks in templates outside every tree are already reported with their template
+	parsedTemplates := make([]string, 0, len(cache))
+	for template := range cache {
+		parsedTemplates = append(parsedTemplates, template)
+	}
+	sort.Strings(parsedTemplates)
+	for _, template := range parsedTemplates {
+		for _, d := range cache[template].defs {
+			if d.Block && !overridden[d] {
+				report.DeadBlocks = append(report.DeadBlocks, d)
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,47 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferences(t *testing.T) {
+	src := "{{ template \"card\" . }}{{- template `row.tmpl` -}}{{ templates \"x\" }}"
+	assert.Equal(t, []string{"card", "row.tmpl"}, References([]byte(src)))
+}
+
+func TestLint(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":        `{{ block "title" . }}{{ end }}{{ block "footer" . }}{{ end }}{{ template "content" . }}`,
+		"_unused.tmpl":        `{{ block "nav" . }}{{ end }}`,
+		"g/card.tmpl":         `{{ define "card" }}{{ template "icon" }}{{ end }}`,
+		"g/icon.tmpl":         `{{ define "icon" }}{{ end }}`,
+		"g/old.tmpl":          `{{ define "old" }}{{ template "old" }}{{ end }}`,
+		"a/index.layout.tmpl": `{{ define "title" }}{{ end }}{{ define "content" }}{{ template "card" }}{{ template "row.tmpl" }}{{ end }}`,
+		"a/row.tmpl":          `<tr></tr>`,
+		"a/cruft.tmpl":        `{{ define "cruft" }}{{ end }}`,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	report, err := ctx.Lint()
+	require.NoError(t, err)
+	assert.Equal(t, &LintReport{
+		UnusedTemplates: []UnusedTemplate{
+			{Path: "g/old.tmpl", Kind: fs.KindGlobal},
+			{Path: "a/cruft.tmpl", Kind: fs.KindLocal},
+		},
+		UnusedLayouts: []string{"./_unused.tmpl"},
+		DeadBlocks:    []Definition{{Name: "footer", Template: "./_layout.tmpl", Line: 1, Block: true}},
+	}, report)
+	assert.False(t, report.Empty())
+	assert.Equal(t, `g/old.tmpl: global template is never referenced
+a/cruft.tmpl: local template is never referenced
+./_unused.tmpl: layout is not used by any target
+./_layout.tmpl:1: block "footer" is never overridden
+`, report.String())
+}
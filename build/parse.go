@@ -0,0 +1,136 @@
+package build
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/BTBurke/taevas/build/fs"
+)
+
+// Template is a parsed template tree.  It is an *html/template.Template, or a *text/template.Template for targets
+// with a text extension.
+type Template interface {
+	Name() string
+	Execute(w io.Writer, data interface{}) error
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// templateSet adds named templates and aliases to an html or text template set
+type templateSet interface {
+	add(name string, src string) (*parse.Tree, error)
+	alias(name string, tree *parse.Tree) error
+	root() Template
+}
+
+type htmlSet struct {
+	t     *template.Template
+	funcs template.FuncMap
+}
+
+func (s *htmlSet) add(name string, src string) (*parse.Tree, error) {
+	var t *template.Template
+	if s.t == nil {
+		s.t = template.New(name).Funcs(s.funcs)
+		t = s.t
+	} else {
+		t = s.t.New(name)
+	}
+	if _, err := t.Parse(src); err != nil {
+		return nil, err
+	}
+	return t.Tree, nil
+}
+
+func (s *htmlSet) alias(name string, tree *parse.Tree) error {
+	// html/template escapes each tree in place, so an alias cannot share the tree of its template
+	_, err := s.t.AddParseTree(name, tree.Copy())
+	return err
+}
+
+func (s *htmlSet) root() Template { return s.t }
+
+type textSet struct {
+	t     *texttemplate.Template
+	funcs texttemplate.FuncMap
+}
+
+func (s *textSet) add(name string, src string) (*parse.Tree, error) {
+	var t *texttemplate.Template
+	if s.t == nil {
+		s.t = texttemplate.New(name).Funcs(s.funcs)
+		t = s.t
+	} else {
+		t = s.t.New(name)
+	}
+	if _, err := t.Parse(src); err != nil {
+		return nil, err
+	}
+	return t.Tree, nil
+}
+
+func (s *textSet) alias(name string, tree *parse.Tree) error {
+	// text/template does not modify trees, but an alias gets its own copy as with html/template so that both sets
+	// behave the same
+	_, err := s.t.AddParseTree(name, tree.Copy())
+	return err
+}
+
+func (s *textSet) root() Template { return s.t }
+
+// ParseTree parses the template tree of a target into a single template set, using text/template when the target
+// has a text extension and html/template otherwise.  Each template is named by fs.TemplateName and is also
+// available under its aliases.  The returned template is the first in the tree, usually the outermost layout, and
+// executing it renders the target.
+func ParseTree(f *fs.Filesystem, target string, funcs template.FuncMap) (Template, error) {
+	tree, err := f.TargetTree(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(tree) == 0 {
+		return nil, fmt.Errorf("failed to parse %s: target has no template tree", target)
+	}
+	typ, err := f.TemplateType(target)
+	if err != nil {
+		return nil, err
+	}
+	var set templateSet = &htmlSet{funcs: funcs}
+	if typ == fs.TypeText {
+		set = &textSet{funcs: texttemplate.FuncMap(funcs)}
+	}
+
+	// owners records which template claimed a name so that colliding aliases are reported
+	owners := make(map[string]string)
+	for _, path := range tree {
+		name, aliases, err := f.TemplateName(path)
+		if err != nil {
+			return nil, err
+		}
+		src, err := f.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		t, err := set.add(name, string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		owners[name] = path
+
+		// a template containing only definitions has no body to alias
+		if t == nil {
+			continue
+		}
+		for _, alias := range aliases {
+			if owner, ok := owners[alias]; ok && owner != path {
+				return nil, fmt.Errorf("alias %s of %s is already the name of %s", alias, path, owner)
+			}
+			if err := set.alias(alias, t); err != nil {
+				return nil, fmt.Errorf("failed to add alias %s of %s: %w", alias, path, err)
+			}
+			owners[alias] = path
+		}
+	}
+	return set.root(), nil
+}
@@ -0,0 +1,74 @@
+package build
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTree(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `<main>{{ template "content" . }}</main>`,
+		"components/button.tmpl":  "---\nalias: button\n---\n<button>{{ . }}</button>",
+		"admin/button.tmpl":       `<a>{{ . }}</a>`,
+		"admin/index.layout.tmpl": `{{ define "content" }}{{ template "components/button" "x" }}{{ template "admin/button" "y" }}{{ template "button" "z" }}{{ end }}`,
+	})
+	ctx, err := New(root, WithNamespacedPartials())
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	tmpl, err := ParseTree(ctx.InputFS, "admin/index.layout.tmpl", template.FuncMap{})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	assert.Equal(t, `<main><button>x</button><a>y</a><button>z</button></main>`, buf.String())
+
+	// partials are referenced by namespaced names
+	report, err := ctx.Lint()
+	require.NoError(t, err)
+	assert.Empty(t, report.UnusedTemplates)
+
+	_, err = ParseTree(ctx.InputFS, "missing.layout.tmpl", nil)
+	assert.Error(t, err)
+}
+
+func TestParseTreeAliasCollision(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":        `{{ template "content" . }}`,
+		"g/a.tmpl":            "---\nalias: x\n---\na",
+		"g/b.tmpl":            "---\nalias: x\n---\nb",
+		"a/index.layout.tmpl": `{{ define "content" }}{{ template "x" }}{{ end }}`,
+	})
+	ctx, err := New(root, WithNamespacedPartials())
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	_, err = ParseTree(ctx.InputFS, "a/index.layout.tmpl", nil)
+	assert.EqualError(t, err, "alias x of g/b.tmpl is already the name of g/a.tmpl")
+}
+
+func TestParseTreeText(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":              `<p>{{ template "content" . }}</p>`,
+		"_layout.txt.tmpl":          `Hello {{ template "content" . }}`,
+		"a/index.layout.tmpl":       `{{ define "content" }}{{ . }}{{ end }}`,
+		"a/welcome.layout.txt.tmpl": `{{ define "content" }}{{ . }}{{ end }}`,
+	})
+	ctx, err := New(root, WithTextExtensions("txt.tmpl"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	for target, expect := range map[string]string{
+		"a/index.layout.tmpl":       "<p>&lt;Bob&gt;</p>",
+		"a/welcome.layout.txt.tmpl": "Hello <Bob>",
+	} {
+		tmpl, err := ParseTree(ctx.InputFS, target, nil)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, tmpl.Execute(&buf, "<Bob>"))
+		assert.Equal(t, expect, buf.String(), "failed for %s", target)
+	}
+}
@@ -0,0 +1,48 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/BTBurke/taevas/build/route"
+)
+
+// Prerender renders every page of the static routes among routes, by target, with the same data as Build.  Routes
+// generated with it serve the pages as they are rather than rendering them per request.  A static route with route
+// parameters must have a data file or collection listing the values of its parameters.  It implements
+// route.Prerenderer.
+func (c *Context) Prerender(routes []route.Route) (map[string][]route.Page, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	urls, err := c.itemURLs(routes)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]route.Page)
+	for _, r := range routes {
+		if !r.Static {
+			continue
+		}
+		tp, err := c.targetPages(r, urls)
+		if err != nil {
+			return nil, err
+		}
+		if tp.skipped {
+			return nil, fmt.Errorf("failed to prerender %s: no data file lists the values of its route parameters", r.Target)
+		}
+		t, err := ParseTree(c.InputFS, r.Target, c.opts.funcs)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range tp.pages {
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, tp.entries[i]); err != nil {
+				return nil, fmt.Errorf("failed to render %s at %s: %w", r.Target, p.URL, err)
+			}
+			out[r.Target] = append(out[r.Target], route.Page{URL: p.URL, Body: buf.Bytes()})
+		}
+	}
+	return out, nil
+}
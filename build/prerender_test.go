@@ -0,0 +1,47 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/BTBurke/taevas/build/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrerender(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"index.layout.tmpl":       "---\nrender: static\ntitle: Home\n---\n{{ define \"content\" }}{{ .title }}{{ end }}",
+		"account.layout.tmpl":     `{{ define "content" }}account{{ end }}`,
+		"docs/[slug].layout.tmpl": "---\nrender: static\n---\n{{ define \"content\" }}{{ .slug }}{{ end }}",
+		"docs/[slug].json":        `[{"slug": "intro"}, {"slug": "setup"}]`,
+	})
+	ctx, err := New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	routes, err := route.Routes(ctx.InputFS)
+	require.NoError(t, err)
+
+	pages, err := ctx.Prerender(routes)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]route.Page{
+		"./index.layout.tmpl": {{URL: "/", Body: []byte("Home")}},
+		"docs/[slug].layout.tmpl": {
+			{URL: "/docs/intro", Body: []byte("intro")},
+			{URL: "/docs/setup", Body: []byte("setup")},
+		},
+	}, pages)
+
+	// static routes with parameters need the values of their parameters
+	root = writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"docs/[slug].layout.tmpl": "---\nrender: static\n---\n{{ define \"content\" }}{{ .slug }}{{ end }}",
+	})
+	ctx, err = New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	routes, err = route.Routes(ctx.InputFS)
+	require.NoError(t, err)
+	_, err = ctx.Prerender(routes)
+	assert.EqualError(t, err, "failed to prerender docs/[slug].layout.tmpl: no data file lists the values of its route parameters")
+}
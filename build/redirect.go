@@ -0,0 +1,95 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/route"
+)
+
+// redirectsFileName is the file listing every redirect of a static build.  It has no extension, so it is written to the
+// output directory directly rather than through the output filesystem, which treats names without one as directories.
+const redirectsFileName = "_redirects"
+
+// redirect is a URL of a page from one of the aliases of its target
+type redirect struct {
+	from string
+	to   string
+}
+
+// aliasRedirects returns a redirect from every alias of a route to a page, with the parameters of the alias filled in
+// from the URL of the page
+func aliasRedirects(r route.Route, p Page) []redirect {
+	values, ok := r.Values(p.URL)
+	if !ok {
+		return nil
+	}
+	out := make([]redirect, len(r.Aliases))
+	for i, a := range r.Aliases {
+		out[i] = redirect{from: route.Fill(a, values), to: p.URL}
+	}
+	return out
+}
+
+// writeRedirects writes a stub that refreshes to the page at every html redirect, since static file servers cannot
+// redirect on their own, and returns the stubs as pages
+func (c *Context) writeRedirects(out *fs.Filesystem, written map[string]string, target string, redirects []redirect) ([]Page, error) {
+	pages := make([]Page, len(redirects))
+	for i, r := range redirects {
+		pages[i] = Page{Target: target, URL: r.from, Path: outputPath(r.from, fs.TypeHTML)}
+		if err := c.write(out, written, target, pages[i].Path, redirectStub(c.opts.baseURL+r.to)); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// redirectStub returns a page that immediately refreshes to url.  It is not indexed and names url as canonical so
+// that search engines treat it like a permanent redirect.
+func redirectStub(url string) []byte {
+	u := html.EscapeString(url)
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>Redirecting to %s</title>\n", u)
+	fmt.Fprintf(&b, "<link rel=\"canonical\" href=\"%s\">\n", u)
+	b.WriteString("<meta name=\"robots\" content=\"noindex\">\n")
+	fmt.Fprintf(&b, "<meta http-equiv=\"refresh\" content=\"0; url=%s\">\n", u)
+	b.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&b, "<p>This page has moved to <a href=\"%s\">%s</a>.</p>\n", u, u)
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+// redirectsFile returns the redirects in the _redirects format read by static hosts such as Netlify and Cloudflare
+// Pages, one permanent redirect per line ordered by the URL redirected from
+func redirectsFile(redirects []redirect) []byte {
+	sorted := append([]redirect(nil), redirects...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].from < sorted[j].from })
+	var b bytes.Buffer
+	for _, r := range sorted {
+		fmt.Fprintf(&b, "%s %s 301\n", r.from, r.to)
+	}
+	return b.Bytes()
+}
+
+// writeRedirectsFile writes the redirects to the output directory
+func (c *Context) writeRedirectsFile(redirects []redirect) error {
+	file := filepath.Join(c.opts.outDir, redirectsFileName)
+	if _, ok := c.written[redirectsFileName]; !ok && !c.opts.outDirOverwrite {
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("failed to build the site: %s already exists in the output directory", redirectsFileName)
+		}
+	}
+	if err := os.MkdirAll(c.opts.outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(file, redirectsFile(redirects), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", redirectsFileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package route
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/utils"
+)
+
+//go:embed routes.go.tmpl
+var routesTmpl string
+
+// OutputFile is the name of the generated file with the route registrations
+const OutputFile = "routes_gen.go"
+
+var tmpl = template.Must(template.New("routes").Funcs(template.FuncMap{
+	"load": load,
+}).Parse(routesTmpl))
+
+// Group is the routes and redirects registered under the same http.ServeMux pattern.  Routes without parameters are
+// matched first and redirects last.
+type Group struct {
+	Pattern   string
+	Routes    []Route
+	Redirects []Redirect
+}
+
+// groups orders routes and the redirects from their aliases by pattern
+func groups(routes []Route) []Group {
+	byPattern := make(map[string]*Group)
+	var patterns []string
+	group := func(pattern string) *Group {
+		if g, ok := byPattern[pattern]; ok {
+			return g
+		}
+		patterns = append(patterns, pattern)
+		byPattern[pattern] = &Group{Pattern: pattern}
+		return byPattern[pattern]
+	}
+	for _, r := range routes {
+		for _, p := range r.patterns() {
+			g := group(p)
+			g.Routes = append(g.Routes, r)
+		}
+	}
+	for _, r := range Redirects(routes) {
+		g := group(r.Pattern())
+		g.Redirects = append(g.Redirects, r)
+	}
+	sort.Strings(patterns)
+
+	out := make([]Group, len(patterns))
+	for i, p := range patterns {
+		rs := byPattern[p].Routes
+		sort.SliceStable(rs, func(i, j int) bool { return len(rs[i].Params) < len(rs[j].Params) })
+		out[i] = *byPattern[p]
+	}
+	return out
+}
+
+// load returns the expression that adapts the loader of a route to the parameters matched from the request path
+func load(r Route) string {
+	if len(r.Params) == 0 {
+		return fmt.Sprintf("static(loaders.%s)", r.Field())
+	}
+	var b strings.Builder
+	b.WriteString("func(r *http.Request, params []string) (interface{}, error) {\n")
+	args := make([]string, len(r.Params))
+	for i, p := range r.Params {
+		args[i] = p.GoName()
+		switch p.Type {
+		case "int":
+			fmt.Fprintf(&b, "%s, err := strconv.Atoi(params[%d])\nif err != nil {\nreturn nil, ErrNotFound\n}\n", p.GoName(), i)
+		default:
+			fmt.Fprintf(&b, "%s := params[%d]\n", p.GoName(), i)
+		}
+	}
+	fmt.Fprintf(&b, "if loaders.%s == nil {\nreturn nil, nil\n}\n", r.Field())
+	fmt.Fprintf(&b, "return loaders.%s(r, %s)\n}", r.Field(), strings.Join(args, ", "))
+	return b.String()
+}
+
+// File is a template in the tree of one or more routes
+type File struct {
+	Path    string
+	Name    string
+	Aliases []string
+	Src     string
+}
+
+// Generate writes the Go source for RegisterRoutes, which registers a handler for every route on an http.ServeMux.
+// Files holds the name and source of every template in the trees of the dynamic routes.  Static routes serve their
+// prerendered pages and have no loader.
+func Generate(w io.Writer, pkg string, routes []Route, files []File) error {
+	var html, text, conv bool
+	var dynamic, static []Route
+	for _, r := range routes {
+		if r.Static {
+			static = append(static, r)
+			continue
+		}
+		dynamic = append(dynamic, r)
+		html = html || r.Type != fs.TypeText
+		text = text || r.Type == fs.TypeText
+		for _, p := range r.Params {
+			conv = conv || p.Type == "int"
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"HTML":    html,
+		"Text":    text,
+		"Strconv": conv,
+		"Static":  static,
+		"Routes":  dynamic,
+		"Groups":  groups(routes),
+		"Files":   files,
+	}); err != nil {
+		return fmt.Errorf("failed to generate routes for package %s: %w", pkg, err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated routes for package %s: %w", pkg, err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// Prerenderer renders the pages of the static routes among routes by target.  It is passed every route so that pages
+// can link to the URLs of dynamic routes.
+type Prerenderer func(routes []Route) (map[string][]Page, error)
+
+// GenerateAll routes every target of the input filesystem and writes the generated registrations to dir, relative to
+// the module root, of the output filesystem.  Static routes are rendered by prerender, which may be nil when there are
+// none.  It returns a RouteError when targets share a route and an error when the template tree of a dynamic route
+// fails to parse.
+func GenerateAll(in *fs.Filesystem, out *fs.Filesystem, dir string, prerender Prerenderer) error {
+	routes, err := Routes(in)
+	if err != nil {
+		return err
+	}
+
+	var static []string
+	for _, r := range routes {
+		if r.Static {
+			static = append(static, r.Target)
+		}
+	}
+	if len(static) > 0 {
+		if prerender == nil {
+			return fmt.Errorf("failed to generate routes: %s renders static but routes are generated without prerendering", static[0])
+		}
+		pages, err := prerender(routes)
+		if err != nil {
+			return err
+		}
+		for i := range routes {
+			routes[i].Pages = pages[routes[i].Target]
+		}
+	}
+
+	seen := make(map[string]bool)
+	var files []File
+	for _, r := range routes {
+		if r.Static {
+			continue
+		}
+		for _, path := range r.Tree {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			name, aliases, err := in.TemplateName(path)
+			if err != nil {
+				return err
+			}
+			src, err := in.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, File{Path: path, Name: name, Aliases: aliases, Src: string(src)})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	if err := checkTrees(routes, files); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, utils.NewPath(dir, "").PackageName(), routes, files); err != nil {
+		return err
+	}
+	if _, err := out.AddVirtual(utils.NewPath(dir, OutputFile).String(), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write generated routes to %s: %w", dir, err)
+	}
+	return nil
+}
+
+// checkTrees parses the template tree of every dynamic route as RegisterRoutes does, so that syntax errors and
+// colliding aliases fail generation instead of panicking when the routes are registered.  Functions are not checked
+// since TemplateFuncs are only set by the application.
+func checkTrees(routes []Route, files []File) error {
+	byPath := make(map[string]File, len(files))
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+	for _, r := range routes {
+		if r.Static {
+			continue
+		}
+		// owners records which template claimed a name so that colliding aliases are reported
+		owners := make(map[string]string)
+		for _, path := range r.Tree {
+			f := byPath[path]
+			t := parse.New(f.Name)
+			t.Mode = parse.SkipFuncCheck
+			if _, err := t.Parse(f.Src, "", "", make(map[string]*parse.Tree)); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			owners[f.Name] = path
+			for _, alias := range f.Aliases {
+				if owner, ok := owners[alias]; ok && owner != path {
+					return fmt.Errorf("alias %s of %s is already the name of %s in the tree of %s", alias, path, owner, r.Target)
+				}
+				owners[alias] = path
+			}
+		}
+	}
+	return nil
+}
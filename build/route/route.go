@@ -0,0 +1,371 @@
+package route
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/utils"
+)
+
+// paramTypes are the Go types a route parameter can be decoded to
+var paramTypes = map[string]bool{
+	"string": true,
+	"int":    true,
+}
+
+// Param is a route parameter passed to the loader of a route
+type Param struct {
+	Name string
+	// Type is string or int
+	Type string
+}
+
+// GoName returns the name of the loader argument, e.g. page.slug -> pageSlug
+func (p Param) GoName() string {
+	t := []rune(utils.Camel(p.Name, ""))
+	if len(t) == 0 {
+		return "param"
+	}
+	t[0] = unicode.ToLower(t[0])
+	name := string(t)
+	if token.IsKeyword(name) || name == "r" || unicode.IsDigit(t[0]) {
+		name += "Param"
+	}
+	return name
+}
+
+// Route is a target served at a URL path
+type Route struct {
+	// Path is the URL path with parameters in braces, e.g. /users/{id}/profile
+	Path   string
+	Target string
+	// Type is fs.TypeHTML or fs.TypeText
+	Type   string
+	Params []Param
+	// Tree is the ordered list of templates parsed to render the target
+	Tree []string
+	// Aliases are previous paths of the target that redirect to it, from the aliases key of its front matter.  They
+	// have the same parameters as the path, e.g. /u/{id} for /users/{id}.
+	Aliases []string
+	// Static is set by render: static in front matter for targets that are the same for every request.  They are
+	// rendered when routes are generated rather than per request.
+	Static bool
+	// Pages are the prerendered pages of a static route, set by GenerateAll
+	Pages []Page
+}
+
+// Page is a page of a static route rendered when routes are generated
+type Page struct {
+	// URL is the route with its parameters filled in, e.g. /docs/intro
+	URL  string
+	Body []byte
+}
+
+// ETag returns the strong entity tag of the page, which changes whenever its body does
+func (p Page) ETag() string {
+	sum := sha256.Sum256(p.Body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Field returns the name of the loader of the route in the generated Loaders struct, e.g. /blog/post -> BlogPost and
+// /users/{id}/profile -> UsersIdProfile.  Routes ending in a slash are the index of their directory, e.g. / -> Index
+// and /blog/ -> BlogIndex.
+func (r Route) Field() string {
+	name := utils.Camel(r.Path, "")
+	if strings.HasSuffix(r.Path, "/") {
+		name += "Index"
+	}
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "R" + name
+	}
+	return name
+}
+
+// ContentType returns the content type of the rendered target
+func (r Route) ContentType() string {
+	if r.Type == fs.TypeText {
+		return "text/plain; charset=utf-8"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// Pattern returns the http.ServeMux pattern that the route is registered under.  Routes with parameters are
+// registered under the path before their first parameter and matched by the generated code, e.g. /users/{id}/profile
+// is registered under /users/.
+func (r Route) Pattern() string {
+	if i := strings.Index(r.Path, "{"); i >= 0 {
+		return r.Path[:i]
+	}
+	return r.Path
+}
+
+// patterns returns the patterns that the route is registered under.  Pages of a static route outside its path, such
+// as the later pages of a paginated listing, are registered under their own URL.
+func (r Route) patterns() []string {
+	patterns := []string{r.Pattern()}
+	for _, p := range r.Pages {
+		if _, ok := r.Values(p.URL); !ok {
+			patterns = append(patterns, p.URL)
+		}
+	}
+	return patterns
+}
+
+// key is the path with unnamed parameters, so that routes that match the same requests are equal
+func (r Route) key() string {
+	return key(r.Path)
+}
+
+func key(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Values returns the value of every parameter in a URL that matches the path of the route, e.g. /users/1 -> id: 1
+func (r Route) Values(url string) (map[string]string, bool) {
+	want := strings.Split(r.Path, "/")
+	got := strings.Split(url, "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for i, w := range want {
+		switch {
+		case strings.HasPrefix(w, "{"):
+			if got[i] == "" {
+				return nil, false
+			}
+			values[w[1:len(w)-1]] = got[i]
+		case w != got[i]:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// Fill replaces the parameters of a path with values, e.g. /u/{id} -> /u/1
+func Fill(path string, values map[string]string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") {
+			segments[i] = values[s[1:len(s)-1]]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Redirect is an alias of a target that permanently redirects to its route
+type Redirect struct {
+	// From is the alias with parameters in braces, e.g. /u/{id}
+	From   string
+	To     string
+	Target string
+}
+
+// Pattern returns the http.ServeMux pattern that the redirect is registered under, like Route.Pattern
+func (r Redirect) Pattern() string {
+	if i := strings.Index(r.From, "{"); i >= 0 {
+		return r.From[:i]
+	}
+	return r.From
+}
+
+// Redirects returns the redirect from every alias of the routes, ordered by alias
+func Redirects(routes []Route) []Redirect {
+	var redirects []Redirect
+	for _, r := range routes {
+		for _, a := range r.Aliases {
+			redirects = append(redirects, Redirect{From: a, To: r.Path, Target: r.Target})
+		}
+	}
+	sort.Slice(redirects, func(i, j int) bool { return key(redirects[i].From) < key(redirects[j].From) })
+	return redirects
+}
+
+// Conflict is a route served by more than one target
+type Conflict struct {
+	Route   string
+	Targets []string
+}
+
+// RouteError reports routes that more than one target would be served at
+type RouteError struct {
+	Conflicts []Conflict
+}
+
+func (e *RouteError) Error() string {
+	var b strings.Builder
+	b.WriteString("route conflicts:")
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&b, "\n  %s is the route of %s", c.Route, strings.Join(c.Targets, " and "))
+	}
+	return b.String()
+}
+
+// Routes returns the route, template tree, aliases and rendering of every target.  It returns a RouteError when targets share a
+// route or generate the same loader name, and an error when a route or alias in front matter is not an absolute path
+// or has an invalid parameter, or when an alias matches the same requests as a route or another alias.
+func Routes(f *fs.Filesystem) ([]Route, error) {
+	rows, err := f.Routes()
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for _, row := range rows {
+		if !validPath(row.Route) {
+			return nil, fmt.Errorf("invalid route %q for %s: routes must be an absolute URL path", row.Route, row.Target)
+		}
+		r := Route{Target: row.Target, Type: row.Type}
+		if r.Path, r.Params, err = parsePath(row.Route); err != nil {
+			return nil, fmt.Errorf("invalid route %q for %s: %w", row.Route, row.Target, err)
+		}
+		routes = append(routes, r)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].key() < routes[j].key() })
+
+	var out []Route
+	var conflicts []Conflict
+	byKey := make(map[string]int)
+	byField := make(map[string]int)
+	for _, r := range routes {
+		// routes are ordered, so the targets of a conflict are adjacent
+		other, ok := byKey[r.key()]
+		if !ok {
+			other, ok = byField[r.Field()]
+		}
+		if ok {
+			if n := len(conflicts); n > 0 && conflicts[n-1].Targets[0] == out[other].Target {
+				conflicts[n-1].Targets = append(conflicts[n-1].Targets, r.Target)
+			} else {
+				conflicts = append(conflicts, Conflict{Route: r.Path, Targets: []string{out[other].Target, r.Target}})
+			}
+			continue
+		}
+
+		if r.Tree, err = f.TargetTree(r.Target); err != nil {
+			return nil, err
+		}
+		if len(r.Tree) == 0 {
+			return nil, fmt.Errorf("failed to route %s: target has no template tree", r.Target)
+		}
+		byKey[r.key()] = len(out)
+		byField[r.Field()] = len(out)
+		out = append(out, r)
+	}
+	if len(conflicts) > 0 {
+		return nil, &RouteError{Conflicts: conflicts}
+	}
+
+	// aliases are checked once every route is known so that an alias cannot shadow any of them
+	for i := range out {
+		m, err := f.Metadata(out[i].Target)
+		if err != nil {
+			return nil, err
+		}
+		switch render := m.Get("render", "dynamic"); render {
+		case "static":
+			out[i].Static = true
+		case "dynamic":
+		default:
+			return nil, fmt.Errorf("invalid render %q for %s: targets render static or dynamic", render, out[i].Target)
+		}
+		if out[i].Aliases, err = aliases(m, out[i], byKey); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// aliases parses the aliases of a route and adds them to the routes by key
+func aliases(m fs.Metadata, r Route, byKey map[string]int) ([]string, error) {
+	values, err := m.List("aliases")
+	if err != nil {
+		return nil, fmt.Errorf("invalid aliases for %s: %w", r.Target, err)
+	}
+	self := byKey[r.key()]
+
+	var out []string
+	for _, v := range values {
+		if !validPath(v) {
+			return nil, fmt.Errorf("invalid alias %q for %s: aliases must be an absolute URL path", v, r.Target)
+		}
+		path, params, err := parsePath(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias %q for %s: %w", v, r.Target, err)
+		}
+		if !sameParams(params, r.Params) {
+			return nil, fmt.Errorf("invalid alias %q for %s: aliases must have the same parameters as the route %s", v, r.Target, r.Path)
+		}
+		if _, ok := byKey[key(path)]; ok {
+			return nil, fmt.Errorf("invalid alias %q for %s: it matches the same requests as a route or another alias", v, r.Target)
+		}
+		byKey[key(path)] = self
+		out = append(out, path)
+	}
+	return out, nil
+}
+
+// sameParams reports whether two paths have parameters with the same names
+func sameParams(a, b []Param) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool, len(a))
+	for _, p := range a {
+		names[p.Name] = true
+	}
+	for _, p := range b {
+		if !names[p.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// validPath reports whether a route or alias is an absolute URL path without a query or fragment
+func validPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.ContainsAny(p, " \t\n?#")
+}
+
+// parsePath converts the parameters of a route from brackets, as in directory and file names, to braces.  Routes set
+// in front matter or configuration may use either, e.g. /users/[id:int] or /users/{id:int}.
+func parsePath(route string) (string, []Param, error) {
+	segments := strings.Split(route, "/")
+	var params []Param
+	seen := make(map[string]bool)
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			s = "[" + s[1:len(s)-1] + "]"
+		}
+		p, ok := utils.ParseParam(s)
+		if !ok {
+			if strings.ContainsAny(s, "[]{}") {
+				return "", nil, fmt.Errorf("segment %s is not a parameter, parameters must be a whole segment such as [id] or [id:int]", s)
+			}
+			continue
+		}
+		if !paramTypes[p.Type] {
+			return "", nil, fmt.Errorf("parameter %s has unsupported type %s, it must be string or int", p.Name, p.Type)
+		}
+		param := Param{Name: p.Name, Type: p.Type}
+		if seen[param.GoName()] {
+			return "", nil, fmt.Errorf("parameter %s is used more than once", p.Name)
+		}
+		seen[param.GoName()] = true
+		params = append(params, param)
+		segments[i] = "{" + p.Name + "}"
+	}
+	return strings.Join(segments, "/"), params, nil
+}
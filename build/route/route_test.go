@@ -0,0 +1,312 @@
+package route
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFS(t *testing.T, files map[string]string) *fs.Filesystem {
+	f, err := fs.New("/test")
+	require.NoError(t, err)
+	require.NoError(t, f.SetExtension(".txt.tmpl", fs.TypeText))
+	for path, content := range files {
+		_, err := f.AddVirtual(path, []byte(content))
+		require.NoError(t, err)
+	}
+	return f
+}
+
+func TestRoutes(t *testing.T) {
+	f := newFS(t, map[string]string{
+		"_base.tmpl":           `<html>{{ template "content" . }}</html>`,
+		"_base.txt.tmpl":       `{{ template "content" . }}`,
+		"index.base.tmpl":      `{{ define "content" }}home{{ end }}`,
+		"blog/index.base.tmpl": `{{ define "content" }}blog{{ end }}`,
+		"blog/post.base.tmpl":  `{{ define "content" }}post{{ end }}`,
+		"feed.base.txt.tmpl":   `{{ define "content" }}feed{{ end }}`,
+		"1999/party.base.tmpl": `{{ define "content" }}party{{ end }}`,
+	})
+	routes, err := Routes(f)
+	require.NoError(t, err)
+
+	var got [][3]string
+	for _, r := range routes {
+		got = append(got, [3]string{r.Path, r.Field(), r.ContentType()})
+	}
+	assert.Equal(t, [][3]string{
+		{"/", "Index", "text/html; charset=utf-8"},
+		{"/1999/party", "R1999Party", "text/html; charset=utf-8"},
+		{"/blog/", "BlogIndex", "text/html; charset=utf-8"},
+		{"/blog/post", "BlogPost", "text/html; charset=utf-8"},
+		{"/feed", "Feed", "text/plain; charset=utf-8"},
+	}, got)
+	assert.Equal(t, []string{"./_base.tmpl", "blog/post.base.tmpl"}, routes[3].Tree)
+}
+
+func TestRouteConflicts(t *testing.T) {
+	f := newFS(t, map[string]string{
+		"_base.tmpl":          `{{ template "content" . }}`,
+		"_base.txt.tmpl":      `{{ template "content" . }}`,
+		"about.base.tmpl":     `about`,
+		"about.base.txt.tmpl": `about`,
+		"blog/post.base.tmpl": `post`,
+		"blog/a-b.base.tmpl":  `a-b`,
+		"blog/a_b.base.tmpl":  `a_b`,
+	})
+	require.NoError(t, f.SetRoute("blog/post.base.tmpl", "/about"))
+
+	_, err := Routes(f)
+	var rerr *RouteError
+	require.ErrorAs(t, err, &rerr)
+	assert.Equal(t, []Conflict{
+		{Route: "/about", Targets: []string{"./about.base.tmpl", "./about.base.txt.tmpl", "blog/post.base.tmpl"}},
+		{Route: "/blog/a_b", Targets: []string{"blog/a-b.base.tmpl", "blog/a_b.base.tmpl"}},
+	}, rerr.Conflicts)
+	assert.Equal(t, `route conflicts:
+  /about is the route of ./about.base.tmpl and ./about.base.txt.tmpl and blog/post.base.tmpl
+  /blog/a_b is the route of blog/a-b.base.tmpl and blog/a_b.base.tmpl`, err.Error())
+
+	// routes in front matter must be absolute
+	id, err := f.AddVirtual("contact.base.tmpl", []byte("contact"))
+	require.NoError(t, err)
+	require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"route": "contact"}))
+	_, err = Routes(f)
+	assert.EqualError(t, err, `invalid route "contact" for ./contact.base.tmpl: routes must be an absolute URL path`)
+}
+
+func TestGenerateAll(t *testing.T) {
+	in := newFS(t, map[string]string{
+		"_base.tmpl":             `<html>{{ template "content" . }}</html>`,
+		"index.base.tmpl":        `{{ define "content" }}{{ template "button.tmpl" . }}{{ end }}`,
+		"blog/post.base.tmpl":    `{{ define "content" }}post{{ end }}`,
+		"components/button.tmpl": `<button>{{ . }}</button>`,
+	})
+	out, err := fs.New("/out")
+	require.NoError(t, err)
+
+	require.NoError(t, GenerateAll(in, out, "web", nil))
+	src, err := out.ReadFile("web/" + OutputFile)
+	require.NoError(t, err)
+	typeCheck(t, "web", src)
+
+	s := string(src)
+	assert.Contains(t, s, "package web")
+	assert.Contains(t, s, "func RegisterRoutes(mux *http.ServeMux, loaders Loaders, locale func(r *http.Request) string)")
+	assert.Contains(t, s, "\tIndex Loader\n")
+	assert.Contains(t, s, "\tBlogPost Loader\n")
+	assert.Contains(t, s, `load:        static(loaders.BlogPost),`)
+	assert.Contains(t, s, `t:           parseHTML("./_base.tmpl", "components/button.tmpl", "blog/post.base.tmpl"),`)
+	assert.Contains(t, s, `"components/button.tmpl": {name: "button.tmpl", src: "<button>{{ . }}</button>"},`)
+	assert.Contains(t, s, "htmltemplate.Must(root.Clone()).Funcs(i18n.Funcs(locale)).Funcs(TemplateFuncs)")
+	assert.NotContains(t, s, "text/template")
+
+	// templates that would panic when the routes are registered fail generation
+	broken := newFS(t, map[string]string{
+		"_base.tmpl":      `{{ template "content" . }}`,
+		"index.base.tmpl": `{{ define "content" }}{{ if . }}{{ end }}`,
+	})
+	assert.Error(t, GenerateAll(broken, out, "web", nil))
+
+	colliding := newFS(t, map[string]string{
+		"_base.tmpl":      `{{ template "content" . }}{{ template "x" . }}`,
+		"index.base.tmpl": `{{ define "content" }}{{ end }}`,
+	})
+	for _, path := range []string{"components/a.tmpl", "components/b.tmpl"} {
+		id, err := colliding.AddVirtual(path, nil)
+		require.NoError(t, err)
+		require.NoError(t, colliding.SetMetadata(id, 0, fs.Metadata{"alias": "x"}))
+	}
+	assert.EqualError(t, GenerateAll(colliding, out, "web", nil), "alias x of components/b.tmpl is already the name of components/a.tmpl in the tree of ./index.base.tmpl")
+}
+
+// sources imports packages from source for typeCheck, caching them between tests
+var sources = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck fails the test unless the generated source of package pkg compiles
+func typeCheck(t *testing.T, pkg string, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, OutputFile, src, 0)
+	require.NoError(t, err)
+	conf := types.Config{Importer: sources}
+	_, err = conf.Check(pkg, fset, []*ast.File{f}, nil)
+	require.NoError(t, err)
+}
+
+func TestGenerateText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "web", []Route{{Path: "/feed", Target: "./feed.base.txt.tmpl", Type: fs.TypeText, Tree: []string{"./feed.base.txt.tmpl"}}}, nil))
+	assert.Contains(t, buf.String(), `"text/template"`)
+	assert.NotContains(t, buf.String(), "html/template")
+	assert.Contains(t, buf.String(), `parseText("./feed.base.txt.tmpl")`)
+	assert.Contains(t, buf.String(), "template.Must(root.AddParseTree(alias, t.Tree.Copy()))")
+	assert.NotContains(t, buf.String(), `"strconv"`)
+	typeCheck(t, "web", buf.Bytes())
+}
+
+func TestParams(t *testing.T) {
+	f := newFS(t, map[string]string{
+		"_base.tmpl":                       `{{ template "content" . }}`,
+		"users/[id:int]/profile.base.tmpl": `profile`,
+		"users/[id:int]/index.base.tmpl":   `user`,
+		"users/index.base.tmpl":            `users`,
+		"docs/[page.slug].base.tmpl":       `doc`,
+		"[lang]/[type].base.tmpl":          `type`,
+	})
+	routes, err := Routes(f)
+	require.NoError(t, err)
+
+	var got []string
+	for _, r := range routes {
+		got = append(got, r.Path+" "+r.Pattern()+" "+r.Field())
+	}
+	assert.Equal(t, []string{
+		"/docs/{page.slug} /docs/ DocsPageSlug",
+		"/users/ /users/ UsersIndex",
+		"/users/{id}/ /users/ UsersIdIndex",
+		"/users/{id}/profile /users/ UsersIdProfile",
+		"/{lang}/{type} / LangType",
+	}, got)
+	assert.Equal(t, []Param{{Name: "id", Type: "int"}}, routes[3].Params)
+	assert.Equal(t, "pageSlug", routes[0].Params[0].GoName())
+	assert.Equal(t, "typeParam", routes[4].Params[1].GoName())
+
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "web", routes, nil))
+	s := buf.String()
+	assert.Contains(t, s, "type UsersIdProfileLoader func(r *http.Request, id int) (interface{}, error)")
+	assert.Contains(t, s, "\tUsersIdProfile UsersIdProfileLoader\n")
+	assert.Contains(t, s, "\tUsersIndex Loader\n")
+	assert.Contains(t, s, "id, err := strconv.Atoi(params[0])")
+	assert.Contains(t, s, "return loaders.LangType(r, lang, typeParam)")
+	typeCheck(t, "web", buf.Bytes())
+
+	for route, expect := range map[string]string{
+		"/users/[id:float]": `invalid route "/users/[id:float]" for ./x.base.tmpl: parameter id has unsupported type float, it must be string or int`,
+		"/users/{id}/{id}":  `invalid route "/users/{id}/{id}" for ./x.base.tmpl: parameter id is used more than once`,
+		"/users/post-[id]":  `invalid route "/users/post-[id]" for ./x.base.tmpl: segment post-[id] is not a parameter, parameters must be a whole segment such as [id] or [id:int]`,
+	} {
+		f := newFS(t, map[string]string{"_base.tmpl": ``, "x.base.tmpl": ``})
+		require.NoError(t, f.SetRoute("x.base.tmpl", route))
+		_, err := Routes(f)
+		assert.EqualError(t, err, expect)
+	}
+
+	// parameters with different names match the same requests
+	f = newFS(t, map[string]string{"_base.tmpl": ``, "users/[id].base.tmpl": ``, "users/[name].base.tmpl": ``})
+	_, err = Routes(f)
+	var rerr *RouteError
+	require.ErrorAs(t, err, &rerr)
+	assert.Equal(t, []string{"users/[id].base.tmpl", "users/[name].base.tmpl"}, rerr.Conflicts[0].Targets)
+}
+
+func TestAliases(t *testing.T) {
+	f := newFS(t, map[string]string{
+		"_base.tmpl":               `{{ template "content" . }}`,
+		"about.base.tmpl":          `about`,
+		"users/[id:int].base.tmpl": `user`,
+	})
+	id, err := f.AddVirtual("docs/[lang]/[slug].base.tmpl", []byte("doc"))
+	require.NoError(t, err)
+	require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"aliases": `["/{slug}/{lang}", "/d/[lang]/[slug]"]`}))
+	id, err = f.AddVirtual("contact.base.tmpl", []byte("contact"))
+	require.NoError(t, err)
+	require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"aliases": "/contact-us, /about-us/"}))
+
+	routes, err := Routes(f)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/contact-us", "/about-us/"}, routes[1].Aliases)
+	assert.Equal(t, []string{"/{slug}/{lang}", "/d/{lang}/{slug}"}, routes[2].Aliases)
+	assert.Equal(t, []Redirect{
+		{From: "/about-us/", To: "/contact", Target: "./contact.base.tmpl"},
+		{From: "/contact-us", To: "/contact", Target: "./contact.base.tmpl"},
+		{From: "/d/{lang}/{slug}", To: "/docs/{lang}/{slug}", Target: "docs/[lang]/[slug].base.tmpl"},
+		{From: "/{slug}/{lang}", To: "/docs/{lang}/{slug}", Target: "docs/[lang]/[slug].base.tmpl"},
+	}, Redirects(routes))
+
+	values, ok := routes[2].Values("/docs/en/intro")
+	require.True(t, ok)
+	assert.Equal(t, "/intro/en", Fill(routes[2].Aliases[0], values))
+	_, ok = routes[2].Values("/docs/en/")
+	assert.False(t, ok)
+
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "web", routes, nil))
+	s := buf.String()
+	assert.Contains(t, s, `mux.Handle("/about-us/", routes{`)
+	assert.Contains(t, s, `{path: "/{slug}/{lang}", redirect: "/docs/{lang}/{slug}"},`)
+	assert.Equal(t, 1, strings.Count(s, `mux.Handle("/", routes{`))
+	typeCheck(t, "web", buf.Bytes())
+
+	for aliases, expect := range map[string]string{
+		"about":         `invalid alias "about" for users/[id:int].base.tmpl: aliases must be an absolute URL path`,
+		"/u/{name}":     `invalid alias "/u/{name}" for users/[id:int].base.tmpl: aliases must have the same parameters as the route /users/{id}`,
+		"/about":        `invalid alias "/about" for users/[id:int].base.tmpl: aliases must have the same parameters as the route /users/{id}`,
+		"/users/{uid}x": `invalid alias "/users/{uid}x" for users/[id:int].base.tmpl: segment {uid}x is not a parameter, parameters must be a whole segment such as [id] or [id:int]`,
+		"/d/{id}":       `invalid alias "/d/{id}" for users/[id:int].base.tmpl: it matches the same requests as a route or another alias`,
+		"[/u/{id}":      `invalid aliases for users/[id:int].base.tmpl: invalid list for aliases: invalid character '/' looking for beginning of value`,
+	} {
+		f := newFS(t, map[string]string{"_base.tmpl": ``, "d/[slug].base.tmpl": ``})
+		id, err := f.AddVirtual("users/[id:int].base.tmpl", nil)
+		require.NoError(t, err)
+		require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"aliases": aliases}))
+		_, err = Routes(f)
+		assert.EqualError(t, err, expect, "failed for %s", aliases)
+	}
+}
+
+func TestStaticRoutes(t *testing.T) {
+	f := newFS(t, map[string]string{
+		"_base.tmpl":      `{{ template "content" . }}`,
+		"index.base.tmpl": `home`,
+	})
+	id, err := f.AddVirtual("docs/[slug].base.tmpl", []byte("doc"))
+	require.NoError(t, err)
+	require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"render": "static"}))
+
+	routes, err := Routes(f)
+	require.NoError(t, err)
+	assert.False(t, routes[0].Static)
+	assert.True(t, routes[1].Static)
+
+	out, err := fs.New("/out")
+	require.NoError(t, err)
+	err = GenerateAll(f, out, "web", nil)
+	assert.EqualError(t, err, "failed to generate routes: docs/[slug].base.tmpl renders static but routes are generated without prerendering")
+
+	prerender := func(routes []Route) (map[string][]Page, error) {
+		return map[string][]Page{"docs/[slug].base.tmpl": {{URL: "/docs/intro", Body: []byte("<p>intro</p>")}, {URL: "/docs/page/2", Body: []byte("2")}}}, nil
+	}
+	require.NoError(t, GenerateAll(f, out, "web", prerender))
+	src, err := out.ReadFile("web/" + OutputFile)
+	require.NoError(t, err)
+	s := string(src)
+	etag := Page{Body: []byte("<p>intro</p>")}.ETag()
+	assert.Len(t, etag, 34)
+	assert.Contains(t, s, `"/docs/intro":  {etag: `+strconv.Quote(etag)+`, body: "<p>intro</p>"},`)
+	assert.Equal(t, 1, strings.Count(s, "<p>intro</p>"))
+	// the second page is outside the route and registered under its own URL
+	assert.Contains(t, s, `mux.Handle("/docs/page/2", routes{`)
+	assert.Equal(t, 2, strings.Count(s, "pages:       pagesDocsSlug,"))
+	assert.Contains(t, s, "\t\"time\"\n")
+	assert.NotContains(t, s, "DocsSlugLoader")
+	assert.NotContains(t, s, `"docs/[slug].base.tmpl": {name:`)
+	typeCheck(t, "web", src)
+
+	f = newFS(t, map[string]string{"_base.tmpl": ``})
+	id, err = f.AddVirtual("x.base.tmpl", nil)
+	require.NoError(t, err)
+	require.NoError(t, f.SetMetadata(id, 0, fs.Metadata{"render": "cached"}))
+	_, err = Routes(f)
+	assert.EqualError(t, err, `invalid render "cached" for ./x.base.tmpl: targets render static or dynamic`)
+}
@@ -0,0 +1,379 @@
+// Package schema validates data files against a subset of JSON Schema, written by hand or derived from a Go type
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types supported in the type keyword.  A schema without a type accepts any value.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// Schema is the subset of JSON Schema used to validate data files: type, properties, required,
+// additionalProperties, items, enum and pattern
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties rejects keys that are not in Properties when false, which catches mistyped keys
+	AdditionalProperties *bool         `json:"additionalProperties,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Error is a value that does not match its schema.  The path locates the value by key and index from the root of
+// the data, e.g. author.name or tags[2], and is empty for the root itself.
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e Error) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Parse reads a JSON schema, rejecting keywords with values outside the supported subset
+func Parse(src []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(src, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.compile(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile(path string) error {
+	switch s.Type {
+	case "", TypeObject, TypeArray, TypeString, TypeNumber, TypeInteger, TypeBoolean:
+	default:
+		return fmt.Errorf("invalid schema at %s: unsupported type %q", location(path), s.Type)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema at %s: %w", location(path), err)
+		}
+		s.pattern = re
+	}
+	for key, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("invalid schema at %s: property has no schema", location(join(path, key)))
+		}
+		if err := p.compile(join(path, key)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// For derives a schema from the type of v.  Structs are objects whose properties are their exported fields, named
+// by their json tag or else the field name, that reject other keys.  Fields are required unless tagged omitempty.
+// Maps are objects with any keys, slices and arrays are arrays, and time.Time is a string.
+func For(v interface{}) *Schema {
+	return forType(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func forType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: TypeString}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		closed := false
+		s := &Schema{Type: TypeObject, Properties: make(map[string]*Schema), AdditionalProperties: &closed}
+		addFields(s, t)
+		sort.Strings(s.Required)
+		return s
+	case reflect.Map:
+		return &Schema{Type: TypeObject}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: TypeString}
+		}
+		return &Schema{Type: TypeArray, Items: forType(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	}
+	return &Schema{}
+}
+
+// addFields adds the exported fields of a struct as properties, flattening embedded structs like encoding/json
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = forType(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// Validate returns every value in v that does not match the schema, in order of their path
+func (s *Schema) Validate(v interface{}) []Error {
+	var errs []Error
+	s.validate("", v, false, &errs)
+	return errs
+}
+
+// ValidateFrontMatter is Validate for the front matter of a markdown file, which may store scalars as text, so a
+// string is accepted as a number, integer or boolean when it parses as one
+func (s *Schema) ValidateFrontMatter(v interface{}) []Error {
+	var errs []Error
+	s.validate("", v, true, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v interface{}, text bool, errs *[]Error) {
+	// YAML timestamps are validated as the text that front matter stores them as
+	if t, ok := v.(time.Time); ok {
+		v = t.Format(time.RFC3339)
+	}
+	fail := func(path string, format string, args ...interface{}) {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.Type != "" && !matchesType(s.Type, v, text) {
+		fail(path, "expected %s, found %s", s.Type, typeOf(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		fail(path, "must be one of %s, found %v", strings.Join(values, ", "), v)
+	}
+	if str, ok := v.(string); ok && s.pattern != nil && !s.pattern.MatchString(str) {
+		fail(path, "%q does not match the pattern %s", str, s.Pattern)
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := val[key]; !ok {
+				fail(join(path, key), "is required")
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p, ok := s.Properties[key]
+			switch {
+			case ok:
+				p.validate(join(path, key), val[key], text, errs)
+			case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				if suggestion := s.closest(key); suggestion != "" {
+					fail(join(path, key), "is not allowed, did you mean %s?", suggestion)
+				} else {
+					fail(join(path, key), "is not allowed")
+				}
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range val {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, text, errs)
+		}
+	}
+}
+
+// closest returns the property within two edits of key, to suggest for a mistyped key
+func (s *Schema) closest(key string) string {
+	best, min := "", 3
+	for name := range s.Properties {
+		if d := distance(strings.ToLower(key), strings.ToLower(name)); d < min || (d == min && name < best) {
+			best, min = name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// matchesType reports whether v has the type.  Strings holding a number or boolean match when text is set.
+func matchesType(typ string, v interface{}, text bool) bool {
+	switch typ {
+	case TypeObject:
+		_, ok := v.(map[string]interface{})
+		return ok
+	case TypeArray:
+		_, ok := v.([]interface{})
+		return ok
+	case TypeString:
+		_, ok := v.(string)
+		return ok
+	case TypeBoolean:
+		switch val := v.(type) {
+		case bool:
+			return true
+		case string:
+			_, err := strconv.ParseBool(val)
+			return text && err == nil
+		}
+	case TypeNumber:
+		_, ok := number(v, text)
+		return ok
+	case TypeInteger:
+		n, ok := number(v, text)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+// number returns the value of a number, or of a string holding one when text is set
+func number(v interface{}, text bool) (float64, bool) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case string:
+		n, err := strconv.ParseFloat(val, 64)
+		return n, text && err == nil
+	}
+	return 0, false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf names the JSON type of a decoded value
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	}
+	if _, ok := number(v, false); ok {
+		return TypeNumber
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func location(path string) string {
+	if path == "" {
+		return "the root"
+	}
+	return path
+}
@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "object",
+		"required": ["title", "date"],
+		"additionalProperties": false,
+		"properties": {
+			"title": {"type": "string"},
+			"date": {"type": "string", "pattern": "^\\d{4}-\\d{2}-\\d{2}"},
+			"draft": {"type": "boolean"},
+			"weight": {"type": "integer"},
+			"status": {"enum": ["draft", "published"]},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"author": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+		}
+	}`))
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		data   string
+		expect []Error
+	}{
+		{name: "valid", data: "title: a\ndate: 2022-01-01\ndraft: false\nweight: 2\nstatus: draft\ntags: [a, b]\nauthor: {name: x}"},
+		{name: "text", data: "{title: a, date: 2022-01-01, draft: 'true', weight: '3'}", expect: []Error{
+			{Path: "draft", Message: "expected boolean, found string"},
+			{Path: "weight", Message: "expected integer, found string"},
+		}},
+		{name: "mistyped key", data: "title: a\ndate: 2022-01-01\ntilte: b\nauthr: {}\nzzz: 1", expect: []Error{
+			{Path: "authr", Message: "is not allowed, did you mean author?"},
+			{Path: "tilte", Message: "is not allowed, did you mean title?"},
+			{Path: "zzz", Message: "is not allowed"},
+		}},
+		{name: "missing", data: "author: {}", expect: []Error{
+			{Path: "title", Message: "is required"},
+			{Path: "date", Message: "is required"},
+			{Path: "author.name", Message: "is required"},
+		}},
+		{name: "types", data: "title: [a]\ndate: 01/01/2022\nweight: 1.5\nstatus: gone\ntags: [a, {b: c}]", expect: []Error{
+			{Path: "date", Message: `"01/01/2022" does not match the pattern ^\d{4}-\d{2}-\d{2}`},
+			{Path: "status", Message: "must be one of draft, published, found gone"},
+			{Path: "tags[1]", Message: "expected string, found object"},
+			{Path: "title", Message: "expected string, found array"},
+			{Path: "weight", Message: "expected integer, found number"},
+		}},
+		{name: "root", data: "[a]", expect: []Error{{Message: "expected object, found array"}}},
+	}
+	for _, tc := range tt {
+		var data interface{}
+		require.NoError(t, yaml.Unmarshal([]byte(tc.data), &data), "failed for %s", tc.name)
+		assert.Equal(t, tc.expect, s.Validate(data), "failed for %s", tc.name)
+	}
+
+	// front matter may store scalars as text
+	var data interface{}
+	require.NoError(t, yaml.Unmarshal([]byte("{title: a, date: 2022-01-01, draft: 'true', weight: '3'}"), &data))
+	assert.Empty(t, s.ValidateFrontMatter(data))
+
+	for _, src := range []string{`{"type": "date"}`, `{"pattern": "("}`, `{"properties": {"a": {"type": ["string"]}}}`, `[]`} {
+		_, err := Parse([]byte(src))
+		assert.Error(t, err, "failed for %s", src)
+	}
+}
+
+func TestFor(t *testing.T) {
+	type Meta struct {
+		Draft bool `json:"draft,omitempty"`
+	}
+	type post struct {
+		Meta
+		Title   string            `json:"title"`
+		Date    time.Time         `json:"date"`
+		Tags    []string          `json:"tags,omitempty"`
+		Weight  *int              `json:"weight,omitempty"`
+		Extra   map[string]string `json:"extra,omitempty"`
+		Summary string
+		Ignored string `json:"-"`
+		private string
+	}
+	closed := false
+	assert.Equal(t, &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"draft":   {Type: TypeBoolean},
+			"title":   {Type: TypeString},
+			"date":    {Type: TypeString},
+			"tags":    {Type: TypeArray, Items: &Schema{Type: TypeString}},
+			"weight":  {Type: TypeInteger},
+			"extra":   {Type: TypeObject},
+			"Summary": {Type: TypeString},
+		},
+		Required:             []string{"Summary", "date", "title"},
+		AdditionalProperties: &closed,
+	}, For(post{}))
+
+	var data interface{}
+	require.NoError(t, yaml.Unmarshal([]byte("title: a\ndate: 2022-01-01\nSummary: s\ntitel: b"), &data))
+	assert.Equal(t, []Error{{Path: "titel", Message: "is not allowed, did you mean title?"}}, For(&post{}).Validate(data))
+}
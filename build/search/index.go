@@ -0,0 +1,203 @@
+// Package search builds a client-side search index from rendered html pages
+package search
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// IgnoreAttr marks an element whose content is not indexed, such as navigation or a footer, e.g.
+// <nav data-search-ignore>
+const IgnoreAttr = "data-search-ignore"
+
+// Script is the client that loads an index and searches it.  Include it with the URL of the index:
+//
+//	<script src="/search.js" data-index="/search.json"></script>
+//
+// It defines taevasSearch(query), which resolves to the matching documents ranked by relevance, and renders results
+// as links into an element with data-search-results as the user types into an input with data-search-input.
+//
+//go:embed search.js
+var Script []byte
+
+// snippetLength is the maximum length in characters of the snippet of a document
+const snippetLength = 160
+
+// Doc is an indexed page.  Field names are abbreviated to keep the index small.
+type Doc struct {
+	Title   string `json:"t"`
+	URL     string `json:"u"`
+	Snippet string `json:"s"`
+}
+
+// Index is an inverted index from the stem of every word to the documents it occurs in.  Each posting list holds
+// pairs of document number and term frequency, e.g. [0, 3, 4, 1].
+type Index struct {
+	Docs  []Doc            `json:"docs"`
+	Terms map[string][]int `json:"terms"`
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{Docs: []Doc{}, Terms: make(map[string][]int)}
+}
+
+// Add indexes the text of an html page served at url.  The title is the title element or else the first h1, and the
+// snippet is the meta description or else the start of the text.  Scripts, styles and elements marked with
+// IgnoreAttr are skipped.
+func (i *Index) Add(url string, page []byte) error {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	e := &extractor{}
+	e.walk(doc)
+
+	text := strings.Join(strings.Fields(e.text.String()), " ")
+	d := Doc{Title: e.title, URL: url, Snippet: e.description}
+	if d.Title == "" {
+		d.Title = e.h1
+	}
+	if d.Title == "" {
+		d.Title = url
+	}
+	if d.Snippet == "" {
+		d.Snippet = snippet(text)
+	}
+
+	n := len(i.Docs)
+	i.Docs = append(i.Docs, d)
+	counts := make(map[string]int)
+	for _, t := range Tokenize(d.Title + " " + text) {
+		counts[t]++
+	}
+	for t, c := range counts {
+		i.Terms[t] = append(i.Terms[t], n, c)
+	}
+	return nil
+}
+
+// JSON encodes the index
+func (i *Index) JSON() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+// stopWords are common English words that are not indexed
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true, "their": true, "then": true, "there": true,
+	"these": true, "they": true, "this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// Tokenize splits text into lower case words, drops stop words and returns the stem of each
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if stopWords[w] {
+			continue
+		}
+		tokens = append(tokens, Stem(w))
+	}
+	return tokens
+}
+
+// snippet returns the start of text, cut at a word boundary
+func snippet(text string) string {
+	r := []rune(text)
+	if len(r) <= snippetLength {
+		return text
+	}
+	s := string(r[:snippetLength])
+	if i := strings.LastIndex(s, " "); i > 0 {
+		s = s[:i]
+	}
+	return s + "…"
+}
+
+// inline elements do not separate the words of the text around them
+var inline = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Code: true, atom.Em: true, atom.I: true, atom.Kbd: true,
+	atom.Mark: true, atom.Q: true, atom.S: true, atom.Small: true, atom.Span: true, atom.Strong: true,
+	atom.Sub: true, atom.Sup: true, atom.U: true,
+}
+
+// skipped elements have no indexable text
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+}
+
+type extractor struct {
+	title       string
+	h1          string
+	description string
+	text        strings.Builder
+}
+
+func (e *extractor) walk(n *html.Node) {
+	if n.Type == html.TextNode {
+		e.text.WriteString(n.Data)
+		return
+	}
+	if n.Type == html.ElementNode {
+		if skipped[n.DataAtom] || hasAttr(n, IgnoreAttr) {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Title:
+			e.title = strings.Join(strings.Fields(textOf(n)), " ")
+			return
+		case atom.Meta:
+			if attr(n, "name") == "description" {
+				e.description = attr(n, "content")
+			}
+		case atom.H1:
+			if e.h1 == "" {
+				e.h1 = strings.Join(strings.Fields(textOf(n)), " ")
+			}
+		}
+		if !inline[n.DataAtom] {
+			e.text.WriteString(" ")
+			defer e.text.WriteString(" ")
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.walk(c)
+	}
+}
+
+// textOf returns the text inside n
+func textOf(n *html.Node) string {
+	e := &extractor{}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.walk(c)
+	}
+	return e.text.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	idx := New()
+	require.NoError(t, idx.Add("/", []byte(`<html><head><title>Home  page</title><style>.connected{}</style></head>
+<body><nav data-search-ignore><a href="/docs">Docs</a></nav>
+<h1>Welcome</h1><p>Templates are <b>connect</b>ed to layouts.</p><script>var templates;</script>
+<footer data-search-ignore><p>Copyright</p></footer></body></html>`)))
+	require.NoError(t, idx.Add("/docs/", []byte(`<html><head><meta name="description" content="How layouts work"></head>
+<body><h1>Layouts</h1><p>A layout is a template. Layouts connect templates.</p></body></html>`)))
+	require.NoError(t, idx.Add("/empty", []byte(`<p>`+strings.Repeat("word ", 40)+`</p>`)))
+
+	assert.Equal(t, []Doc{
+		{Title: "Home page", URL: "/", Snippet: "Welcome Templates are connected to layouts."},
+		{Title: "Layouts", URL: "/docs/", Snippet: "How layouts work"},
+		{Title: "/empty", URL: "/empty", Snippet: strings.TrimSpace(strings.Repeat("word ", 32)) + "…"},
+	}, idx.Docs)
+	assert.Equal(t, []int{0, 1, 1, 2}, idx.Terms["templat"])
+	assert.Equal(t, []int{0, 1, 1, 4}, idx.Terms["layout"])
+	assert.Equal(t, []int{0, 1, 1, 1}, idx.Terms["connect"])
+	assert.Equal(t, []int{0, 1}, idx.Terms["home"])
+	for _, ignored := range []string{"doc", "copyright", "var", "are", "the"} {
+		assert.NotContains(t, idx.Terms, ignored)
+	}
+
+	b, err := idx.JSON()
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Contains(t, decoded, "docs")
+	assert.Contains(t, decoded, "terms")
+	assert.Contains(t, string(Script), "window.taevasSearch")
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"run", "templat", "fast", "2022", "café"}, Tokenize("Running the TEMPLATES, fast-2022 café!"))
+}
@@ -0,0 +1,190 @@
+// Searches an index written by a taevas static build.  Include with
+// <script src="/search.js" data-index="/search.json"></script>
+(function () {
+  "use strict";
+
+  var script = document.currentScript;
+  var indexURL = (script && script.getAttribute("data-index")) || "/search.json";
+  var loading = null;
+
+  var stopWords = {};
+  ("a an and are as at be but by for if in into is it no not of on or such that the their then there these they " +
+    "this to was will with").split(" ").forEach(function (w) { stopWords[w] = true; });
+
+  // Porter stemmer, identical to Stem in the search package
+  function isCons(w, i) {
+    var c = w[i];
+    if (c === "a" || c === "e" || c === "i" || c === "o" || c === "u") return false;
+    if (c === "y") return i === 0 || !isCons(w, i - 1);
+    return true;
+  }
+  function measure(w) {
+    var n = 0, i = 0;
+    while (i < w.length && isCons(w, i)) i++;
+    while (i < w.length) {
+      while (i < w.length && !isCons(w, i)) i++;
+      if (i >= w.length) break;
+      while (i < w.length && isCons(w, i)) i++;
+      n++;
+    }
+    return n;
+  }
+  function hasVowel(w) {
+    for (var i = 0; i < w.length; i++) if (!isCons(w, i)) return true;
+    return false;
+  }
+  function endsDouble(w) {
+    var l = w.length;
+    return l >= 2 && w[l - 1] === w[l - 2] && isCons(w, l - 1);
+  }
+  function endsCVC(w) {
+    var l = w.length;
+    if (l < 3 || !isCons(w, l - 3) || isCons(w, l - 2) || !isCons(w, l - 1)) return false;
+    var c = w[l - 1];
+    return c !== "w" && c !== "x" && c !== "y";
+  }
+  function ends(w, s) {
+    return w.length >= s.length && w.slice(w.length - s.length) === s;
+  }
+  var step2 = [["ational", "ate"], ["tional", "tion"], ["enci", "ence"], ["anci", "ance"], ["izer", "ize"],
+    ["bli", "ble"], ["alli", "al"], ["entli", "ent"], ["eli", "e"], ["ousli", "ous"], ["ization", "ize"],
+    ["ation", "ate"], ["ator", "ate"], ["alism", "al"], ["iveness", "ive"], ["fulness", "ful"], ["ousness", "ous"],
+    ["aliti", "al"], ["iviti", "ive"], ["biliti", "ble"], ["logi", "log"]];
+  var step3 = [["icate", "ic"], ["ative", ""], ["alize", "al"], ["iciti", "ic"], ["ical", "ic"], ["ful", ""],
+    ["ness", ""]];
+  var step4 = ["al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ion", "ou", "ism",
+    "ate", "iti", "ous", "ive", "ize"];
+  function replaceSuffix(w, rules) {
+    for (var i = 0; i < rules.length; i++) {
+      if (!ends(w, rules[i][0])) continue;
+      var stem = w.slice(0, w.length - rules[i][0].length);
+      return measure(stem) > 0 ? stem + rules[i][1] : w;
+    }
+    return w;
+  }
+  function stem(w) {
+    if (w.length <= 2) return w;
+    if (ends(w, "sses") || ends(w, "ies")) w = w.slice(0, -2);
+    else if (!ends(w, "ss") && ends(w, "s")) w = w.slice(0, -1);
+
+    if (ends(w, "eed")) {
+      if (measure(w.slice(0, -3)) > 0) w = w.slice(0, -1);
+    } else {
+      var s = null;
+      if (ends(w, "ed") && hasVowel(w.slice(0, -2))) s = w.slice(0, -2);
+      else if (ends(w, "ing") && hasVowel(w.slice(0, -3))) s = w.slice(0, -3);
+      if (s !== null) {
+        if (ends(s, "at") || ends(s, "bl") || ends(s, "iz")) s += "e";
+        else if (endsDouble(s)) {
+          var c = s[s.length - 1];
+          if (c !== "l" && c !== "s" && c !== "z") s = s.slice(0, -1);
+        } else if (measure(s) === 1 && endsCVC(s)) s += "e";
+        w = s;
+      }
+    }
+
+    if (ends(w, "y") && hasVowel(w.slice(0, -1))) w = w.slice(0, -1) + "i";
+    w = replaceSuffix(w, step2);
+    w = replaceSuffix(w, step3);
+
+    var match = "";
+    step4.forEach(function (suffix) {
+      if (ends(w, suffix) && suffix.length > match.length) match = suffix;
+    });
+    if (match) {
+      var st = w.slice(0, w.length - match.length);
+      if ((match !== "ion" || ends(st, "s") || ends(st, "t")) && measure(st) > 1) w = st;
+    }
+
+    if (ends(w, "e")) {
+      var e = w.slice(0, -1), m = measure(e);
+      if (m > 1 || (m === 1 && !endsCVC(e))) w = e;
+    }
+    if (ends(w, "ll") && measure(w) > 1) w = w.slice(0, -1);
+    return w;
+  }
+
+  function words(text) {
+    return text.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function (w) {
+      return w && !stopWords[w];
+    });
+  }
+
+  function load() {
+    if (!loading) {
+      loading = fetch(indexURL).then(function (r) {
+        if (!r.ok) throw new Error("failed to load search index " + indexURL + ": " + r.status);
+        return r.json();
+      });
+    }
+    return loading;
+  }
+
+  // search resolves to the documents containing every word of the query, ranked by tf-idf.  The last word also
+  // matches longer words so that results appear while typing, before the partial word stems like the whole one.
+  function search(query) {
+    return load().then(function (index) {
+      var raw = words(query);
+      if (!raw.length) return [];
+      var n = index.docs.length, scores = null;
+      raw.forEach(function (word, i) {
+        var stemmed = stem(word), terms = [stemmed];
+        if (i === raw.length - 1) {
+          terms = Object.keys(index.terms).filter(function (t) {
+            return t.indexOf(stemmed) === 0 || t.indexOf(word) === 0;
+          });
+        }
+        var matched = {};
+        terms.forEach(function (t) {
+          var postings = index.terms[t] || [];
+          var idf = Math.log(1 + n / (postings.length / 2));
+          for (var p = 0; p < postings.length; p += 2) {
+            matched[postings[p]] = (matched[postings[p]] || 0) + postings[p + 1] * idf;
+          }
+        });
+        if (scores === null) {
+          scores = matched;
+          return;
+        }
+        Object.keys(scores).forEach(function (d) {
+          if (matched[d] === undefined) delete scores[d];
+          else scores[d] += matched[d];
+        });
+      });
+      return Object.keys(scores).map(function (d) {
+        var doc = index.docs[d];
+        return { title: doc.t, url: doc.u, snippet: doc.s, score: scores[d] };
+      }).sort(function (a, b) { return b.score - a.score; });
+    });
+  }
+
+  function render(results, container) {
+    container.textContent = "";
+    results.forEach(function (r) {
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = r.url;
+      link.textContent = r.title;
+      var snippet = document.createElement("p");
+      snippet.textContent = r.snippet;
+      item.appendChild(link);
+      item.appendChild(snippet);
+      container.appendChild(item);
+    });
+  }
+
+  window.taevasSearch = search;
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var input = document.querySelector("[data-search-input]");
+    var container = document.querySelector("[data-search-results]");
+    if (!input || !container) return;
+    var latest = 0;
+    input.addEventListener("input", function () {
+      var id = ++latest;
+      search(input.value).then(function (results) {
+        if (id === latest) render(results, container);
+      });
+    });
+  });
+})();
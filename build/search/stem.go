@@ -0,0 +1,193 @@
+package search
+
+// Stem reduces an English word in lower case to its stem with the Porter stemming algorithm, so that forms such as
+// connect, connected and connection are indexed together.  search.js implements the same algorithm for queries.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	w := []byte(word)
+	w = step1ab(w)
+	w = step1c(w)
+	w = replaceSuffix(w, step2, 0)
+	w = replaceSuffix(w, step3, 0)
+	w = step4(w)
+	w = step5(w)
+	return string(w)
+}
+
+// isCons reports whether the letter at i is a consonant.  y is a consonant at the start of a word or after a vowel.
+func isCons(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isCons(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, m in [C](VC){m}[V]
+func measure(w []byte) int {
+	n, i := 0, 0
+	for i < len(w) && isCons(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !isCons(w, i) {
+			i++
+		}
+		if i >= len(w) {
+			break
+		}
+		for i < len(w) && isCons(w, i) {
+			i++
+		}
+		n++
+	}
+	return n
+}
+
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !isCons(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDouble reports whether w ends in a double consonant
+func endsDouble(w []byte) bool {
+	l := len(w)
+	return l >= 2 && w[l-1] == w[l-2] && isCons(w, l-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant where the last consonant is not w, x or y, as in hop
+func endsCVC(w []byte) bool {
+	l := len(w)
+	if l < 3 || !isCons(w, l-3) || isCons(w, l-2) || !isCons(w, l-1) {
+		return false
+	}
+	c := w[l-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+func hasSuffix(w []byte, s string) bool {
+	return len(w) >= len(s) && string(w[len(w)-len(s):]) == s
+}
+
+func step1ab(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		w = w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+	case hasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			w = w[:len(w)-1]
+		}
+		return w
+	}
+	var stem []byte
+	switch {
+	case hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		stem = w[:len(w)-2]
+	case hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		stem = w[:len(w)-3]
+	default:
+		return w
+	}
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem, 'e')
+	case endsDouble(stem):
+		if c := stem[len(stem)-1]; c != 'l' && c != 's' && c != 'z' {
+			return stem[:len(stem)-1]
+		}
+	case measure(stem) == 1 && endsCVC(stem):
+		return append(stem, 'e')
+	}
+	return stem
+}
+
+func step1c(w []byte) []byte {
+	if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		w[len(w)-1] = 'i'
+	}
+	return w
+}
+
+// rule replaces a suffix with a replacement
+type rule struct {
+	suffix, replacement string
+}
+
+var step2 = []rule{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"}, {"bli", "ble"},
+	{"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}, {"aliti", "al"},
+	{"iviti", "ive"}, {"biliti", "ble"}, {"logi", "log"},
+}
+
+var step3 = []rule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// replaceSuffix applies the first rule whose suffix w ends in when the measure of the rest of w is greater than m.
+// Rules after the first matching suffix are not tried even when the measure is too small.
+func replaceSuffix(w []byte, rules []rule, m int) []byte {
+	for _, r := range rules {
+		if !hasSuffix(w, r.suffix) {
+			continue
+		}
+		stem := w[:len(w)-len(r.suffix)]
+		if measure(stem) > m {
+			return append(stem, r.replacement...)
+		}
+		return w
+	}
+	return w
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ion", "ou", "ism", "ate",
+	"iti", "ous", "ive", "ize",
+}
+
+func step4(w []byte) []byte {
+	// the longest matching suffix is removed, e.g. ement rather than ment or ent
+	match := ""
+	for _, s := range step4Suffixes {
+		if hasSuffix(w, s) && len(s) > len(match) {
+			match = s
+		}
+	}
+	if match == "" {
+		return w
+	}
+	stem := w[:len(w)-len(match)]
+	if match == "ion" && !hasSuffix(stem, "s") && !hasSuffix(stem, "t") {
+		return w
+	}
+	if measure(stem) > 1 {
+		return stem
+	}
+	return w
+}
+
+func step5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		stem := w[:len(w)-1]
+		if m := measure(stem); m > 1 || (m == 1 && !endsCVC(stem)) {
+			w = stem
+		}
+	}
+	if hasSuffix(w, "ll") && measure(w) > 1 {
+		w = w[:len(w)-1]
+	}
+	return w
+}
@@ -0,0 +1,24 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	for word, stem := range map[string]string{
+		"caresses": "caress", "ponies": "poni", "ties": "ti", "caress": "caress", "cats": "cat",
+		"feed": "feed", "agreed": "agre", "plastered": "plaster", "motoring": "motor", "sing": "sing",
+		"conflated": "conflat", "troubled": "troubl", "sized": "size", "hopping": "hop", "tanned": "tan",
+		"falling": "fall", "hissing": "hiss", "fizzed": "fizz", "failing": "fail", "filing": "file",
+		"happy": "happi", "sky": "sky", "relational": "relat", "conditional": "condit", "rational": "ration",
+		"valenci": "valenc", "digitizer": "digit", "generalization": "gener", "connection": "connect",
+		"connected": "connect", "connecting": "connect", "adjustment": "adjust", "dependent": "depend",
+		"adoption": "adopt", "electrical": "electr", "hopefulness": "hope", "probate": "probat", "rate": "rate",
+		"cease": "ceas", "controll": "control", "roll": "roll", "running": "run", "templates": "templat",
+		"is": "is", "a": "a",
+	} {
+		assert.Equal(t, stem, Stem(word), "failed for %s", word)
+	}
+}
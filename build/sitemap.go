@@ -0,0 +1,249 @@
+package build
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/taevas/build/route"
+)
+
+// indexed is a page that may be listed in the sitemap, robots.txt and the search index.  Targets and collection items
+// opt out of the sitemap with sitemap: false in their front matter, out of the search index with search: false and
+// out of both and crawling with noindex: true.
+type indexed struct {
+	url        string
+	modtime    time.Time
+	unlisted   bool
+	unsearched bool
+	noindex    bool
+}
+
+// optOut records the opt outs in the metadata of a target or item
+func (p *indexed) optOut(m map[string]interface{}) {
+	p.unlisted = p.unlisted || fmt.Sprint(m["sitemap"]) == "false"
+	p.unsearched = p.unsearched || fmt.Sprint(m["search"]) == "false"
+	p.noindex = p.noindex || fmt.Sprint(m["noindex"]) == "true"
+}
+
+// feed is a collection listed by a target without route parameters, which is published as Atom and RSS feeds below
+// the route of the target unless it sets feed: false
+type feed struct {
+	r     route.Route
+	data  interface{}
+	items []Item
+}
+
+// siteFiles returns sitemap.xml, robots.txt and the feeds of collections by output path.  The sitemap and feeds need
+// absolute URLs and are only generated when a base URL is set.
+func (c *Context) siteFiles(pages []indexed, feeds []feed) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	base := c.opts.baseURL
+
+	var robots bytes.Buffer
+	robots.WriteString("User-agent: *\n")
+	disallowed := 0
+	for _, p := range pages {
+		if p.noindex {
+			// rules match URLs by prefix unless they end in $, which would also disallow /private-notes for /private
+			fmt.Fprintf(&robots, "Disallow: %s$\n", p.url)
+			disallowed++
+		}
+	}
+	if disallowed == 0 {
+		robots.WriteString("Disallow:\n")
+	}
+	if base != "" {
+		fmt.Fprintf(&robots, "\nSitemap: %s/sitemap.xml\n", base)
+	}
+	files["robots.txt"] = robots.Bytes()
+
+	if base == "" {
+		return files, nil
+	}
+
+	set := urlset{}
+	for _, p := range pages {
+		if p.unlisted || p.noindex {
+			continue
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + p.url, LastMod: p.modtime.UTC().Format(time.RFC3339)})
+	}
+	b, err := marshalXML(set)
+	if err != nil {
+		return nil, err
+	}
+	files["sitemap.xml"] = b
+
+	for _, f := range feeds {
+		dir := strings.TrimPrefix(f.r.Path, "/")
+		if dir != "" && !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+		atom, rss := c.feeds(f)
+		if atom == nil {
+			continue
+		}
+		atom.Links = append(atom.Links, atomLink{Href: base + "/" + dir + "atom.xml", Rel: "self"})
+		if files[dir+"atom.xml"], err = marshalXML(atom); err != nil {
+			return nil, err
+		}
+		if files[dir+"rss.xml"], err = marshalXML(rss); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// feeds returns the Atom and RSS feeds of the items with pages in a collection, or nil when none have pages.  Items
+// are titled by their title key, dated by their date key or else the modification time of their file, and summarised
+// by their summary or description key.
+func (c *Context) feeds(f feed) (*atomFeed, *rssFeed) {
+	base := c.opts.baseURL
+	title, description := f.r.Path, ""
+	if data, ok := f.data.(map[string]interface{}); ok {
+		title = stringValue(data, title, "title")
+		description = stringValue(data, "", "description")
+	}
+	if description == "" {
+		description = title
+	}
+
+	atom := &atomFeed{Title: title, ID: base + f.r.Path, Links: []atomLink{{Href: base + f.r.Path}}}
+	rss := &rssFeed{Version: "2.0", Channel: rssChannel{Title: title, Link: base + f.r.Path, Description: description}}
+	var updated time.Time
+	for _, i := range f.items {
+		if i.URL == "" {
+			continue
+		}
+		date := i.modtime
+		switch v := i.Data["date"].(type) {
+		case time.Time:
+			date = v
+		case string:
+			for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					date = t
+					break
+				}
+			}
+		}
+		if date.After(updated) {
+			updated = date
+		}
+		title := stringValue(i.Data, i.Slug, "title")
+		summary := stringValue(i.Data, "", "summary", "description")
+		atom.Entries = append(atom.Entries, atomEntry{
+			Title:   title,
+			ID:      base + i.URL,
+			Updated: date.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: base + i.URL},
+			Summary: summary,
+		})
+		rss.Channel.Items = append(rss.Channel.Items, rssItem{
+			Title:       title,
+			Link:        base + i.URL,
+			GUID:        base + i.URL,
+			PubDate:     date.UTC().Format(time.RFC1123Z),
+			Description: summary,
+		})
+	}
+	if len(atom.Entries) == 0 {
+		return nil, nil
+	}
+	atom.Updated = updated.UTC().Format(time.RFC3339)
+	return atom, rss
+}
+
+// pageModTime returns the latest modification time of the template tree and data of a page
+func (c *Context) pageModTime(target string, files ...string) (time.Time, error) {
+	tree, err := c.InputFS.TargetTree(target)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.InputFS.ModTime(append(tree, files...)...)
+}
+
+// stringValue returns the first of keys with a value in data, or def
+func stringValue(data map[string]interface{}, def string, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := data[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return def
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode xml: %w", err)
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+type urlset struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// sortedPaths returns the keys of files in order
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,119 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteFiles(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"index.layout.tmpl":       `{{ define "content" }}home{{ end }}`,
+		"private.layout.tmpl":     "---\nnoindex: true\n---\n{{ define \"content\" }}private{{ end }}",
+		"thanks.layout.tmpl":      "---\nsitemap: false\n---\n{{ define \"content\" }}thanks{{ end }}",
+		"blog/index.layout.tmpl":  "---\ntitle: Blog\ncollection: posts\nsort: date\norder: desc\n---\n{{ define \"content\" }}blog{{ end }}",
+		"blog/[slug].layout.tmpl": "---\ncollection: posts\n---\n{{ define \"content\" }}{{ .Item.Data.title }}{{ end }}",
+		"posts/first.md":          "---\ntitle: First\ndate: 2022-01-02\nsummary: The first post\n---\n",
+		"posts/hidden.md":         "---\ntitle: Hidden\ndate: 2022-01-03\nsitemap: false\n---\n",
+	})
+	mtime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		return os.Chtimes(path, mtime, mtime)
+	}))
+	later := mtime.Add(24 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "posts/first.md"), later, later))
+
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false), WithBaseURL("https://example.com/"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	site, err := ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blog/atom.xml", "blog/rss.xml", "robots.txt", "sitemap.xml"}, site.Generated)
+
+	read := func(path string) string {
+		b, err := os.ReadFile(filepath.Join(out, path))
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "User-agent: *\nDisallow: /private$\n\nSitemap: https://example.com/sitemap.xml\n", read("robots.txt"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+    <lastmod>2022-05-01T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://example.com/blog/</loc>
+    <lastmod>2022-05-01T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://example.com/blog/first</loc>
+    <lastmod>2022-05-02T12:00:00Z</lastmod>
+  </url>
+</urlset>
+`, read("sitemap.xml"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog</title>
+  <id>https://example.com/blog/</id>
+  <updated>2022-01-03T00:00:00Z</updated>
+  <link href="https://example.com/blog/"></link>
+  <link href="https://example.com/blog/atom.xml" rel="self"></link>
+  <entry>
+    <title>Hidden</title>
+    <id>https://example.com/blog/hidden</id>
+    <updated>2022-01-03T00:00:00Z</updated>
+    <link href="https://example.com/blog/hidden"></link>
+  </entry>
+  <entry>
+    <title>First</title>
+    <id>https://example.com/blog/first</id>
+    <updated>2022-01-02T00:00:00Z</updated>
+    <link href="https://example.com/blog/first"></link>
+    <summary>The first post</summary>
+  </entry>
+</feed>
+`, read("blog/atom.xml"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Blog</title>
+    <link>https://example.com/blog/</link>
+    <description>Blog</description>
+    <item>
+      <title>Hidden</title>
+      <link>https://example.com/blog/hidden</link>
+      <guid>https://example.com/blog/hidden</guid>
+      <pubDate>Mon, 03 Jan 2022 00:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>First</title>
+      <link>https://example.com/blog/first</link>
+      <guid>https://example.com/blog/first</guid>
+      <pubDate>Sun, 02 Jan 2022 00:00:00 +0000</pubDate>
+      <description>The first post</description>
+    </item>
+  </channel>
+</rss>
+`, read("blog/rss.xml"))
+
+	// without a base URL only robots.txt is generated
+	out = filepath.Join(root, "relative")
+	ctx, err = New(root, WithOutputDirectory(out, false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	site, err = ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"robots.txt"}, site.Generated)
+	assert.Equal(t, "User-agent: *\nDisallow: /private$\n", read("robots.txt"))
+
+	_, err = New(root, WithBaseURL("example.com"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,560 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/route"
+	"github.com/BTBurke/taevas/build/search"
+	"github.com/BTBurke/taevas/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// dataExtensions are the extensions of data files in order of precedence
+var dataExtensions = []string{".json", ".yaml", ".yml"}
+
+// Page is a target rendered to a file by Build
+type Page struct {
+	Target string
+	// URL is the route with its parameters filled in, e.g. /users/1/profile
+	URL string
+	// Path is the file written to the output directory, e.g. users/1/profile/index.html
+	Path string
+}
+
+// Site is the result of a static build
+type Site struct {
+	Pages []Page
+	// Skipped lists targets with route parameters that have no data file listing the values of their parameters
+	Skipped []string
+	// Generated lists the files written for the whole site, such as sitemap.xml, robots.txt and collection feeds
+	Generated []string
+	// Redirects lists the stubs written at the aliases of html targets, which refresh to the page they alias
+	Redirects []Page
+}
+
+// Build renders every target to a file in OutputFS under the empty key, along with the files generated for the whole
+// site, and flushes it to the output directory.  Existing files are only replaced when the output directory allows
+// overwriting.
+func (c *Context) Build() (*Site, error) {
+	return c.build(nil)
+}
+
+// Rebuild renders targets again, such as those that Rescan reports as changed, and reuses the output of the previous
+// build for every other target.  Site files are generated again from every page, and files of the previous build that
+// no target writes any more are removed from the output directory.  OutputFS holds every page, where reused pages are
+// read from the output directory.  It is the same as Build when nothing was built before.
+func (c *Context) Rebuild(targets []string) (*Site, error) {
+	if c.built == nil {
+		return c.build(nil)
+	}
+	changed := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		changed[t] = true
+	}
+	return c.build(changed)
+}
+
+// builtTarget is the output of a target in a build, which Rebuild reuses when the target has not changed
+type builtTarget struct {
+	pages     []Page
+	stubs     []Page
+	skipped   bool
+	index     []indexed
+	docs      []searchDoc
+	feed      *feed
+	redirects []redirect
+}
+
+// searchDoc is a rendered page to add to the search index
+type searchDoc struct {
+	url  string
+	body []byte
+}
+
+// build renders the targets in changed, or every target when changed is nil
+func (c *Context) build(changed map[string]bool) (*Site, error) {
+	if changed == nil {
+		// a full build neither reuses nor replaces the output of a previous build
+		c.built, c.written = nil, nil
+	}
+	routes, err := route.Routes(c.InputFS)
+	if err != nil {
+		return nil, err
+	}
+	out, err := fs.New(c.opts.outDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output filesystem: %w", err)
+	}
+	if err := c.opts.configure(out); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	urls, err := c.itemURLs(routes)
+	if err != nil {
+		return nil, err
+	}
+
+	site := &Site{}
+	written := make(map[string]string)
+	built := make(map[string]*builtTarget, len(routes))
+	var index []indexed
+	var feeds []feed
+	var redirects []redirect
+	var idx *search.Index
+	if c.opts.search {
+		idx = search.New()
+	}
+	for _, r := range routes {
+		b, ok := c.built[r.Target]
+		if ok && changed != nil && !changed[r.Target] {
+			// pages of unchanged targets are left on disk and added to the output as they are
+			for _, p := range append(append([]Page(nil), b.pages...), b.stubs...) {
+				if err := claim(written, r.Target, p.Path); err != nil {
+					return nil, err
+				}
+				if _, err := out.Add(p.Path); err != nil {
+					return nil, fmt.Errorf("failed to add %s: %w", p.Path, err)
+				}
+			}
+		} else if b, err = c.buildTarget(r, urls, out, written); err != nil {
+			return nil, err
+		}
+		built[r.Target] = b
+
+		if b.skipped {
+			site.Skipped = append(site.Skipped, r.Target)
+			continue
+		}
+		site.Pages = append(site.Pages, b.pages...)
+		site.Redirects = append(site.Redirects, b.stubs...)
+		index = append(index, b.index...)
+		redirects = append(redirects, b.redirects...)
+		if b.feed != nil {
+			feeds = append(feeds, *b.feed)
+		}
+		if idx == nil {
+			continue
+		}
+		for _, d := range b.docs {
+			if err := idx.Add(d.url, d.body); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// targets rendering robots.txt, sitemap.xml or a feed replace the generated file
+	files, err := c.siteFiles(index, feeds)
+	if err != nil {
+		return nil, err
+	}
+	if idx != nil {
+		if files["search.json"], err = idx.JSON(); err != nil {
+			return nil, fmt.Errorf("failed to encode search index: %w", err)
+		}
+		files["search.js"] = search.Script
+	}
+	for _, path := range sortedPaths(files) {
+		if _, ok := written[path]; ok {
+			continue
+		}
+		if err := c.write(out, written, "the site", path, files[path]); err != nil {
+			return nil, err
+		}
+		site.Generated = append(site.Generated, path)
+	}
+
+	if len(redirects) > 0 {
+		if err := c.writeRedirectsFile(redirects); err != nil {
+			return nil, err
+		}
+		written[redirectsFileName] = "the site"
+		site.Generated = append(site.Generated, redirectsFileName)
+	}
+	if err := c.removeStale(written); err != nil {
+		return nil, err
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+	c.OutputFS[""] = out
+	c.built, c.written = built, written
+	return site, nil
+}
+
+// buildTarget renders and writes every page of a route
+func (c *Context) buildTarget(r route.Route, urls map[string]map[string]string, out *fs.Filesystem, written map[string]string) (*builtTarget, error) {
+	tp, err := c.targetPages(r, urls)
+	if err != nil {
+		return nil, err
+	}
+	b := &builtTarget{skipped: tp.skipped}
+	if tp.skipped {
+		return b, nil
+	}
+	if tp.feed != nil && tp.m.Get("feed", "") != "false" {
+		b.feed = tp.feed
+	}
+
+	t, err := ParseTree(c.InputFS, r.Target, c.opts.funcs)
+	if err != nil {
+		return nil, err
+	}
+	var sources []string
+	if file, ok := c.dataFile(r.Target); ok {
+		sources = append(sources, file)
+	}
+	meta := decodeMetadata(tp.m)
+	for i, p := range tp.pages {
+		p.Path = outputPath(p.URL, r.Type)
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, tp.entries[i]); err != nil {
+			return nil, fmt.Errorf("failed to render %s at %s: %w", r.Target, p.URL, err)
+		}
+		body := buf.Bytes()
+		if c.opts.liveReload != "" && r.Type == fs.TypeHTML {
+			body = injectScript(body, c.opts.liveReload)
+		}
+		if err := c.write(out, written, r.Target, p.Path, body); err != nil {
+			return nil, err
+		}
+		b.pages = append(b.pages, p)
+
+		aliases := aliasRedirects(r, p)
+		b.redirects = append(b.redirects, aliases...)
+		if r.Type != fs.TypeHTML {
+			continue
+		}
+		stubs, err := c.writeRedirects(out, written, r.Target, aliases)
+		if err != nil {
+			return nil, err
+		}
+		b.stubs = append(b.stubs, stubs...)
+		page := indexed{url: p.URL}
+		page.optOut(meta)
+		files := sources
+		if l, ok := tp.entries[i].(*Listing); ok && l.Item != nil {
+			files = append([]string{l.Item.path}, sources...)
+			page.optOut(l.Item.Data)
+		}
+		if page.modtime, err = c.pageModTime(r.Target, files...); err != nil {
+			return nil, err
+		}
+		b.index = append(b.index, page)
+		if c.opts.search && !page.noindex && !page.unsearched {
+			b.docs = append(b.docs, searchDoc{url: p.URL, body: buf.Bytes()})
+		}
+	}
+	return b, nil
+}
+
+// injectScript inserts a script loaded from src before the closing body tag of a page
+func injectScript(page []byte, src string) []byte {
+	tag := `<script src="` + html.EscapeString(src) + `"></script>`
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(append([]byte(nil), page...), tag...)
+	}
+	out := make([]byte, 0, len(page)+len(tag))
+	out = append(out, page[:i]...)
+	out = append(out, tag...)
+	return append(out, page[i:]...)
+}
+
+// removeStale removes the files of the previous build that were not written again
+func (c *Context) removeStale(written map[string]string) error {
+	for path := range c.written {
+		if _, ok := written[path]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.opts.outDir, path)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s from a previous build: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// targetPages are the pages of a route and the data each of them is rendered with
+type targetPages struct {
+	m       fs.Metadata
+	pages   []Page
+	entries []interface{}
+	// feed is the collection listed by a route without parameters
+	feed *feed
+	// skipped is set for a route with parameters that has no data file listing their values
+	skipped bool
+}
+
+// targetPages returns the pages of a route from its data file, front matter or collection.  A route declaring a
+// collection has a page per item when it has parameters and per page of items otherwise, and other routes with
+// parameters have a page per entry in their data file.  urls holds the URL of every item by collection directory and
+// slug.
+func (c *Context) targetPages(r route.Route, urls map[string]map[string]string) (*targetPages, error) {
+	data, ok, err := c.loadData(r.Target)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.InputFS.Metadata(r.Target)
+	if err != nil {
+		return nil, err
+	}
+	col, err := parseCollection(r.Target, m)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := &targetPages{m: m}
+	switch {
+	case col != nil:
+		items, err := c.items(col)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.Params) > 0 {
+			tp.pages, tp.entries, err = itemPages(r, items, data)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		for i := range items {
+			items[i].URL = urls[col.dir][items[i].Slug]
+		}
+		tp.pages, tp.entries = listPages(r, items, col.perPage, data)
+		tp.feed = &feed{r: r, data: data, items: items}
+	case len(r.Params) == 0:
+		tp.pages = []Page{{Target: r.Target, URL: r.Path}}
+		tp.entries = []interface{}{data}
+	case !ok:
+		tp.skipped = true
+	default:
+		tp.pages, tp.entries, err = expand(r, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tp, nil
+}
+
+// write adds a file written by source to the output filesystem, failing when another target writes the same file or
+// when it would replace a file on disk that the output directory does not allow overwriting
+func (c *Context) write(out *fs.Filesystem, written map[string]string, source string, path string, data []byte) error {
+	if err := claim(written, source, path); err != nil {
+		return err
+	}
+	// files of the previous build are replaced by a rebuild
+	if _, ok := c.written[path]; !ok && !c.opts.outDirOverwrite {
+		if _, err := os.Stat(filepath.Join(c.opts.outDir, path)); err == nil {
+			return fmt.Errorf("failed to build %s: %s already exists in the output directory", source, path)
+		}
+	}
+	if _, err := out.AddVirtual(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// claim records that source writes path, failing when another source already does
+func claim(written map[string]string, source string, path string) error {
+	if other, ok := written[path]; ok {
+		return fmt.Errorf("failed to build %s: %s is also written by %s", source, path, other)
+	}
+	written[path] = source
+	return nil
+}
+
+// loadData returns the data a target is rendered with and whether it came from a data file.  The data file next to a
+// target, e.g. index.json or index.yaml for index.layout.tmpl, takes precedence over its front matter.
+func (c *Context) loadData(target string) (interface{}, bool, error) {
+	if file, ok := c.dataFile(target); ok {
+		data, err := c.readData(file)
+		return data, true, err
+	}
+	data, err := c.frontMatter(target)
+	return data, false, err
+}
+
+// dataFile returns the data file next to a target that is named like its route
+func (c *Context) dataFile(target string) (string, bool) {
+	dir, name := path.Split(target)
+	// the name of a leading route parameter may contain dots, e.g. [page.slug].tmpl reads [page.slug].json
+	skip := 0
+	if strings.HasPrefix(name, "[") {
+		skip = strings.Index(name, "]") + 1
+	}
+	if i := strings.Index(name[skip:], "."); i >= 0 {
+		name = name[:skip+i]
+	}
+	for _, ext := range dataExtensions {
+		if file := path.Join(dir, name+ext); c.InputFS.Exists(file) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// readData decodes a data file, or the front matter of a markdown file
+func (c *Context) readData(file string) (interface{}, error) {
+	if err, ok := c.invalid[utils.ParsePath(file).String()]; ok {
+		return nil, err
+	}
+	if path.Ext(file) == ".md" {
+		return c.frontMatter(file)
+	}
+	b, err := c.InputFS.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if path.Ext(file) == ".json" {
+		err = json.Unmarshal(b, &data)
+	} else {
+		err = yaml.Unmarshal(b, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data file %s: %w", file, err)
+	}
+	return data, nil
+}
+
+// frontMatter returns the front matter of a template or markdown file as template data.  Values keep the types they
+// are written with, except in virtual files whose front matter is only held as metadata.
+func (c *Context) frontMatter(file string) (map[string]interface{}, error) {
+	values, err := c.InputFS.FrontMatter(file)
+	if err != nil || values != nil {
+		return values, err
+	}
+	m, err := c.InputFS.Metadata(file)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMetadata(m), nil
+}
+
+// decodeMetadata returns front matter as template data, decoding the lists and tables that are stored as JSON
+func decodeMetadata(m fs.Metadata) map[string]interface{} {
+	data := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		var v interface{}
+		if (strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{")) && json.Unmarshal([]byte(value), &v) == nil {
+			data[key] = v
+			continue
+		}
+		data[key] = value
+	}
+	return data
+}
+
+// collection returns the collection declared in the front matter of a target or nil when it declares none
+func (c *Context) collection(target string) (*collection, error) {
+	m, err := c.InputFS.Metadata(target)
+	if err != nil {
+		return nil, err
+	}
+	return parseCollection(target, m)
+}
+
+// itemURLs returns the URL of every item by collection directory and slug.  Items are served by the first target
+// with route parameters that declares their collection.
+func (c *Context) itemURLs(routes []route.Route) (map[string]map[string]string, error) {
+	urls := make(map[string]map[string]string)
+	for _, r := range routes {
+		if len(r.Params) == 0 {
+			continue
+		}
+		col, err := c.collection(r.Target)
+		if err != nil {
+			return nil, err
+		}
+		if col == nil || urls[col.dir] != nil {
+			continue
+		}
+		items, err := c.items(col)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := itemPages(r, items, nil); err != nil {
+			return nil, err
+		}
+		urls[col.dir] = make(map[string]string, len(items))
+		for _, i := range items {
+			urls[col.dir][i.Slug] = i.URL
+		}
+	}
+	return urls, nil
+}
+
+// expand returns a page for every entry in the data of a route with parameters, which must be a list of objects that
+// include the parameters
+func expand(r route.Route, data interface{}) ([]Page, []interface{}, error) {
+	list, ok := data.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to build %s: the data file of a route with parameters must be a list of entries", r.Target)
+	}
+	pages := make([]Page, len(list))
+	for i, entry := range list {
+		values, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("failed to build %s: entry %d of its data file is not an object", r.Target, i+1)
+		}
+		url, err := fill(r, values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build %s: entry %d of its data file %w", r.Target, i+1, err)
+		}
+		pages[i] = Page{Target: r.Target, URL: url}
+	}
+	return pages, list, nil
+}
+
+// fill returns the route with its parameters replaced by values, which must be single path segments and integers
+// for int parameters
+func fill(r route.Route, values map[string]interface{}) (string, error) {
+	u := r.Path
+	for _, p := range r.Params {
+		v, ok := values[p.Name]
+		if !ok {
+			return "", fmt.Errorf("has no value for parameter %s", p.Name)
+		}
+		segment := fmt.Sprint(v)
+		// the segment names a directory of the output, so it may not leave the directory of the route
+		if segment == "" || segment == "." || segment == ".." || url.PathEscape(segment) != segment {
+			return "", fmt.Errorf("has a value for parameter %s that is not a single path segment: %q", p.Name, segment)
+		}
+		if p.Type == "int" {
+			if _, err := strconv.Atoi(segment); err != nil {
+				return "", fmt.Errorf("has a value for parameter %s that is not an int: %q", p.Name, segment)
+			}
+		}
+		u = strings.Replace(u, "{"+p.Name+"}", segment, 1)
+	}
+	return u, nil
+}
+
+// outputPath returns the file a route is written to, relative to the output directory.  Routes whose last segment
+// has an extension, such as /robots.txt, are written as is, and other html routes to an index.html so that every URL
+// works on a static file server.
+func outputPath(url string, typ string) string {
+	p := strings.TrimPrefix(url, "/")
+	switch {
+	case p == "" || strings.HasSuffix(p, "/"):
+		return p + "index.html"
+	case path.Ext(p) != "":
+		return p
+	case typ == fs.TypeText:
+		return p + ".txt"
+	default:
+		return p + "/index.html"
+	}
+}
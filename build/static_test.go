@@ -0,0 +1,250 @@
+package build
+
+import (
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTBurke/taevas/build/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":               `<h1>{{ template "content" . }}</h1>`,
+		"index.layout.tmpl":          `{{ define "content" }}{{ .title | upper }}{{ end }}`,
+		"index.json":                 `{"title": "home"}`,
+		"about.layout.tmpl":          "---\ntitle: About\ntags: [a, b]\n---\n{{ define \"content\" }}{{ .title }} {{ range .tags }}{{ . }}{{ end }}{{ end }}",
+		"users/[id:int].layout.tmpl": `{{ define "content" }}{{ .name }}{{ end }}`,
+		"users/[id:int].yaml":        "- id: 1\n  name: Ann\n- id: 2\n  name: Bob\n",
+		"docs/[slug].layout.tmpl":    `{{ define "content" }}{{ .slug }}{{ end }}`,
+		"_plain.txt":                 `{{ template "content" . }}`,
+		"robots.plain.txt":           "---\nroute: /robots.txt\n---\n{{ define \"content\" }}User-agent: *{{ end }}",
+		"notes.plain.txt":            `{{ define "content" }}<notes>{{ end }}`,
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false), WithTextExtensions(".txt"), WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	site, err := ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []Page{
+		{Target: "./index.layout.tmpl", URL: "/", Path: "index.html"},
+		{Target: "./about.layout.tmpl", URL: "/about", Path: "about/index.html"},
+		{Target: "./notes.plain.txt", URL: "/notes", Path: "notes.txt"},
+		{Target: "./robots.plain.txt", URL: "/robots.txt", Path: "robots.txt"},
+		{Target: "users/[id:int].layout.tmpl", URL: "/users/1", Path: "users/1/index.html"},
+		{Target: "users/[id:int].layout.tmpl", URL: "/users/2", Path: "users/2/index.html"},
+	}, site.Pages)
+	assert.Equal(t, []string{"docs/[slug].layout.tmpl"}, site.Skipped)
+
+	for path, expect := range map[string]string{
+		"index.html":         "<h1>HOME</h1>",
+		"about/index.html":   "<h1>About ab</h1>",
+		"notes.txt":          "<notes>",
+		"robots.txt":         "User-agent: *",
+		"users/1/index.html": "<h1>Ann</h1>",
+		"users/2/index.html": "<h1>Bob</h1>",
+	} {
+		b, err := os.ReadFile(filepath.Join(out, path))
+		require.NoError(t, err)
+		assert.Equal(t, expect, string(b), "failed for %s", path)
+	}
+
+	// existing files are only replaced when the output directory allows it
+	_, err = ctx.Build()
+	assert.Error(t, err)
+	ctx, err = New(root, WithOutputDirectory(out, true), WithTextExtensions(".txt"), WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	_, err = ctx.Build()
+	assert.NoError(t, err)
+}
+
+func TestBuildDataErrors(t *testing.T) {
+	tt := []struct {
+		name  string
+		param string
+		data  string
+	}{
+		{name: "not a list", param: "id", data: `{"id": 1}`},
+		{name: "missing parameter", param: "id", data: `[{"name": "Ann"}]`},
+		{name: "not a segment", param: "id", data: `[{"id": "a/b"}]`},
+		{name: "parent directory", param: "id", data: `[{"id": ".."}]`},
+		{name: "current directory", param: "id", data: `[{"id": "."}]`},
+		{name: "escaped", param: "id", data: `[{"id": "a b"}]`},
+		{name: "not an int", param: "id:int", data: `[{"id": "ann"}]`},
+		{name: "fractional int", param: "id:int", data: `[{"id": 1.5}]`},
+	}
+	for _, tc := range tt {
+		root := writeTree(t, map[string]string{
+			"_layout.tmpl":                         `{{ .name }}`,
+			"users/[" + tc.param + "].layout.tmpl": ``,
+			"users/[" + tc.param + "].json":        tc.data,
+		})
+		ctx, err := New(root, WithOutputDirectory(filepath.Join(root, "public"), false))
+		require.NoError(t, err)
+		require.NoError(t, ctx.Scan())
+		_, err = ctx.Build()
+		assert.Error(t, err, "failed for %s", tc.name)
+	}
+
+	// data files that fail to parse only fail the builds that read them
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":           ``,
+		"users/[id].layout.tmpl": ``,
+		"users/[id].json":        `[`,
+		"config/settings.yaml":   "a: [",
+		"notes/todo.md":          "---\ntitle: [\n---\n",
+	})
+	ctx, err := New(root, WithOutputDirectory(filepath.Join(root, "public"), false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	_, err = ctx.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "users/[id].json")
+}
+
+func TestBuildCollection(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"blog/index.layout.tmpl":  "---\ntitle: Blog\ncollection: posts\nsort: date\norder: desc\nfilter: {draft: false}\nper_page: 2\n---\n{{ define \"content\" }}{{ .Data.title }} {{ .Page }}/{{ .Pages }}:{{ range .Items }} {{ .URL }}={{ .Data.title }}{{ end }} prev={{ .Prev }} next={{ .Next }}{{ end }}",
+		"blog/[slug].layout.tmpl": "---\ncollection: posts\nsort: date\n---\n{{ define \"content\" }}{{ .Item.Data.title }}:{{ .Item.Body }}{{ if .Item.Data.draft }} draft{{ end }} prev={{ .Prev }} next={{ .Next }}{{ end }}",
+		"posts/first.md":          "---\ntitle: First\ndate: 2022-01-01\ndraft: false\n---\nHello",
+		"posts/second.md":         "---\ntitle: Second\ndate: 2022-02-01\ndraft: false\n---\n",
+		"posts/third.json":        `{"title": "Third", "date": "2022-03-01", "draft": false, "slug": "3rd"}`,
+		"posts/draft.md":          "---\ntitle: Draft\ndate: 2022-04-01\ndraft: true\n---\n",
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	site, err := ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []Page{
+		{Target: "blog/index.layout.tmpl", URL: "/blog/", Path: "blog/index.html"},
+		{Target: "blog/index.layout.tmpl", URL: "/blog/page/2", Path: "blog/page/2/index.html"},
+		{Target: "blog/[slug].layout.tmpl", URL: "/blog/first", Path: "blog/first/index.html"},
+		{Target: "blog/[slug].layout.tmpl", URL: "/blog/second", Path: "blog/second/index.html"},
+		{Target: "blog/[slug].layout.tmpl", URL: "/blog/3rd", Path: "blog/3rd/index.html"},
+		{Target: "blog/[slug].layout.tmpl", URL: "/blog/draft", Path: "blog/draft/index.html"},
+	}, site.Pages)
+
+	for path, expect := range map[string]string{
+		"blog/index.html":        "Blog 1/2: /blog/3rd=Third /blog/second=Second prev= next=/blog/page/2",
+		"blog/page/2/index.html": "Blog 2/2: /blog/first=First prev=/blog/ next=",
+		"blog/first/index.html":  "First:Hello prev= next=/blog/second",
+		"blog/3rd/index.html":    "Third: prev=/blog/second next=/blog/draft",
+		// item data keeps the types of front matter and data file values
+		"blog/draft/index.html": "Draft: draft prev=/blog/3rd next=",
+	} {
+		b, err := os.ReadFile(filepath.Join(out, path))
+		require.NoError(t, err)
+		assert.Equal(t, expect, string(b), "failed for %s", path)
+	}
+
+	root = writeTree(t, map[string]string{
+		"_layout.tmpl":      ``,
+		"index.layout.tmpl": "---\ncollection: posts\nper_page: none\n---\n",
+	})
+	ctx, err = New(root, WithOutputDirectory(filepath.Join(root, "public"), false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	_, err = ctx.Build()
+	assert.Error(t, err)
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":       `<html><head><title>{{ .title }}</title></head><body><nav data-search-ignore>Menu</nav>{{ template "content" . }}</body></html>`,
+		"index.layout.tmpl":  "---\ntitle: Home\n---\n{{ define \"content\" }}<p>Layouts and templates</p>{{ end }}",
+		"search.layout.tmpl": "---\ntitle: Search\nsearch: false\n---\n{{ define \"content\" }}<input data-search-input>{{ end }}",
+		"secret.layout.tmpl": "---\ntitle: Secret\nnoindex: true\n---\n{{ define \"content\" }}<p>templates</p>{{ end }}",
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false), WithSearchIndex())
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	site, err := ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"robots.txt", "search.js", "search.json"}, site.Generated)
+
+	b, err := os.ReadFile(filepath.Join(out, "search.json"))
+	require.NoError(t, err)
+	var idx search.Index
+	require.NoError(t, json.Unmarshal(b, &idx))
+	assert.Equal(t, []search.Doc{{Title: "Home", URL: "/", Snippet: "Layouts and templates"}}, idx.Docs)
+	assert.Equal(t, []int{0, 1}, idx.Terms["templat"])
+	assert.NotContains(t, idx.Terms, "menu")
+
+	b, err = os.ReadFile(filepath.Join(out, "search.js"))
+	require.NoError(t, err)
+	assert.Equal(t, search.Script, b)
+}
+
+func TestBuildRedirects(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"contact.layout.tmpl":     "---\naliases: [/contact-us, /about/contact/]\n---\n{{ define \"content\" }}contact{{ end }}",
+		"docs/[slug].layout.tmpl": "---\naliases: /d/{slug}\n---\n{{ define \"content\" }}{{ .slug }}{{ end }}",
+		"docs/[slug].json":        `[{"slug": "intro"}, {"slug": "setup"}]`,
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false), WithBaseURL("https://example.com/"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	site, err := ctx.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []Page{
+		{Target: "./contact.layout.tmpl", URL: "/contact-us", Path: "contact-us/index.html"},
+		{Target: "./contact.layout.tmpl", URL: "/about/contact/", Path: "about/contact/index.html"},
+		{Target: "docs/[slug].layout.tmpl", URL: "/d/intro", Path: "d/intro/index.html"},
+		{Target: "docs/[slug].layout.tmpl", URL: "/d/setup", Path: "d/setup/index.html"},
+	}, site.Redirects)
+	assert.Equal(t, []string{"robots.txt", "sitemap.xml", "_redirects"}, site.Generated)
+
+	b, err := os.ReadFile(filepath.Join(out, "d/intro/index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<meta http-equiv="refresh" content="0; url=https://example.com/docs/intro">`)
+	assert.Contains(t, string(b), `<link rel="canonical" href="https://example.com/docs/intro">`)
+
+	b, err = os.ReadFile(filepath.Join(out, "_redirects"))
+	require.NoError(t, err)
+	assert.Equal(t, "/about/contact/ /contact 301\n/contact-us /contact 301\n/d/intro /docs/intro 301\n/d/setup /docs/setup 301\n", string(b))
+
+	// stubs are not listed in the sitemap
+	b, err = os.ReadFile(filepath.Join(out, "sitemap.xml"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "/d/intro")
+}
+
+func TestBuildLiveReload(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":      `<html><BODY>{{ template "content" . }}</BODY></html>`,
+		"index.layout.tmpl": `{{ define "content" }}home{{ end }}`,
+		"_plain.txt":        `{{ template "content" . }}`,
+		"notes.plain.txt":   `{{ define "content" }}notes{{ end }}`,
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false), WithTextExtensions(".txt"), WithLiveReload("/_taevas/reload.js"))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	_, err = ctx.Build()
+	require.NoError(t, err)
+
+	for path, expect := range map[string]string{
+		"index.html": `<html><BODY>home<script src="/_taevas/reload.js"></script></BODY></html>`,
+		"notes.txt":  "notes",
+	} {
+		b, err := os.ReadFile(filepath.Join(out, path))
+		require.NoError(t, err)
+		assert.Equal(t, expect, string(b), "failed for %s", path)
+	}
+	assert.Equal(t, []byte("page<script src=\"/r.js\"></script>"), injectScript([]byte("page"), "/r.js"))
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BTBurke/taevas/build/fs"
+	"github.com/BTBurke/taevas/build/schema"
+)
+
+// DataProblem is a value in a data file that does not match the schema declared for it
+type DataProblem struct {
+	File string
+	// Key locates the value, e.g. author.name or tags[2], and is empty when the whole file is invalid
+	Key     string
+	Message string
+}
+
+func (p DataProblem) String() string {
+	if p.Key == "" {
+		return p.File + ": " + p.Message
+	}
+	return p.File + ": " + p.Key + ": " + p.Message
+}
+
+// SchemaError reports data files that do not match their schema, which would otherwise render as blank fields
+type SchemaError struct {
+	Problems []DataProblem
+}
+
+func (e *SchemaError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid data files:")
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s", p)
+	}
+	return b.String()
+}
+
+// Validate checks data files against the schema declared by the schema key in the front matter of a target.  The
+// schema is the name of a Go type registered with WithSchema or the root relative path of a JSON schema file, e.g.
+// schemas/post.json.  A target declaring a collection validates the data of every item in it, including items
+// excluded by its filter, and other targets validate their data file.  It returns a SchemaError listing every
+// invalid value.
+func (c *Context) Validate() error {
+	targets, err := c.InputFS.Targets()
+	if err != nil {
+		return err
+	}
+	var problems []DataProblem
+	// data files are validated once for each schema that applies to them
+	checked := make(map[string]bool)
+	for _, target := range targets {
+		m, err := c.InputFS.Metadata(target)
+		if err != nil {
+			return err
+		}
+		name := m.Get("schema", "")
+		if name == "" {
+			continue
+		}
+		s, err := c.schema(target, name)
+		if err != nil {
+			return err
+		}
+
+		var files []string
+		col, err := parseCollection(target, m)
+		if err != nil {
+			return err
+		}
+		if col != nil {
+			items, err := c.InputFS.Collection(col.dir, fs.CollectionQuery{})
+			if err != nil {
+				return err
+			}
+			for _, i := range items {
+				files = append(files, i.Path)
+			}
+		} else if file, ok := c.dataFile(target); ok {
+			files = append(files, file)
+		}
+
+		for _, file := range files {
+			if checked[file+"\n"+name] {
+				continue
+			}
+			checked[file+"\n"+name] = true
+			data, err := c.readData(file)
+			if err != nil {
+				return err
+			}
+			validate := s.Validate
+			if path.Ext(file) == ".md" {
+				validate = s.ValidateFrontMatter
+			}
+			for _, e := range validate(data) {
+				problems = append(problems, DataProblem{File: file, Key: e.Path, Message: e.Message})
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+// schema returns the schema registered under name or parsed from the JSON schema file at name
+func (c *Context) schema(target string, name string) (*schema.Schema, error) {
+	if !strings.HasSuffix(name, ".json") {
+		s, ok := c.opts.schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("schema %s of %s is not registered with WithSchema", name, target)
+		}
+		return s, nil
+	}
+	if !c.InputFS.Exists(name) {
+		return nil, fmt.Errorf("schema %s of %s does not exist", name, target)
+	}
+	b, err := c.InputFS.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema %s of %s: %w", name, target, err)
+	}
+	return s, nil
+}
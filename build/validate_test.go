@@ -0,0 +1,60 @@
+package build
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	type post struct {
+		Title string   `json:"title"`
+		Date  string   `json:"date"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `{{ template "content" . }}`,
+		"blog/[slug].layout.tmpl": "---\ncollection: posts\nschema: Post\n---\n{{ define \"content\" }}{{ .Item.Data.title }}{{ end }}",
+		"posts/first.md":          "---\ntitle: First\ndate: 2022-01-01\n---\n",
+		"posts/second.md":         "---\ntitel: Second\ndate: 2022-02-01\ntags: [a, {b: c}]\n---\n",
+		"team/index.layout.tmpl":  "---\nschema: schemas/team.json\n---\n{{ define \"content\" }}{{ range . }}{{ .name }}{{ end }}{{ end }}",
+		"team/index.yaml":         "- name: Ann\n- nmae: Bob\n  role: 3\n",
+		"schemas/team.json":       `{"type": "array", "items": {"type": "object", "required": ["name"], "additionalProperties": false, "properties": {"name": {"type": "string"}, "role": {"type": "string"}}}}`,
+	})
+	ctx, err := New(root, WithSchema("Post", post{}))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	err = ctx.Validate()
+	var serr *SchemaError
+	require.True(t, errors.As(err, &serr), "expected a SchemaError, got %v", err)
+	assert.Equal(t, []DataProblem{
+		{File: "posts/second.md", Key: "title", Message: "is required"},
+		{File: "posts/second.md", Key: "tags[1]", Message: "expected string, found object"},
+		{File: "posts/second.md", Key: "titel", Message: "is not allowed, did you mean title?"},
+		{File: "team/index.yaml", Key: "[1].name", Message: "is required"},
+		{File: "team/index.yaml", Key: "[1].nmae", Message: "is not allowed, did you mean name?"},
+		{File: "team/index.yaml", Key: "[1].role", Message: "expected string, found number"},
+	}, serr.Problems)
+	assert.Equal(t, `invalid data files:
+  posts/second.md: title: is required
+  posts/second.md: tags[1]: expected string, found object
+  posts/second.md: titel: is not allowed, did you mean title?
+  team/index.yaml: [1].name: is required
+  team/index.yaml: [1].nmae: is not allowed, did you mean name?
+  team/index.yaml: [1].role: expected string, found number`, err.Error())
+
+	// invalid content fails the build before anything is written
+	_, err = ctx.Build()
+	assert.True(t, errors.As(err, &serr))
+
+	// a schema that is neither registered nor a file is an error
+	ctx, err = New(root)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	err = ctx.Validate()
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &serr))
+}
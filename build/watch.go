@@ -0,0 +1,200 @@
+package build
+
+import (
+	"context"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/taevas/utils"
+)
+
+// Changes are the files added, modified or removed since the input filesystem was last scanned and the targets they
+// affect
+type Changes struct {
+	// Files are relative to the root, e.g. blog/_layout.tmpl
+	Files   []string
+	Targets []string
+}
+
+// Empty reports whether no files changed
+func (ch *Changes) Empty() bool {
+	return len(ch.Files) == 0
+}
+
+// add merges other changes into ch
+func (ch *Changes) add(other *Changes) {
+	ch.Files = union(ch.Files, other.Files)
+	ch.Targets = union(ch.Targets, other.Targets)
+}
+
+// Rescan updates the input filesystem with the files added, modified or removed on disk since it was scanned, found
+// by comparing their modification times, and returns them with the targets they affect.  Only the rows of changed
+// files are replaced.  A changed template affects every target whose template tree includes it, so a changed layout
+// affects all of its targets, and a changed data file affects the target named like it and targets with a
+// collection in its directory.  Like Scan, it returns a LayoutError or DefineError when the changed templates no
+// longer resolve, along with the changes.
+func (c *Context) Rescan() (*Changes, error) {
+	known, err := c.InputFS.ModTimes()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(known))
+	var added, modified, removed []string
+	err = c.walk(func(rel string, d iofs.DirEntry) error {
+		seen[utils.ParsePath(rel).String()] = true
+		info, err := d.Info()
+		if err != nil {
+			// files removed during the walk are found by the next rescan
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		t, ok := known[utils.ParsePath(rel).String()]
+		switch {
+		case !ok:
+			added = append(added, filepath.ToSlash(rel))
+		case !info.ModTime().Equal(t):
+			modified = append(modified, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range known {
+		if !seen[p] {
+			removed = append(removed, strings.TrimPrefix(p, "./"))
+		}
+	}
+	sort.Strings(removed)
+
+	ch := &Changes{Files: union(added, modified, removed)}
+	if ch.Empty() {
+		return ch, nil
+	}
+	// targets are found both before and after updating the rows, since removing a template also removes it from the
+	// template trees
+	before, err := c.affected(append(append([]string(nil), modified...), removed...))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range append(append([]string(nil), modified...), removed...) {
+		if err := c.InputFS.Remove(p); err != nil {
+			return nil, err
+		}
+		delete(c.invalid, utils.ParsePath(p).String())
+	}
+	for _, p := range append(append([]string(nil), modified...), added...) {
+		if err := c.index(p); err != nil {
+			return nil, err
+		}
+	}
+	after, err := c.affected(append(append([]string(nil), modified...), added...))
+	if err != nil {
+		return nil, err
+	}
+	ch.Targets = union(before, after)
+
+	if err := c.checkLayouts(); err != nil {
+		return ch, err
+	}
+	return ch, c.checkDefines()
+}
+
+// affected returns the targets that depend on the files at paths
+func (c *Context) affected(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	targets, err := c.InputFS.Dependents(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		pp := utils.ParsePath(p)
+		changed[pp.String()] = true
+		if !c.opts.isTemplate(pp.FileName()) && pp.Ext() != ".css" {
+			dirs[pp.Dir()] = true
+		}
+	}
+	if len(dirs) == 0 {
+		return targets, nil
+	}
+	all, err := c.InputFS.Targets()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range all {
+		if file, ok := c.dataFile(t); ok && changed[utils.ParsePath(file).String()] {
+			targets = append(targets, t)
+			continue
+		}
+		col, err := c.collection(t)
+		if err != nil {
+			return nil, err
+		}
+		if col != nil && dirs[col.dir] {
+			targets = append(targets, t)
+		}
+	}
+	return union(targets), nil
+}
+
+// Watch rescans the root every interval until ctx is done and calls rebuild with the files changed and the targets
+// affected since the last successful rebuild.  Errors from rescanning or rebuilding are passed to report and do not
+// stop watching, since the next edit usually fixes them.  The changes are kept and rebuilt along with the next ones
+// until a rebuild succeeds.
+func (c *Context) Watch(ctx context.Context, interval time.Duration, rebuild func(*Changes) error, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pending := &Changes{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ch, err := c.Rescan()
+		if ch != nil {
+			pending.add(ch)
+		}
+		if err != nil {
+			report(err)
+			continue
+		}
+		// a failed rebuild is only retried once files change again
+		if ch.Empty() {
+			continue
+		}
+		if err := rebuild(pending); err != nil {
+			report(err)
+			continue
+		}
+		pending = &Changes{}
+	}
+}
+
+// union returns the distinct strings in lists, in order
+func union(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, l := range lists {
+		for _, s := range l {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
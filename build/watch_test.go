@@ -0,0 +1,132 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRescan(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":            `<main>{{ template "content" . }}</main>`,
+		"index.layout.tmpl":       `{{ define "content" }}home{{ end }}`,
+		"about.layout.tmpl":       `{{ define "content" }}about{{ end }}`,
+		"docs/[slug].layout.tmpl": `{{ define "content" }}{{ .slug }}{{ end }}`,
+		"docs/[slug].json":        `[{"slug": "intro"}, {"slug": "setup"}]`,
+		"news.layout.tmpl":        "---\ncollection: posts\n---\n{{ define \"content\" }}{{ range .Items }}{{ .Slug }}{{ end }}{{ end }}",
+		"posts/a.md":              "a",
+		"style.css":               "main {}",
+	})
+	out := filepath.Join(root, "public")
+	ctx, err := New(root, WithOutputDirectory(out, false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+	_, err = ctx.Build()
+	require.NoError(t, err)
+
+	ch, err := ctx.Rescan()
+	require.NoError(t, err)
+	assert.True(t, ch.Empty())
+
+	mtime := time.Now().Add(time.Hour)
+	edit := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+		require.NoError(t, os.Chtimes(filepath.Join(root, name), mtime, mtime))
+		mtime = mtime.Add(time.Second)
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(out, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// a changed layout affects all of its targets
+	edit("_layout.tmpl", `<body>{{ template "content" . }}</body>`)
+	ch, err = ctx.Rescan()
+	require.NoError(t, err)
+	assert.Equal(t, &Changes{
+		Files:   []string{"_layout.tmpl"},
+		Targets: []string{"./about.layout.tmpl", "./index.layout.tmpl", "./news.layout.tmpl", "docs/[slug].layout.tmpl"},
+	}, ch)
+
+	// a second save within the same second is found
+	saved := mtime.Add(-time.Second + time.Millisecond)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "_layout.tmpl"), saved, saved))
+	ch, err = ctx.Rescan()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_layout.tmpl"}, ch.Files)
+
+	// a data file affects the target named like it and a collection item the targets listing it
+	edit("docs/[slug].json", `[{"slug": "intro"}]`)
+	edit("posts/b.md", "b")
+	edit("style.css", "body {}")
+	ch, err = ctx.Rescan()
+	require.NoError(t, err)
+	assert.Equal(t, &Changes{
+		Files:   []string{"docs/[slug].json", "posts/b.md", "style.css"},
+		Targets: []string{"./news.layout.tmpl", "docs/[slug].layout.tmpl"},
+	}, ch)
+
+	// a removed target is rebuilt without its pages
+	require.NoError(t, os.Remove(filepath.Join(root, "about.layout.tmpl")))
+	ch, err = ctx.Rescan()
+	require.NoError(t, err)
+	assert.Equal(t, &Changes{Files: []string{"about.layout.tmpl"}, Targets: []string{"./about.layout.tmpl"}}, ch)
+
+	// only the changed targets are rendered again
+	edit("index.layout.tmpl", `{{ define "content" }}welcome{{ end }}`)
+	ch, err = ctx.Rescan()
+	require.NoError(t, err)
+	site, err := ctx.Rebuild([]string{"./index.layout.tmpl", "./about.layout.tmpl", "docs/[slug].layout.tmpl"})
+	require.NoError(t, err)
+	assert.Len(t, site.Pages, 3)
+	assert.Equal(t, "<body>welcome</body>", read("index.html"))
+	assert.Equal(t, "<body>intro</body>", read("docs/intro/index.html"))
+	assert.Equal(t, "<main>a</main>", read("news/index.html"))
+	b, err := ctx.OutputFS[""].ReadFile("news/index.html")
+	require.NoError(t, err)
+	assert.Equal(t, "<main>a</main>", string(b))
+	for _, stale := range []string{"about/index.html", "docs/setup/index.html"} {
+		_, err := os.Stat(filepath.Join(out, stale))
+		assert.True(t, os.IsNotExist(err), "failed for %s", stale)
+	}
+
+	// templates that no longer resolve are reported along with the changes
+	edit("post.missing.tmpl", `post`)
+	ch, err = ctx.Rescan()
+	var lerr *LayoutError
+	assert.ErrorAs(t, err, &lerr)
+	assert.Equal(t, []string{"post.missing.tmpl"}, ch.Files)
+}
+
+func TestWatch(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"_layout.tmpl":      `{{ template "content" . }}`,
+		"index.layout.tmpl": `{{ define "content" }}home{{ end }}`,
+	})
+	ctx, err := New(root, WithOutputDirectory(filepath.Join(root, "public"), false))
+	require.NoError(t, err)
+	require.NoError(t, ctx.Scan())
+
+	watching, stop := context.WithCancel(context.Background())
+	defer stop()
+	rebuilt := make(chan *Changes)
+	go ctx.Watch(watching, 10*time.Millisecond, func(ch *Changes) error {
+		rebuilt <- ch
+		return nil
+	}, func(err error) { t.Error(err) })
+
+	mtime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "index.layout.tmpl"), mtime, mtime))
+	select {
+	case ch := <-rebuilt:
+		assert.Equal(t, &Changes{Files: []string{"index.layout.tmpl"}, Targets: []string{"./index.layout.tmpl"}}, ch)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rebuild after a change")
+	}
+}
@@ -2,9 +2,11 @@ package utils
 
 import (
 	"errors"
+	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 )
 
 // Path represents an arbitrary location on disk, either a directory or file
@@ -22,8 +24,9 @@ func ParsePath(p string) Path {
 		}
 	}
 	d, f := filepath.Split(p)
-	// if no extension exists, the whole thing was a directory
-	if filepath.Ext(f) == "" {
+	// if no extension exists, the whole thing was a directory.  Bracketed route parameters such as [page.slug] are
+	// directories even when they contain a dot.
+	if _, param := ParseParam(f); filepath.Ext(f) == "" || param {
 		d = p
 		f = ""
 	}
@@ -136,6 +139,87 @@ func (p Path) Depth() (int, bool) {
 	return len(strings.Split(rr, string(filepath.Separator))), true
 }
 
+// PackageName returns a valid Go package name derived from the last element of the directory.  Files in the
+// module root use the last element of the module name.  Names that are Go keywords, such as a directory named type,
+// get a pkg suffix.
+func (p Path) PackageName() string {
+	name := filepath.Base(p.Dir())
+	if p.IsRoot() {
+		name = filepath.Base(GoModule())
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	out := b.String()
+	switch {
+	case out == "":
+		return "templates"
+	case unicode.IsDigit(rune(out[0])):
+		return "p" + out
+	case token.IsKeyword(out):
+		return out + "pkg"
+	}
+	return out
+}
+
+// PackageDir returns the nearest directory of the path, or one of its parents, that is a valid Go package.  Directories
+// that are route parameters such as [id] cannot be imported, so code generated for their templates is placed in the
+// parent directory.
+func (p Path) PackageDir() string {
+	dir := p.Dir()
+	for dir != "." {
+		if !strings.ContainsAny(dir, "[]") {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// Camel converts a name such as first_name, first-name, first.name or /blog/post to an exported Go identifier such as
+// FirstName or BlogPost.  Names that would be empty or start with a digit are prefixed with prefix.
+func Camel(s string, prefix string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+	out := b.String()
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = prefix + out
+	}
+	return out
+}
+
+// Params returns the route parameters in the directories and file name of the path, in order
+func (p Path) Params() []Param {
+	var params []Param
+	for _, segment := range strings.Split(filepath.ToSlash(p.dir), "/") {
+		if param, ok := ParseParam(segment); ok {
+			params = append(params, param)
+		}
+	}
+	// a file name starts with a parameter followed by its layout and extension, e.g. [id].layout.tmpl
+	if end := strings.Index(p.file, "]"); strings.HasPrefix(p.file, "[") && end > 0 {
+		if param, ok := ParseParam(p.file[:end+1]); ok {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
 // String returns the absolute path
 func (p Path) String() string {
 	if p.dir == "." {
@@ -143,3 +227,27 @@ func (p Path) String() string {
 	}
 	return filepath.Join(p.dir, p.file)
 }
+
+// Param is a route parameter written as a bracketed path segment, e.g. [id] or [id:int] with a type hint
+type Param struct {
+	Name string
+	// Type is string unless the segment has a type hint
+	Type string
+}
+
+// ParseParam parses a path segment that is a route parameter.  It returns false when the segment is not enclosed in
+// brackets or the parameter has no name.
+func ParseParam(segment string) (Param, bool) {
+	if len(segment) < 3 || segment[0] != '[' || segment[len(segment)-1] != ']' {
+		return Param{}, false
+	}
+	name := segment[1 : len(segment)-1]
+	typ := "string"
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name, typ = name[:i], name[i+1:]
+	}
+	if name == "" || typ == "" {
+		return Param{}, false
+	}
+	return Param{Name: name, Type: typ}, true
+}
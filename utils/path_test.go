@@ -41,3 +41,58 @@ func TestPath(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageName(t *testing.T) {
+	tt := map[string]string{
+		"a":                "a",
+		"admin/users":      "users",
+		"admin/user-pages": "userpages",
+		"v2/2fa":           "p2fa",
+		"docs/type":        "typepkg",
+		"func":             "funcpkg",
+	}
+	for dir, expect := range tt {
+		assert.Equal(t, expect, NewPath(dir, "").PackageName(), "failed for %s", dir)
+	}
+}
+
+func TestCamel(t *testing.T) {
+	tt := map[string]string{
+		"first_name": "FirstName",
+		"page.slug":  "PageSlug",
+		"/blog/post": "BlogPost",
+		"2fa":        "X2fa",
+		"":           "X",
+	}
+	for in, expect := range tt {
+		assert.Equal(t, expect, Camel(in, "X"), "failed for %s", in)
+	}
+}
+
+func TestParams(t *testing.T) {
+	tt := []struct {
+		in     string
+		dir    string
+		file   string
+		params []Param
+		pkg    string
+	}{
+		{in: "users/[id]/profile.layout.tmpl", dir: "users/[id]", file: "profile.layout.tmpl", params: []Param{{Name: "id", Type: "string"}}, pkg: "users"},
+		{in: "users/[id:int]/[tab].layout.tmpl", dir: "users/[id:int]", file: "[tab].layout.tmpl", params: []Param{{Name: "id", Type: "int"}, {Name: "tab", Type: "string"}}, pkg: "users"},
+		{in: "docs/[page.slug]", dir: "docs/[page.slug]", params: []Param{{Name: "page.slug", Type: "string"}}, pkg: "docs"},
+		{in: "[lang]/about.layout.tmpl", dir: "[lang]", file: "about.layout.tmpl", params: []Param{{Name: "lang", Type: "string"}}, pkg: "."},
+		{in: "blog/post.layout.tmpl", dir: "blog", file: "post.layout.tmpl", pkg: "blog"},
+	}
+	for _, tc := range tt {
+		p := ParsePath(tc.in)
+		assert.Equal(t, tc.dir, p.Dir(), "failed for %s", tc.in)
+		assert.Equal(t, tc.file, p.FileName(), "failed for %s", tc.in)
+		assert.Equal(t, tc.params, p.Params(), "failed for %s", tc.in)
+		assert.Equal(t, tc.pkg, p.PackageDir(), "failed for %s", tc.in)
+	}
+
+	for _, segment := range []string{"id", "[]", "[:int]", "[id:]", "[id"} {
+		_, ok := ParseParam(segment)
+		assert.False(t, ok, "failed for %s", segment)
+	}
+}